@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Action names recorded in the audit log
+const (
+	ActionForce = "force"
+	ActionReset = "reset"
+	ActionPin   = "pin"
+	ActionUnpin = "unpin"
+)
+
+// AuditEntry records an operator action taken through the handler
+type AuditEntry struct {
+	Time    time.Time  `json:"time"`
+	Breaker string     `json:"breaker"`
+	Action  string     `json:"action"`
+	State   string     `json:"state,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+	// Actor is taken from the ActorHeader of the request, or the remote address when missing
+	Actor string `json:"actor"`
+}
+
+// DefaultAuditSize is the number of audit entries kept in memory
+const DefaultAuditSize = 100
+
+// AuditLog keeps the most recent operator actions in memory, oldest first. A Handler keeps one, and other handlers
+// acting on breakers, such as the dashboard, can keep their own or share the one of a Handler.
+type AuditLog struct {
+	mutex   sync.RWMutex
+	size    int
+	entries []AuditEntry
+}
+
+// NewAuditLog creates an audit log keeping the size most recent entries, size must be at least 1
+func NewAuditLog(size int) (*AuditLog, error) {
+	if size < 1 {
+		return nil, ErrInvalidAuditSize{Size: size}
+	}
+	return &AuditLog{size: size}, nil
+}
+
+// Record adds an action taken on a breaker, the time and the actor are taken from the request
+func (a *AuditLog) Record(r *http.Request, entry AuditEntry) {
+	a.record(stamp(r, entry))
+}
+
+// Audit returns the entries, oldest first
+func (a *AuditLog) Audit() []AuditEntry {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	entries := make([]AuditEntry, len(a.entries))
+	copy(entries, a.entries)
+	return entries
+}
+
+func (a *AuditLog) record(entry AuditEntry) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > a.size {
+		a.entries = a.entries[len(a.entries)-a.size:]
+	}
+}
+
+// stamp sets the time of the entry and the actor that made the request
+func stamp(r *http.Request, entry AuditEntry) AuditEntry {
+	entry.Time = time.Now()
+	entry.Actor = r.Header.Get(ActorHeader)
+	if entry.Actor == "" {
+		entry.Actor = r.RemoteAddr
+	}
+	return entry
+}
@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingReason gets returned when pinning a state without giving a reason for the audit log
+var ErrMissingReason = errors.New("a reason is required to pin a state")
+
+// ErrInvalidExpiry gets returned when pinning a state until a time that already passed
+var ErrInvalidExpiry = errors.New("pin expiry must be in the future")
+
+// ErrNotJSON gets returned when a control request is not sent as application/json
+var ErrNotJSON = errors.New("control requests must be sent as application/json")
+
+// ErrStreamingUnsupported gets returned by the watch endpoint when the response writer cannot be flushed
+var ErrStreamingUnsupported = errors.New("streaming is not supported by the response writer")
+
+// ErrUnknownBreaker gets returned when no breaker is registered under the requested name
+type ErrUnknownBreaker struct {
+	Name string
+}
+
+func (e ErrUnknownBreaker) Error() string {
+	return fmt.Sprintf("unknown circuit breaker %s", e.Name)
+}
+
+// ErrDuplicateBreaker gets returned when registering a breaker whose name is already taken
+type ErrDuplicateBreaker struct {
+	Name string
+}
+
+func (e ErrDuplicateBreaker) Error() string {
+	return fmt.Sprintf("circuit breaker %s is already registered", e.Name)
+}
+
+// ErrInvalidAuditSize gets returned when the audit log is not allowed to keep at least one entry
+type ErrInvalidAuditSize struct {
+	Size int
+}
+
+func (e ErrInvalidAuditSize) Error() string {
+	return fmt.Sprintf("audit size must be at least 1, got %d", e.Size)
+}
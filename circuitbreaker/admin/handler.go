@@ -0,0 +1,284 @@
+// Package admin provides an http.Handler to inspect and control a set of breakers at runtime, meant to be
+// mounted on an admin port that is not reachable by regular traffic.
+//
+// The handler serves the following JSON endpoints, relative to where it is mounted:
+//
+//	GET    /breakers               list the registered breakers
+//	GET    /breakers/{name}        describe a breaker
+//	POST   /breakers/{name}/force  force a state, body ForceRequest
+//	POST   /breakers/{name}/reset  reset the breaker, optional body ResetRequest
+//	PUT    /breakers/{name}/pin    pin a state until an expiry, body PinRequest
+//	DELETE /breakers/{name}/pin    release a pin early
+//	GET    /audit                  list the most recent operator actions
+//	GET    /watch                  stream state changes as newline delimited Event, filtered by ?name=
+//
+// Requests that change a breaker must be sent with the application/json content type.
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// ActorHeader identifies who made a control request in the audit log
+const ActorHeader = "X-Actor"
+
+// Handler serves the admin endpoints for the breakers registered with it
+type Handler struct {
+	mutex    sync.RWMutex
+	breakers map[string]*circuitbreaker.Breaker
+	mux      *http.ServeMux
+	audit    *AuditLog
+	logger   *slog.Logger
+}
+
+var _ http.Handler = &Handler{}
+
+// Option customises the handler
+type Option func(*Handler)
+
+// WithLogger logs every audited action, nothing is logged by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithAuditSize sets how many audit entries are kept in memory, DefaultAuditSize by default. NewHandler returns
+// ErrInvalidAuditSize when it is less than 1.
+func WithAuditSize(size int) Option {
+	return func(h *Handler) {
+		h.audit.size = size
+	}
+}
+
+// NewHandler creates a handler for the breakers, more can be registered later
+func NewHandler(breakers []*circuitbreaker.Breaker, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		breakers: map[string]*circuitbreaker.Breaker{},
+		mux:      http.NewServeMux(),
+		audit:    &AuditLog{size: DefaultAuditSize},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.audit.size < 1 {
+		return nil, ErrInvalidAuditSize{Size: h.audit.size}
+	}
+
+	for _, breaker := range breakers {
+		if err := h.Register(breaker); err != nil {
+			return nil, err
+		}
+	}
+
+	h.mux.HandleFunc("GET /breakers", h.list)
+	h.mux.HandleFunc("GET /breakers/{name}", h.withBreaker(h.describe))
+	h.mux.HandleFunc("POST /breakers/{name}/force", requireJSON(h.withBreaker(h.force)))
+	h.mux.HandleFunc("POST /breakers/{name}/reset", requireJSON(h.withBreaker(h.reset)))
+	h.mux.HandleFunc("PUT /breakers/{name}/pin", requireJSON(h.withBreaker(h.pin)))
+	h.mux.HandleFunc("DELETE /breakers/{name}/pin", requireJSON(h.withBreaker(h.unpin)))
+	h.mux.HandleFunc("GET /audit", h.listAudit)
+	h.mux.HandleFunc("GET /watch", h.watch)
+	return h, nil
+}
+
+// Register adds a breaker, names must be unique
+func (h *Handler) Register(breaker *circuitbreaker.Breaker) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	name := breaker.Name()
+	if _, ok := h.breakers[name]; ok {
+		return ErrDuplicateBreaker{Name: name}
+	}
+	h.breakers[name] = breaker
+	return nil
+}
+
+// Breakers returns the registered breakers sorted by name
+func (h *Handler) Breakers() []*circuitbreaker.Breaker {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	breakers := make([]*circuitbreaker.Breaker, 0, len(h.breakers))
+	for _, breaker := range h.breakers {
+		breakers = append(breakers, breaker)
+	}
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].Name() < breakers[j].Name()
+	})
+	return breakers
+}
+
+// Breaker returns the breaker registered under the name
+func (h *Handler) Breaker(name string) (*circuitbreaker.Breaker, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	breaker, ok := h.breakers[name]
+	return breaker, ok
+}
+
+// Audit returns the most recent operator actions, oldest first
+func (h *Handler) Audit() []AuditEntry {
+	return h.audit.Audit()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	breakers := h.Breakers()
+	views := make([]Breaker, 0, len(breakers))
+	for _, breaker := range breakers {
+		views = append(views, NewBreaker(breaker))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (h *Handler) describe(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	writeJSON(w, http.StatusOK, NewBreaker(breaker))
+}
+
+func (h *Handler) force(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	var req ForceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	state, err := circuitbreaker.ParseState(req.State)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	breaker.ForceState(state)
+	h.Record(r, AuditEntry{Breaker: breaker.Name(), Action: ActionForce, State: state.String(), Reason: req.Reason})
+	writeJSON(w, http.StatusOK, NewBreaker(breaker))
+}
+
+func (h *Handler) reset(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	var req ResetRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	breaker.Reset()
+	h.Record(r, AuditEntry{Breaker: breaker.Name(), Action: ActionReset, Reason: req.Reason})
+	writeJSON(w, http.StatusOK, NewBreaker(breaker))
+}
+
+func (h *Handler) pin(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	var req PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	state, err := circuitbreaker.ParseState(req.State)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Reason == "" {
+		writeError(w, http.StatusBadRequest, ErrMissingReason)
+		return
+	}
+
+	var until time.Time
+	if req.Until != nil {
+		until = *req.Until
+	}
+	if req.Duration != "" {
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		until = time.Now().Add(duration)
+	}
+
+	if !until.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, ErrInvalidExpiry)
+		return
+	}
+
+	breaker.Pin(state, until, req.Reason)
+	h.Record(r, AuditEntry{Breaker: breaker.Name(), Action: ActionPin, State: state.String(), Until: &until, Reason: req.Reason})
+	writeJSON(w, http.StatusOK, NewBreaker(breaker))
+}
+
+func (h *Handler) unpin(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	breaker.Unpin()
+	h.Record(r, AuditEntry{Breaker: breaker.Name(), Action: ActionUnpin})
+	writeJSON(w, http.StatusOK, NewBreaker(breaker))
+}
+
+func (h *Handler) listAudit(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Audit())
+}
+
+func (h *Handler) withBreaker(handler func(http.ResponseWriter, *http.Request, *circuitbreaker.Breaker)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		breaker, ok := h.Breaker(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, ErrUnknownBreaker{Name: name})
+			return
+		}
+		handler(w, r, breaker)
+	}
+}
+
+// requireJSON rejects requests that are not sent as JSON, so that a cross-site form post cannot change a breaker
+func requireJSON(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, ErrNotJSON)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Record adds an action taken on a breaker through another handler, such as the dashboard, to the audit log. The
+// time and the actor are taken from the request.
+func (h *Handler) Record(r *http.Request, entry AuditEntry) {
+	entry = stamp(r, entry)
+	h.audit.record(entry)
+
+	if h.logger != nil {
+		h.logger.Info("circuit breaker admin action",
+			slog.String("name", entry.Breaker),
+			slog.String("action", entry.Action),
+			slog.String("state", entry.State),
+			slog.String("reason", entry.Reason),
+			slog.String("actor", entry.Actor),
+		)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
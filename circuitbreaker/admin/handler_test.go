@@ -0,0 +1,224 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+func newHandler(t *testing.T, names ...string) (*admin.Handler, map[string]*circuitbreaker.Breaker) {
+	t.Helper()
+
+	breakers := map[string]*circuitbreaker.Breaker{}
+	var list []*circuitbreaker.Breaker
+	for _, name := range names {
+		breaker, err := circuitbreaker.NewBreaker(name)
+		if err != nil {
+			t.Fatalf("circuitbreaker.NewBreaker(%s), expected no err, got %s", name, err)
+		}
+		breakers[name] = breaker
+		list = append(list, breaker)
+	}
+
+	handler, err := admin.NewHandler(list)
+	if err != nil {
+		t.Fatalf("admin.NewHandler, expected no err, got %s", err)
+	}
+	return handler, breakers
+}
+
+func do(t *testing.T, handler http.Handler, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set(admin.ActorHeader, "oncall")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if out != nil {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s, could not decode body: %s", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestList(t *testing.T) {
+	handler, _ := newHandler(t, "payments", "inventory")
+
+	var views []admin.Breaker
+	if status := do(t, handler, http.MethodGet, "/breakers", nil, &views); status != http.StatusOK {
+		t.Fatalf("GET /breakers, status expected 200, got %d", status)
+	}
+
+	if len(views) != 2 || views[0].Name != "inventory" || views[1].Name != "payments" {
+		t.Fatalf("GET /breakers, expected inventory and payments, got %+v", views)
+	}
+
+	expected := admin.Thresholds{
+		FailureRate:          circuitbreaker.DefaultFailureRate,
+		RecoveryRate:         circuitbreaker.DefaultRecoveryRate,
+		CooldownDuration:     circuitbreaker.DefaultCooldownDuration.String(),
+		MaxRequestOnHalfOpen: circuitbreaker.DefaultMaxRequestOnHalfOpen,
+		MinRequests:          circuitbreaker.DefaultMinRequests,
+	}
+	if views[0].Thresholds != expected || views[0].State != "closed" {
+		t.Errorf("GET /breakers, expected closed with thresholds %+v, got %+v", expected, views[0])
+	}
+}
+
+func TestDescribeUnknown(t *testing.T) {
+	handler, _ := newHandler(t, "payments")
+
+	var body admin.Error
+	if status := do(t, handler, http.MethodGet, "/breakers/orders", nil, &body); status != http.StatusNotFound {
+		t.Errorf("GET /breakers/orders, status expected 404, got %d", status)
+	}
+
+	if expected := (admin.ErrUnknownBreaker{Name: "orders"}).Error(); body.Error != expected {
+		t.Errorf("GET /breakers/orders, error expected %q, got %q", expected, body.Error)
+	}
+}
+
+func TestForceAndReset(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	var view admin.Breaker
+	status := do(t, handler, http.MethodPost, "/breakers/payments/force", admin.ForceRequest{State: "open", Reason: "drill"}, &view)
+	if status != http.StatusOK || view.State != "open" || view.OpenUntil == nil {
+		t.Errorf("POST force, expected 200 open with open_until, got %d %+v", status, view)
+	}
+
+	if state := breakers["payments"].State(); state != circuitbreaker.Open {
+		t.Errorf("breaker state, expected open, got %s", state)
+	}
+
+	status = do(t, handler, http.MethodPost, "/breakers/payments/force", admin.ForceRequest{State: "ajar"}, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("POST force with unknown state, status expected 400, got %d", status)
+	}
+
+	status = do(t, handler, http.MethodPost, "/breakers/payments/reset", nil, &view)
+	if status != http.StatusOK || view.State != "closed" {
+		t.Errorf("POST reset, expected 200 closed, got %d %+v", status, view)
+	}
+
+	var audit []admin.AuditEntry
+	do(t, handler, http.MethodGet, "/audit", nil, &audit)
+	if len(audit) != 2 || audit[0].Action != admin.ActionForce || audit[0].Reason != "drill" || audit[0].Actor != "oncall" || audit[1].Action != admin.ActionReset {
+		t.Errorf("GET /audit, expected force then reset by oncall, got %+v", audit)
+	}
+}
+
+func TestPin(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	status := do(t, handler, http.MethodPut, "/breakers/payments/pin", admin.PinRequest{State: "open", Duration: "10m"}, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("PUT pin without reason, status expected 400, got %d", status)
+	}
+
+	status = do(t, handler, http.MethodPut, "/breakers/payments/pin", admin.PinRequest{State: "open", Duration: "-1m", Reason: "past"}, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("PUT pin in the past, status expected 400, got %d", status)
+	}
+
+	var view admin.Breaker
+	status = do(t, handler, http.MethodPut, "/breakers/payments/pin", admin.PinRequest{State: "open", Duration: "10m", Reason: "payments outage"}, &view)
+	if status != http.StatusOK || view.Pin == nil || view.Pin.Reason != "payments outage" || view.Pin.State != "open" {
+		t.Fatalf("PUT pin, expected 200 with pin, got %d %+v", status, view)
+	}
+
+	if remaining := time.Until(view.Pin.Until); remaining < 9*time.Minute || remaining > 10*time.Minute {
+		t.Errorf("PUT pin, expected pin to expire in 10m, got %s", remaining)
+	}
+
+	if _, ok := breakers["payments"].Pinned(); !ok {
+		t.Errorf("breaker.Pinned, expected pin")
+	}
+
+	var unpinned admin.Breaker
+	status = do(t, handler, http.MethodDelete, "/breakers/payments/pin", nil, &unpinned)
+	if status != http.StatusOK || unpinned.Pin != nil {
+		t.Errorf("DELETE pin, expected 200 without pin, got %d %+v", status, unpinned)
+	}
+}
+
+func TestNotJSONRejected(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	testCases := map[string]struct {
+		method      string
+		path        string
+		contentType string
+		expected    int
+	}{
+		"Form":    {http.MethodPost, "/breakers/payments/force", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		"Text":    {http.MethodPost, "/breakers/payments/reset", "text/plain", http.StatusUnsupportedMediaType},
+		"Pin":     {http.MethodPut, "/breakers/payments/pin", "", http.StatusUnsupportedMediaType},
+		"Unpin":   {http.MethodDelete, "/breakers/payments/pin", "", http.StatusUnsupportedMediaType},
+		"Charset": {http.MethodPost, "/breakers/payments/force", "application/json; charset=utf-8", http.StatusOK},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"state":"open","duration":"10m","reason":"drill"}`))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expected {
+				t.Errorf("%s %s as %q, status expected %d, got %d", tc.method, tc.path, tc.contentType, tc.expected, rec.Code)
+			}
+		})
+	}
+
+	if audit := handler.Audit(); len(audit) != 1 || breakers["payments"].State() != circuitbreaker.Open {
+		t.Errorf("handler.Audit, expected only the JSON request to force the breaker open, got %+v", audit)
+	}
+}
+
+func TestAuditSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		_, err := admin.NewHandler(nil, admin.WithAuditSize(size))
+		if err != (admin.ErrInvalidAuditSize{Size: size}) {
+			t.Errorf("admin.NewHandler with audit size %d, expected ErrInvalidAuditSize, got %v", size, err)
+		}
+		if _, err := admin.NewAuditLog(size); err != (admin.ErrInvalidAuditSize{Size: size}) {
+			t.Errorf("admin.NewAuditLog(%d), expected ErrInvalidAuditSize, got %v", size, err)
+		}
+	}
+
+	handler, err := admin.NewHandler(nil, admin.WithAuditSize(1))
+	if err != nil {
+		t.Fatalf("admin.NewHandler with audit size 1, expected no err, got %s", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/breakers/payments/reset", nil)
+	handler.Record(req, admin.AuditEntry{Breaker: "payments", Action: admin.ActionReset})
+	handler.Record(req, admin.AuditEntry{Breaker: "payments", Action: admin.ActionUnpin})
+	if audit := handler.Audit(); len(audit) != 1 || audit[0].Action != admin.ActionUnpin {
+		t.Errorf("handler.Audit, expected only the last entry, got %+v", audit)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	err := handler.Register(breakers["payments"])
+	if err != (admin.ErrDuplicateBreaker{Name: "payments"}) {
+		t.Errorf("handler.Register, expected ErrDuplicateBreaker, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Breaker is the JSON representation of a breaker
+type Breaker struct {
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	OpenUntil  *time.Time `json:"open_until,omitempty"`
+	Pin        *Pin       `json:"pin,omitempty"`
+	Thresholds Thresholds `json:"thresholds"`
+	Aggregate  Aggregate  `json:"aggregate"`
+}
+
+// Thresholds is the JSON representation of circuitbreaker.Thresholds, durations are formatted with
+// time.Duration.String
+type Thresholds struct {
+	FailureRate          float64 `json:"failure_rate"`
+	RecoveryRate         float64 `json:"recovery_rate"`
+	CooldownDuration     string  `json:"cooldown_duration"`
+	MaxRequestOnHalfOpen int     `json:"max_request_on_half_open"`
+	MinRequests          int     `json:"min_requests"`
+}
+
+// Aggregate is the JSON representation of gauges.Aggregate along with its rates
+type Aggregate struct {
+	RequestCount int     `json:"request_count"`
+	FailureCount int     `json:"failure_count"`
+	SuccessCount int     `json:"success_count"`
+	FailureRate  float64 `json:"failure_rate"`
+	SuccessRate  float64 `json:"success_rate"`
+}
+
+// Pin is the JSON representation of circuitbreaker.Pin
+type Pin struct {
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// NewBreaker captures the current status of the breaker
+func NewBreaker(breaker *circuitbreaker.Breaker) Breaker {
+	thresholds := breaker.CurrentSettings().Thresholds
+	view := Breaker{
+		Name:  breaker.Name(),
+		State: breaker.State().String(),
+		Thresholds: Thresholds{
+			FailureRate:          thresholds.FailureRate,
+			RecoveryRate:         thresholds.RecoveryRate,
+			CooldownDuration:     thresholds.CooldownDuration.String(),
+			MaxRequestOnHalfOpen: thresholds.MaxRequestOnHalfOpen,
+			MinRequests:          thresholds.MinRequests,
+		},
+		Aggregate: NewAggregate(breaker.Aggregate()),
+	}
+
+	if until, ok := breaker.OpenUntil(); ok {
+		view.OpenUntil = &until
+	}
+
+	if pin, ok := breaker.Pinned(); ok {
+		view.Pin = &Pin{State: pin.State.String(), Since: pin.Since, Until: pin.Until, Reason: pin.Reason}
+	}
+	return view
+}
+
+// NewAggregate converts the aggregate and computes its rates
+func NewAggregate(aggregate gauges.Aggregate) Aggregate {
+	return Aggregate{
+		RequestCount: aggregate.RequestCount,
+		FailureCount: aggregate.FailureCount,
+		SuccessCount: aggregate.SuccessCount,
+		FailureRate:  aggregate.FailureRate(),
+		SuccessRate:  aggregate.SuccessRate(),
+	}
+}
+
+// ForceRequest is the body of a force request
+type ForceRequest struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+// PinRequest is the body of a pin request, either Duration (parsed with time.ParseDuration) or Until must be set
+type PinRequest struct {
+	State    string     `json:"state"`
+	Duration string     `json:"duration,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+	Reason   string     `json:"reason"`
+}
+
+// ResetRequest is the optional body of a reset request
+type ResetRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Error is the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// EventSnapshot is the type of the events sent first by the watch endpoint, one per breaker, with its current
+// state
+const EventSnapshot = "snapshot"
+
+// Event is the JSON representation of the state related events streamed by the watch endpoint
+type Event struct {
+	Time    time.Time `json:"time"`
+	Breaker string    `json:"breaker"`
+	Type    string    `json:"type"`
+	From    string    `json:"from,omitempty"`
+	State   string    `json:"state"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// NewEvent converts a breaker event
+func NewEvent(event circuitbreaker.Event) Event {
+	return Event{
+		Time:    event.Time,
+		Breaker: event.Name,
+		Type:    event.Type.String(),
+		From:    event.From.String(),
+		State:   event.State.String(),
+		Reason:  event.Reason,
+	}
+}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// WatchContentType is the content type of the watch endpoint, one JSON encoded Event per line
+const WatchContentType = "application/x-ndjson"
+
+// watch streams a snapshot of the watched breakers followed by their state changes until the client goes away.
+// The name query parameter can be repeated to only watch some breakers.
+func (h *Handler) watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrStreamingUnsupported)
+		return
+	}
+
+	breakers, err := h.selectBreakers(r.URL.Query()["name"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	events := make(chan circuitbreaker.Event, circuitbreaker.DefaultEventBufferSize)
+	for _, breaker := range breakers {
+		sub := breaker.Subscribe(circuitbreaker.DefaultEventBufferSize, circuitbreaker.StateChangeTypes()...)
+		defer sub.Close()
+
+		go func() {
+			for event := range sub.Events() {
+				select {
+				case events <- event:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", WatchContentType)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	now := time.Now()
+	for _, breaker := range breakers {
+		state := breaker.State().String()
+		encoder.Encode(Event{Time: now, Breaker: breaker.Name(), Type: EventSnapshot, State: state})
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-events:
+			if err := encoder.Encode(NewEvent(event)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// selectBreakers returns the named breakers, or all of them when no name is given
+func (h *Handler) selectBreakers(names []string) ([]*circuitbreaker.Breaker, error) {
+	if len(names) == 0 {
+		return h.Breakers(), nil
+	}
+
+	breakers := make([]*circuitbreaker.Breaker, 0, len(names))
+	for _, name := range names {
+		breaker, ok := h.Breaker(name)
+		if !ok {
+			return nil, ErrUnknownBreaker{Name: name}
+		}
+		breakers = append(breakers, breaker)
+	}
+	return breakers, nil
+}
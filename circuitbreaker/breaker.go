@@ -1,8 +1,12 @@
 package circuitbreaker
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
 )
@@ -10,11 +14,36 @@ import (
 // ExecuteHandler describe a function that handles triggering the actual requests
 type ExecuteHandler func(name string) (*http.Response, error)
 
+// ExecuteContextHandler is like ExecuteHandler but also receives the context passed to ExecuteContext
+type ExecuteContextHandler func(ctx context.Context, name string) (*http.Response, error)
+
 // Breaker manages the circuit breaker activities such as executing the request
 type Breaker struct {
-	mutex        sync.RWMutex
+	mutex sync.RWMutex
+	// Settings in effect, they are replaced as a whole by UpdateSettings so read them with CurrentSettings when
+	// the breaker may be updated concurrently
 	Settings     *Settings
 	stateMachine *stateMachine
+	counter      *counter
+	broker       *eventBroker
+	listeners    []*Subscription
+	pin          *Pin
+	pinTimer     *time.Timer
+	bulkhead     *bulkhead
+	rateLimiter  *rateLimiter
+	inFlight     atomic.Int64
+	latencies    latencyWindow
+	persister    persister
+	updateMutex  sync.Mutex
+}
+
+// Pin describes a state that was forced on the breaker until an expiry time
+type Pin struct {
+	State State
+	Since time.Time
+	Until time.Time
+	// Reason is free text explaining why the state was pinned, kept for auditing
+	Reason string
 }
 
 func NewBreaker(name string) (*Breaker, error) {
@@ -28,63 +57,613 @@ func NewBreaker(name string) (*Breaker, error) {
 
 func NewBreakerWithSettings(settings *Settings) (*Breaker, error) {
 	if err := settings.Validate(); err != nil {
+		settings.logInvalid(err)
 		return nil, err
 	}
 
 	b := &Breaker{
 		Settings: settings,
+		counter:  newCounter(),
+		broker:   newEventBroker(),
+		bulkhead: newBulkhead(settings.Bulkhead),
+	}
+	b.stateMachine = NewStateMachine(settings.Gauge, settings.Thresholds, b.onCooldown)
+	if settings.StateStore != nil {
+		b.restore()
+	}
+	b.stateMachine.Throttle(settings.Throttle != nil)
+	b.rateLimiter = newRateLimiter(settings.RateLimit, b.stateMachine.State())
+
+	for _, listener := range settings.EventListeners {
+		sub := b.Subscribe(DefaultEventBufferSize)
+		b.listeners = append(b.listeners, sub)
+		listen(sub, listener)
 	}
-	b.stateMachine = NewStateMachine(settings.Gauge, settings.Thresholds, b.onStateChange)
 	return b, nil
 }
 
 func (b *Breaker) Execute(handler ExecuteHandler) (*http.Response, error) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	if handler == nil {
+		err := ErrInvalidSettingParam{Param: "ExecuteHandler", Val: nil}
+		b.CurrentSettings().logInvalid(err)
+		return nil, err
+	}
 
+	return b.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return handler(name)
+	})
+}
+
+// ExecuteContext runs the handler if the circuit breaker currently permits requests. The lock is only held
+// while admitting the request and while reporting its outcome, so concurrent calls do not wait on each other.
+// A context that is already done is returned as an error without the request being recorded, and so is one
+// that is done while waiting for a slot in the bulkhead. Failed calls are retried when the settings have a
+// Retry policy.
+func (b *Breaker) ExecuteContext(ctx context.Context, handler ExecuteContextHandler) (*http.Response, error) {
+	return b.execute(ctx, handler, false)
+}
+
+// execute runs the call, retrying it according to the settings, hedged tells whether its attempts are hedged
+func (b *Breaker) execute(ctx context.Context, handler ExecuteContextHandler, hedged bool) (*http.Response, error) {
+	// the call sticks to the settings it started with, even if they are updated while it runs
+	settings := b.CurrentSettings()
 	if handler == nil {
-		return nil, ErrInvalidSettingParam{Param: "ExecuteHandler", Val: nil}
+		err := ErrInvalidSettingParam{Param: "ExecuteHandler", Val: nil}
+		settings.logInvalid(err)
+		return nil, err
 	}
 
-	prev := b.stateMachine.State()
-	if !b.stateMachine.ShouldMakeRequests() {
-		return nil, ErrRequestNotPermitted{
-			Name:  b.Settings.Name,
-			State: b.stateMachine.State(),
+	retry := settings.Retry
+	if retry != nil {
+		retry.Budget.deposit()
+	}
+	// unrecorded is set while a failed attempt was retried without being recorded in the gauge
+	unrecorded := false
+	for attempt := 1; ; attempt++ {
+		var outcome attemptOutcome
+		if hedged {
+			outcome = b.hedgedAttempt(ctx, settings, handler, attempt)
+		} else {
+			outcome = b.attempt(ctx, settings, handler, attempt)
+		}
+		resp, err := outcome.resp, outcome.err
+		switch {
+		case outcome.result == attemptSucceeded:
+			b.report(gauges.Success, outcome.probe)
+			return resp, err
+		case outcome.result != attemptFailed:
+			// the retry was rejected or ignored, the attempt before it is the outcome of the call
+			if unrecorded {
+				b.report(gauges.Failure, 0)
+			}
+			return resp, err
+		case !retry.retries(ctx, resp, err, attempt):
+			b.report(gauges.Failure, outcome.probe)
+			return resp, err
+		case !retry.Budget.withdraw():
+			b.counter.recordRetryDenied()
+			b.logRetryDenied(attempt, err)
+			b.publish(Event{Type: RetryBudgetExhausted, State: b.State(), Err: err, Attempt: attempt})
+			b.report(gauges.Failure, outcome.probe)
+			return resp, err
+		}
+
+		if retry.RecordAttempts {
+			b.report(gauges.Failure, outcome.probe)
+		} else {
+			unrecorded = true
+			b.releaseProbe(outcome.probe)
+		}
+
+		if waitErr := retry.wait(ctx, attempt); waitErr != nil {
+			if unrecorded {
+				b.report(gauges.Failure, 0)
+			}
+			return resp, err
 		}
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		b.counter.recordRetry()
 	}
+}
 
-	resp, err := handler(b.Settings.Name)
+// attemptResult tells how an attempt ended
+type attemptResult int
 
-	var outcome gauges.Outcome
+const (
+	attemptRejected attemptResult = iota
+	attemptIgnored
+	attemptSucceeded
+	attemptFailed
+)
 
-	if b.Settings.IsSuccessful(resp, err) {
-		outcome = gauges.Success
-	} else {
-		outcome = gauges.Failure
+// attemptOutcome is what a single attempt returned
+type attemptOutcome struct {
+	resp   *http.Response
+	result attemptResult
+	err    error
+	// probe is the half-open probe slot held until the outcome is reported, 0 when the attempt holds none
+	probe int
+}
+
+// attempt runs the handler once if permitted, the outcome of a call that ran is left to the caller to report
+// to the gauge, along with the probe slot it holds
+func (b *Breaker) attempt(ctx context.Context, settings *Settings, handler ExecuteContextHandler, attempt int) attemptOutcome {
+	if err := ctx.Err(); err != nil {
+		return attemptOutcome{result: attemptRejected, err: err}
 	}
 
-	state, err := b.stateMachine.ReportOutcome(outcome)
+	admitted, err := b.admit(CriticalityFromContext(ctx))
+	state := admitted.state
+	if err != nil {
+		var limited ErrLimitExceeded
+		var throttled ErrThrottled
+		var rateLimited ErrRateLimited
+		var shed ErrShed
+		switch {
+		case errors.As(err, &limited):
+			b.counter.recordLimitRejection()
+			b.rejected(ctx, settings, LimitRejected, state, err)
+		case errors.As(err, &throttled):
+			b.counter.recordThrottled()
+			b.rejected(ctx, settings, Throttled, state, err)
+		case errors.As(err, &rateLimited):
+			b.counter.recordRateLimited()
+			b.rejected(ctx, settings, RateLimited, state, err)
+		case errors.As(err, &shed):
+			b.counter.recordShed()
+			b.rejected(ctx, settings, Shed, state, err)
+		default:
+			b.counter.recordRejection()
+			b.rejected(ctx, settings, CallRejected, state, err)
+		}
+		return attemptOutcome{result: attemptRejected, err: err}
+	}
+	defer b.inFlight.Add(-1)
+
+	release, err := admitted.bulkhead.acquire(ctx, settings.Name)
+	if err != nil {
+		var full ErrBulkheadFull
+		if !errors.As(err, &full) {
+			b.releaseProbe(admitted.probe)
+			return attemptOutcome{result: attemptRejected, err: err}
+		}
+
+		b.counter.recordBulkheadRejection()
+		b.rejected(ctx, settings, BulkheadRejected, state, err)
+		if admitted.bulkhead.config.CountRejections {
+			b.report(gauges.Failure, admitted.probe)
+		} else {
+			b.releaseProbe(admitted.probe)
+		}
+		return attemptOutcome{result: attemptRejected, err: err}
+	}
+	defer release()
+
+	for _, instrumentation := range settings.Instrumentations {
+		instrumentation.CallPermitted(ctx, settings.Name, state)
+	}
+	b.publish(Event{Type: CallPermitted, State: state, Attempt: attempt})
+
+	start := time.Now()
+	resp, err := handler(ctx, settings.Name)
+	duration := time.Since(start)
+
+	// an attempt cancelled because another attempt of a hedged call succeeded says nothing about the dependency
+	if hedgeLost(ctx) || settings.IsIgnored != nil && settings.IsIgnored(resp, err) {
+		b.counter.recordIgnored()
+		for _, instrumentation := range settings.Instrumentations {
+			instrumentation.CallIgnored(ctx, settings.Name, state, err, duration)
+		}
+		b.publish(Event{Type: CallIgnored, State: state, Duration: duration, Err: err, Attempt: attempt})
+		b.releaseProbe(admitted.probe)
+		return attemptOutcome{resp: resp, result: attemptIgnored, err: err}
+	}
+
+	outcome, eventType, result := gauges.Success, CallSucceeded, attemptSucceeded
+	successful := settings.IsSuccessful(resp, err)
+	if !successful {
+		outcome, eventType, result = gauges.Failure, CallFailed, attemptFailed
+	}
 
-	if state != prev {
-		b.Settings.OnStateChange(b.Settings.Name, prev, state)
+	b.counter.recordCall(successful, err)
+	for _, instrumentation := range settings.Instrumentations {
+		instrumentation.CallFinished(ctx, settings.Name, state, outcome, err, duration)
 	}
+	b.publish(Event{Type: eventType, State: state, Duration: duration, Err: err, Attempt: attempt})
 
-	return resp, err
+	if successful && settings.Hedge != nil {
+		b.latencies.record(duration, settings.Hedge.Window)
+	}
+	if admitted.limiter != nil {
+		admitted.limiter.OnSample(Sample{RTT: duration, InFlight: admitted.inFlight, Dropped: !successful})
+	}
+	return attemptOutcome{resp: resp, result: result, err: err, probe: admitted.probe}
 }
 
+// rejected notifies everyone interested in a call that was not run, eventType tells what rejected it
+func (b *Breaker) rejected(ctx context.Context, settings *Settings, eventType EventType, state State, err error) {
+	b.logRejection(eventType, state, err)
+	for _, instrumentation := range settings.Instrumentations {
+		instrumentation.CallRejected(ctx, settings.Name, state, err)
+	}
+	b.publish(Event{Type: eventType, State: state, Err: err})
+}
+
+// Reset closes the circuit, clears the gauge and releases any pin, a rate limit starts slowly again
 func (b *Breaker) Reset() {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	prev := b.stateMachine.State()
+	b.clearPin()
 	b.stateMachine.Reset()
+	if prev == Closed {
+		b.rateLimiter.restart(time.Now())
+	}
+	b.mutex.Unlock()
+
+	b.logOverride("circuit breaker reset", prev, Closed)
+	b.publish(Event{Type: Reset, From: prev, State: Closed})
+	b.transitioned(prev, Closed)
+	if prev == Closed {
+		// the gauge was cleared without a transition
+		b.persist()
+	}
 }
 
+// ForceState moves the breaker to the given state, releasing any pin. The breaker keeps transitioning on its
+// own afterwards, use Pin to keep it in a state.
 func (b *Breaker) ForceState(state State) {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	prev := b.stateMachine.State()
+	b.clearPin()
 	b.stateMachine.TransitionState(state)
+	b.mutex.Unlock()
+
+	b.logOverride("circuit breaker state forced", prev, state)
+	b.publish(Event{Type: Forced, From: prev, State: state})
+	b.transitioned(prev, state)
+}
+
+// Trip opens the circuit as if its failure rate had crossed the threshold, unless it is already open or pinned.
+// It is meant for instances coordinating with each other, and reports whether the circuit was opened.
+func (b *Breaker) Trip() bool {
+	b.mutex.Lock()
+	prev := b.stateMachine.State()
+	if prev == Open || b.pin != nil {
+		b.mutex.Unlock()
+		return false
+	}
+	b.stateMachine.TransitionState(Open)
+	b.mutex.Unlock()
+
+	b.transitioned(prev, Open)
+	return true
+}
+
+// Pin moves the breaker to the given state and keeps it there until the given time, regardless of the
+// outcome of requests. A later Pin, ForceState or Reset replaces it. When the pin expires the breaker resumes
+// from the pinned state, an open circuit whose cooldown already elapsed moving straight to half-open.
+func (b *Breaker) Pin(state State, until time.Time, reason string) {
+	b.mutex.Lock()
+	prev := b.stateMachine.State()
+	b.clearPin()
+	pin := &Pin{State: state, Since: time.Now(), Until: until, Reason: reason}
+	b.pin = pin
+	b.stateMachine.Pin(state, until)
+	b.pinTimer = time.AfterFunc(time.Until(until), func() { b.onPinExpired(pin) })
+	b.mutex.Unlock()
+
+	b.logOverride("circuit breaker state pinned", prev, state)
+	b.publish(Event{Type: Pinned, From: prev, State: state, Reason: reason})
+	b.transitioned(prev, state)
+}
+
+// Unpin releases the pin early, the breaker stays in its current state
+func (b *Breaker) Unpin() {
+	b.mutex.Lock()
+	pin := b.pin
+	b.clearPin()
+	state := b.stateMachine.State()
+	b.mutex.Unlock()
+
+	if pin != nil {
+		b.publish(Event{Type: Unpinned, From: state, State: state, Reason: pin.Reason})
+	}
+}
+
+// Pinned returns the pin currently in effect, if any
+func (b *Breaker) Pinned() (Pin, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.pin == nil {
+		return Pin{}, false
+	}
+	return *b.pin, true
+}
+
+// OpenUntil returns when the cooldown of an open circuit ends, ok is false when the circuit is not open
+func (b *Breaker) OpenUntil() (until time.Time, ok bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.stateMachine.State() != Open {
+		return time.Time{}, false
+	}
+	return b.stateMachine.OpenUntil(), true
+}
+
+// Subscribe starts delivering the events of the breaker on a channel buffering up to bufferSize events,
+// DefaultEventBufferSize is used when bufferSize is not positive. Only the events of the given types are
+// delivered, and so only they take room in the buffer, every event is delivered when none is given. The
+// subscription must be closed once done.
+func (b *Breaker) Subscribe(bufferSize int, types ...EventType) *Subscription {
+	return b.broker.subscribe(bufferSize, types)
+}
+
+// Close stops the goroutines of the listeners registered with WithEventListener, once they return from the event
+// they are handling. The breaker keeps guarding calls, and subscriptions made with Subscribe are left to their
+// owner to close.
+func (b *Breaker) Close() {
+	for _, sub := range b.listeners {
+		sub.Close()
+	}
+}
+
+// Name returns the name of the breaker, it does not change when the settings are updated
+func (b *Breaker) Name() string {
+	return b.CurrentSettings().Name
+}
+
+// CurrentSettings returns the settings in effect, they must not be modified, use UpdateSettings instead
+func (b *Breaker) CurrentSettings() *Settings {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.Settings
+}
+
+// UpdateSettings applies the options to a copy of the current settings and, once they are validated, puts them
+// in effect on the running breaker. Calls already running finish with the settings they started with. The name
+// cannot be changed, and event listeners and the state store are only used when the breaker is created.
+//
+// A new gauge takes over the readings logged in the previous one when it implements gauges.Restorer and the
+// previous one gauges.Snapshotter. The thresholds are checked right away, so lowering them can open the circuit,
+// while an open circuit keeps the cooldown it started with. A new bulkhead starts empty, calls already running are
+// not counted against its limit, and a new rate limit starts with a full burst. Turning the throttle on closes the
+// circuit unless it is pinned.
+func (b *Breaker) UpdateSettings(opts ...SettingsOption) error {
+	// updates are applied one at a time so that none of them is lost
+	b.updateMutex.Lock()
+	defer b.updateMutex.Unlock()
+
+	current := b.CurrentSettings()
+	updated := current.clone()
+	for _, opt := range opts {
+		opt(updated)
+	}
+	updated.Name = current.Name
+
+	if err := updated.Validate(); err != nil {
+		updated.logInvalid(err)
+		return err
+	}
+	if updated.Gauge == nil {
+		err := ErrInvalidSettingParam{Param: "Gauge", Val: nil}
+		updated.logInvalid(err)
+		return err
+	}
+
+	var restoreErr error
+	b.mutex.Lock()
+	prev := b.stateMachine.State()
+	if updated.Gauge != current.Gauge {
+		restorer, ok := updated.Gauge.(gauges.Restorer)
+		if snapshot, snapshotted := gauges.LocalSnapshot(current.Gauge); ok && snapshotted {
+			restoreErr = restorer.Restore(snapshot)
+		}
+	}
+	b.Settings = updated
+	if !sameBulkhead(updated.Bulkhead, current.Bulkhead) {
+		// calls holding a slot release it to the bulkhead they got it from
+		b.bulkhead = newBulkhead(updated.Bulkhead)
+	}
+	if !sameRateLimit(updated.RateLimit, current.RateLimit) {
+		b.rateLimiter = newRateLimiter(updated.RateLimit, prev)
+	}
+	b.stateMachine.Reconfigure(updated.Gauge, updated.Thresholds)
+	b.stateMachine.Throttle(updated.Throttle != nil)
+	state := b.stateMachine.State()
+	b.mutex.Unlock()
+
+	if restoreErr != nil {
+		b.logStoreError("could not carry the readings over to the new gauge", restoreErr)
+	}
+	b.logSettingsUpdated(current, updated)
+	b.publish(Event{Type: SettingsUpdated, From: prev, State: state})
+	b.transitioned(prev, state)
+	return nil
+}
+
+// State returns the current state of the circuit breaker
+func (b *Breaker) State() State {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.stateMachine.State()
+}
+
+// Aggregate returns the outcomes currently held by the gauge
+func (b *Breaker) Aggregate() gauges.Aggregate {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.Settings.Gauge.OverallAggregate()
+}
+
+// InFlight returns the number of calls holding a slot in the bulkhead, it is always 0 without a bulkhead
+func (b *Breaker) InFlight() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.bulkhead.inFlight()
+}
+
+// Counts returns the cumulative call and transition totals of the circuit breaker
+func (b *Breaker) Counts() Counts {
+	return b.counter.snapshot()
+}
+
+// admission is what admit decided for a call
+type admission struct {
+	state State
+	// bulkhead the call must get a slot from, nil when calls are not bounded
+	bulkhead *bulkhead
+	// limiter to report the outcome of the call to, nil when there is none
+	limiter Limiter
+	// inFlight is the number of calls running once this one was admitted, itself included
+	inFlight int
+	// probe is the half-open probe slot taken by the call, 0 when the circuit is not half-open
+	probe int
+}
+
+// admit counts the call as running when it is permitted, the caller must decrement inFlight once it is done
+func (b *Breaker) admit(criticality Criticality) (admission, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.stateMachine.State()
+	if !b.stateMachine.ShouldMakeRequests() {
+		return admission{state: state}, ErrRequestNotPermitted{
+			Name:  b.Settings.Name,
+			State: state,
+		}
+	}
+
+	if shedding := b.Settings.Shedding; shedding != nil {
+		if shedding.sheds(criticality, state, b.Settings.Gauge.OverallAggregate(), b.Settings.Thresholds.MinRequests) {
+			return admission{state: state}, ErrShed{Name: b.Settings.Name, Criticality: criticality, State: state}
+		}
+	}
+
+	if throttle := b.Settings.Throttle; throttle != nil && !b.stateMachine.IsPinned(time.Now()) {
+		if rejected, probability := throttle.rejects(b.Settings.Gauge.OverallAggregate()); rejected {
+			// rejected calls count as requests, which keeps shedding load until the dependency accepts calls again
+			b.Settings.Gauge.LogReading(gauges.Failure)
+			return admission{state: state}, ErrThrottled{Name: b.Settings.Name, Probability: probability}
+		}
+	}
+
+	if b.rateLimiter != nil {
+		if allowed, rate := b.rateLimiter.allow(time.Now()); !allowed {
+			return admission{state: state}, ErrRateLimited{Name: b.Settings.Name, Rate: rate}
+		}
+	}
+
+	inFlight := int(b.inFlight.Load())
+	limiter := b.Settings.Limiter
+	if limiter != nil {
+		if limit := limiter.Limit(); inFlight >= limit {
+			return admission{state: state}, ErrLimitExceeded{Name: b.Settings.Name, Limit: limit}
+		}
+	}
+
+	probe, ok := b.stateMachine.AdmitProbe()
+	if !ok {
+		return admission{state: state}, ErrRequestNotPermitted{Name: b.Settings.Name, State: state}
+	}
+
+	b.inFlight.Add(1)
+	return admission{state: state, bulkhead: b.bulkhead, limiter: limiter, inFlight: inFlight + 1, probe: probe}, nil
+}
+
+// report records the outcome of a call and gives back the probe slot it held
+func (b *Breaker) report(outcome gauges.Outcome, probe int) {
+	b.mutex.Lock()
+	b.stateMachine.ReleaseProbe(probe)
+	prev := b.stateMachine.State()
+	// the request was admitted, but another call may have opened the circuit while it was in flight, in
+	// which case the outcome is simply dropped
+	state, err := b.stateMachine.ReportOutcome(outcome)
+	b.mutex.Unlock()
+
+	if err == nil {
+		b.transitioned(prev, state)
+	}
+}
+
+// releaseProbe gives back the probe slot of a call whose outcome is not reported
+func (b *Breaker) releaseProbe(probe int) {
+	if probe == 0 {
+		return
+	}
+	b.mutex.Lock()
+	b.stateMachine.ReleaseProbe(probe)
+	b.mutex.Unlock()
+}
+
+func (b *Breaker) onCooldown() {
+	b.mutex.Lock()
+	expired := b.stateMachine.CooldownExpired(time.Now())
+	state := b.stateMachine.State()
+	b.mutex.Unlock()
+
+	if expired {
+		b.transitioned(Open, state)
+	}
+}
+
+func (b *Breaker) onPinExpired(pin *Pin) {
+	b.mutex.Lock()
+	if b.pin != pin {
+		b.mutex.Unlock()
+		return
+	}
+	b.clearPin()
+	state := b.stateMachine.State()
+	b.stateMachine.CooldownExpired(time.Now())
+	// a throttling breaker does not stay in the state it was pinned in
+	b.stateMachine.Throttle(b.Settings.Throttle != nil)
+	resumed := b.stateMachine.State()
+	b.mutex.Unlock()
+
+	b.publish(Event{Type: Unpinned, From: state, State: state, Reason: pin.Reason})
+	b.transitioned(state, resumed)
+}
+
+// clearPin must be called with the lock held
+func (b *Breaker) clearPin() {
+	if b.pinTimer != nil {
+		b.pinTimer.Stop()
+		b.pinTimer = nil
+	}
+	b.pin = nil
+	b.stateMachine.Unpin()
+}
+
+// transitioned notifies everyone interested in state changes, it must be called without holding the lock so
+// that handlers can call back into the breaker
+func (b *Breaker) transitioned(from, to State) {
+	if from == to {
+		return
+	}
+
+	b.mutex.RLock()
+	settings, rateLimiter := b.Settings, b.rateLimiter
+	b.mutex.RUnlock()
+
+	rateLimiter.transitioned(from, to, time.Now())
+	b.counter.recordTransition(from, to)
+	b.logTransition(from, to)
+	for _, instrumentation := range settings.Instrumentations {
+		instrumentation.StateChanged(settings.Name, from, to)
+	}
+	if settings.OnStateChange != nil {
+		settings.OnStateChange(settings.Name, from, to)
+	}
+	b.publish(Event{Type: StateTransition, From: from, State: to})
+	b.persist()
 }
 
-func (b *Breaker) onStateChange(from, to State) {
-	b.Settings.OnStateChange(b.Settings.Name, from, to)
+func (b *Breaker) publish(event Event) {
+	event.Name = b.Name()
+	event.Time = time.Now()
+	b.broker.publish(event)
 }
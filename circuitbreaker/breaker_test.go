@@ -1,13 +1,32 @@
 package circuitbreaker_test
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aelnahas/circuitbreaker/circuitbreaker"
 	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
 )
 
+// newTestBreaker creates a breaker named test with the given options
+func newTestBreaker(t *testing.T, opts ...circuitbreaker.SettingsOption) *circuitbreaker.Breaker {
+	t.Helper()
+
+	settings, err := circuitbreaker.NewSettings("test", opts...)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewSettings, expected no err, got %s", err)
+	}
+	cb, err := circuitbreaker.NewBreakerWithSettings(settings)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+	}
+	return cb
+}
+
 type TestExecuter struct {
 	ExecutorCalledCount int
 	Handler             circuitbreaker.ExecuteHandler
@@ -148,3 +167,235 @@ func TestMonitorReset(t *testing.T) {
 		t.Errorf("cb.Execute, handler called N times, expected : 0, got: %d", te.ExecutorCalledCount)
 	}
 }
+
+func TestBreakerCounts(t *testing.T) {
+	var IsSuccessful circuitbreaker.IsSuccessfulHandler = func(r *http.Response, e error) bool {
+		return e == nil
+	}
+
+	gauge := gauges.NewFixedWindowGauge(10)
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithIsSuccessfulHandler(IsSuccessful), circuitbreaker.WithGauge(gauge))
+	cb, err := circuitbreaker.NewBreakerWithSettings(settings)
+
+	if err != nil {
+		t.Errorf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+	}
+
+	cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return &http.Response{}, nil
+	})
+	cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	})
+	cb.ForceState(circuitbreaker.Open)
+	cb.Execute(func(name string) (*http.Response, error) {
+		return nil, nil
+	})
+
+	counts := cb.Counts()
+	expected := circuitbreaker.Counts{Successes: 1, Timeouts: 1, Rejections: 1}
+
+	if counts.Successes != expected.Successes || counts.Failures != expected.Failures ||
+		counts.Timeouts != expected.Timeouts || counts.Rejections != expected.Rejections {
+		t.Errorf("cb.Counts, expected %+v, got %+v", expected, counts)
+	}
+
+	transition := circuitbreaker.Transition{From: circuitbreaker.Closed, To: circuitbreaker.Open}
+	if counts.Transitions[transition] != 1 {
+		t.Errorf("cb.Counts, transitions %+v expected 1, got %d", transition, counts.Transitions[transition])
+	}
+}
+
+func TestBreakerPin(t *testing.T) {
+	var IsSuccessful circuitbreaker.IsSuccessfulHandler = func(r *http.Response, e error) bool {
+		return false
+	}
+
+	gauge := gauges.NewFixedWindowGauge(1)
+	settings, _ := circuitbreaker.NewSettings("test",
+		circuitbreaker.WithIsSuccessfulHandler(IsSuccessful),
+		circuitbreaker.WithGauge(gauge),
+		circuitbreaker.WithMinRequest(1),
+		circuitbreaker.WithCooldownDuration(time.Millisecond))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+
+	t.Run("KeepsStateUntilExpiry", func(t *testing.T) {
+		cb.Pin(circuitbreaker.Closed, time.Now().Add(50*time.Millisecond), "maintenance")
+
+		cb.Execute(func(name string) (*http.Response, error) {
+			return nil, nil
+		})
+
+		if state := cb.State(); state != circuitbreaker.Closed {
+			t.Errorf("cb.State while pinned, expected closed, got %s", state)
+		}
+
+		pin, ok := cb.Pinned()
+		if !ok || pin.State != circuitbreaker.Closed || pin.Reason != "maintenance" {
+			t.Errorf("cb.Pinned, expected closed pin with reason, got %+v %t", pin, ok)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if _, ok := cb.Pinned(); ok {
+			t.Errorf("cb.Pinned after expiry, expected no pin")
+		}
+	})
+
+	t.Run("ForceStateReleasesPin", func(t *testing.T) {
+		cb.Pin(circuitbreaker.Open, time.Now().Add(time.Hour), "incident")
+		time.Sleep(10 * time.Millisecond)
+
+		if state := cb.State(); state != circuitbreaker.Open {
+			t.Errorf("cb.State while pinned open, expected open after cooldown, got %s", state)
+		}
+
+		cb.ForceState(circuitbreaker.Closed)
+		if _, ok := cb.Pinned(); ok {
+			t.Errorf("cb.Pinned after ForceState, expected no pin")
+		}
+	})
+}
+
+func TestBreakerTrip(t *testing.T) {
+	cb, _ := circuitbreaker.NewBreaker("test")
+
+	if !cb.Trip() || cb.State() != circuitbreaker.Open {
+		t.Errorf("cb.Trip on a closed circuit, expected open, got %s", cb.State())
+	}
+
+	if cb.Trip() {
+		t.Errorf("cb.Trip on an open circuit, expected false, got true")
+	}
+
+	cb.Pin(circuitbreaker.Closed, time.Now().Add(time.Hour), "maintenance")
+	if cb.Trip() || cb.State() != circuitbreaker.Closed {
+		t.Errorf("cb.Trip while pinned, expected closed, got %s", cb.State())
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	var closed atomic.Bool
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithMaxRequestOnHalfOpen(3),
+		circuitbreaker.WithOnStateChangeHandler(func(name string, from, to circuitbreaker.State) {
+			closed.Store(to == circuitbreaker.Closed)
+		}))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	cb.ForceState(circuitbreaker.HalfOpen)
+
+	release := make(chan struct{})
+	var running atomic.Int32
+	blocking := func(ctx context.Context, name string) (*http.Response, error) {
+		running.Add(1)
+		<-release
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+
+	var wg sync.WaitGroup
+	var rejected atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cb.ExecuteContext(context.Background(), blocking)
+			if err == (circuitbreaker.ErrRequestNotPermitted{Name: "test", State: circuitbreaker.HalfOpen}) {
+				rejected.Add(1)
+			}
+		}()
+	}
+	for running.Load()+rejected.Load() != 10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if running.Load() != 3 || rejected.Load() != 7 {
+		t.Errorf("concurrent calls while half-open, expected 3 probes and 7 rejections, got %d and %d", running.Load(), rejected.Load())
+	}
+
+	// the probes give back their slots once reported, the next ones decide the state
+	close(release)
+	wg.Wait()
+	if closed.Load() {
+		t.Fatalf("state after 3 probes, expected half-open, got closed")
+	}
+	cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	if !closed.Load() {
+		t.Errorf("state after 4 successful probes, expected closed")
+	}
+}
+
+func TestBreakerUpdateSettings(t *testing.T) {
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithFailureRate(60), circuitbreaker.WithMinRequest(4))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	cb.ExecuteContext(context.Background(), failingHandler)
+	cb.ExecuteContext(context.Background(), failingHandler)
+	cb.ExecuteContext(context.Background(), succeedingHandler)
+	cb.ExecuteContext(context.Background(), succeedingHandler)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("cb.State under the failure rate, expected closed, got %s", cb.State())
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := cb.UpdateSettings(circuitbreaker.WithFailureRate(120))
+		expectedErr := circuitbreaker.ErrInvalidSettingParam{Param: "FailureRate", Val: 120.0}
+		if err != expectedErr {
+			t.Errorf("cb.UpdateSettings, expected %s, got %v", expectedErr, err)
+		}
+		if rate := cb.CurrentSettings().Thresholds.FailureRate; rate != 60 {
+			t.Errorf("failure rate after an invalid update, expected 60, got %v", rate)
+		}
+	})
+
+	t.Run("Thresholds", func(t *testing.T) {
+		if err := cb.UpdateSettings(circuitbreaker.WithFailureRate(40), circuitbreaker.WithCooldownDuration(time.Hour)); err != nil {
+			t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+		}
+		if cb.State() != circuitbreaker.Open {
+			t.Errorf("cb.State over the new failure rate, expected open, got %s", cb.State())
+		}
+		if until, _ := cb.OpenUntil(); time.Until(until) < 59*time.Minute {
+			t.Errorf("cb.OpenUntil, expected the new cooldown, got %s", time.Until(until))
+		}
+		if settings.Thresholds.FailureRate != 60 {
+			t.Errorf("original settings, expected untouched, got failure rate %v", settings.Thresholds.FailureRate)
+		}
+
+		events := receiveEvents(t, sub, 10)
+		updated, transition := events[8], events[9]
+		if updated.Type != circuitbreaker.SettingsUpdated || transition.Type != circuitbreaker.StateTransition {
+			t.Errorf("events, expected settings-updated then state-transition, got %s and %s", updated.Type, transition.Type)
+		}
+	})
+
+	t.Run("Gauge", func(t *testing.T) {
+		cb.Reset()
+		cb.ExecuteContext(context.Background(), failingHandler)
+		cb.ExecuteContext(context.Background(), succeedingHandler)
+
+		gauge := gauges.NewFixedWindowGauge(5)
+		if err := cb.UpdateSettings(circuitbreaker.WithGauge(gauge), circuitbreaker.WithFailureRate(100)); err != nil {
+			t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+		}
+		if aggregate := gauge.OverallAggregate(); aggregate.RequestCount != 2 || aggregate.FailureCount != 1 {
+			t.Errorf("new gauge, expected the 2 previous readings, got %+v", aggregate)
+		}
+
+		cb.ExecuteContext(context.Background(), failingHandler)
+		if aggregate := cb.Aggregate(); aggregate.RequestCount != 3 {
+			t.Errorf("cb.Aggregate, expected 3 requests in the new gauge, got %d", aggregate.RequestCount)
+		}
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		rename := func(s *circuitbreaker.Settings) { s.Name = "renamed" }
+		if err := cb.UpdateSettings(rename); err != nil {
+			t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+		}
+		if cb.Name() != "test" {
+			t.Errorf("cb.Name, expected test, got %s", cb.Name())
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Bulkhead bounds the calls running at once through a breaker, so that a slow dependency cannot pile up
+// goroutines faster than the breaker notices failures. Calls over the limit wait in a bounded queue for a slot
+// and are rejected with ErrBulkheadFull when the queue is full or their wait times out.
+type Bulkhead struct {
+	// MaxConcurrent is the number of calls allowed to run at once
+	MaxConcurrent int
+	// MaxQueue is the number of calls allowed to wait for a slot, 0 rejects calls right away when all slots
+	// are taken
+	MaxQueue int
+	// QueueTimeout bounds how long a call waits for a slot, 0 waits until the context of the call is done
+	QueueTimeout time.Duration
+	// CountRejections logs calls rejected by the bulkhead as failures in the gauge, so that a saturated
+	// dependency eventually opens the circuit
+	CountRejections bool
+}
+
+// WithBulkhead bounds the calls running at once through the breaker, see Bulkhead
+func WithBulkhead(bulkhead Bulkhead) SettingsOption {
+	return func(s *Settings) {
+		s.Bulkhead = &bulkhead
+	}
+}
+
+func (b Bulkhead) validate() error {
+	if b.MaxConcurrent <= 0 {
+		return ErrInvalidSettingParam{Param: "Bulkhead.MaxConcurrent", Val: b.MaxConcurrent}
+	}
+	if b.MaxQueue < 0 {
+		return ErrInvalidSettingParam{Param: "Bulkhead.MaxQueue", Val: b.MaxQueue}
+	}
+	if b.QueueTimeout < 0 {
+		return ErrInvalidSettingParam{Param: "Bulkhead.QueueTimeout", Val: b.QueueTimeout}
+	}
+	return nil
+}
+
+func sameBulkhead(a, b *Bulkhead) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// bulkhead is the semaphore enforcing a Bulkhead, a new one is created when the settings change and calls
+// release the slot of the bulkhead they acquired it from
+type bulkhead struct {
+	config Bulkhead
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+func newBulkhead(config *Bulkhead) *bulkhead {
+	if config == nil {
+		return nil
+	}
+	return &bulkhead{config: *config, slots: make(chan struct{}, config.MaxConcurrent)}
+}
+
+// acquire waits for a slot, it returns ErrBulkheadFull when none could be taken and the error of the context
+// when it was done first
+func (bh *bulkhead) acquire(ctx context.Context, name string) (release func(), err error) {
+	if bh == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case bh.slots <- struct{}{}:
+		return bh.release, nil
+	default:
+	}
+
+	if bh.queued.Add(1) > int64(bh.config.MaxQueue) {
+		bh.queued.Add(-1)
+		return nil, ErrBulkheadFull{Name: name, MaxConcurrent: bh.config.MaxConcurrent}
+	}
+	defer bh.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if bh.config.QueueTimeout > 0 {
+		timer := time.NewTimer(bh.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case bh.slots <- struct{}{}:
+		return bh.release, nil
+	case <-timeout:
+		return nil, ErrBulkheadFull{Name: name, MaxConcurrent: bh.config.MaxConcurrent, Waited: time.Since(start)}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (bh *bulkhead) release() {
+	<-bh.slots
+}
+
+// inFlight returns the number of calls holding a slot
+func (bh *bulkhead) inFlight() int {
+	if bh == nil {
+		return 0
+	}
+	return len(bh.slots)
+}
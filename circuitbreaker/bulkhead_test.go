@@ -0,0 +1,164 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// blockingCalls starts n calls that hold their slot until release is closed, it returns once they all run
+func blockingCalls(t *testing.T, cb *circuitbreaker.Breaker, n int) (release chan struct{}, done chan error) {
+	t.Helper()
+
+	release = make(chan struct{})
+	done = make(chan error, n)
+	running := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+				running <- struct{}{}
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-running
+	}
+	return release, done
+}
+
+func TestBulkhead(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 2}))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	release, done := blockingCalls(t, cb, 2)
+	if inFlight := cb.InFlight(); inFlight != 2 {
+		t.Errorf("cb.InFlight, expected 2, got %d", inFlight)
+	}
+
+	_, err := cb.ExecuteContext(context.Background(), succeedingHandler)
+	expectedErr := circuitbreaker.ErrBulkheadFull{Name: "test", MaxConcurrent: 2}
+	if err != expectedErr {
+		t.Errorf("cb.ExecuteContext over the limit, expected %s, got %v", expectedErr, err)
+	}
+	if counts := cb.Counts(); counts.BulkheadRejections != 1 || counts.Rejections != 0 {
+		t.Errorf("cb.Counts, expected 1 bulkhead rejection and no rejection, got %d and %d", counts.BulkheadRejections, counts.Rejections)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 0 {
+		t.Errorf("cb.Aggregate, expected rejections left out of the gauge, got %d requests", aggregate.RequestCount)
+	}
+
+	events := receiveEvents(t, sub, 3)
+	if events[2].Type != circuitbreaker.BulkheadRejected || events[2].Err != expectedErr {
+		t.Errorf("event, expected bulkhead-rejected with %s, got %s with %v", expectedErr, events[2].Type, events[2].Err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("blocking call, expected no err, got %s", err)
+		}
+	}
+
+	if _, err := cb.ExecuteContext(context.Background(), succeedingHandler); err != nil {
+		t.Errorf("cb.ExecuteContext once slots are released, expected no err, got %s", err)
+	}
+	if inFlight := cb.InFlight(); inFlight != 0 {
+		t.Errorf("cb.InFlight, expected 0, got %d", inFlight)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	t.Run("Slot", func(t *testing.T) {
+		cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 1, MaxQueue: 1}))
+		release, _ := blockingCalls(t, cb, 1)
+
+		queued := make(chan error, 1)
+		go func() {
+			_, err := cb.ExecuteContext(context.Background(), succeedingHandler)
+			queued <- err
+		}()
+
+		// the queue only holds one call, the next one is rejected right away
+		time.Sleep(20 * time.Millisecond)
+		if _, err := cb.ExecuteContext(context.Background(), succeedingHandler); !errors.As(err, &circuitbreaker.ErrBulkheadFull{}) {
+			t.Errorf("cb.ExecuteContext with a full queue, expected ErrBulkheadFull, got %v", err)
+		}
+
+		close(release)
+		if err := <-queued; err != nil {
+			t.Errorf("queued call, expected no err, got %s", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 20 * time.Millisecond}))
+		release, _ := blockingCalls(t, cb, 1)
+		defer close(release)
+
+		_, err := cb.ExecuteContext(context.Background(), succeedingHandler)
+		var full circuitbreaker.ErrBulkheadFull
+		if !errors.As(err, &full) || full.Waited < 20*time.Millisecond {
+			t.Errorf("cb.ExecuteContext, expected ErrBulkheadFull after waiting 20ms, got %v", err)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 1, MaxQueue: 1}))
+		release, _ := blockingCalls(t, cb, 1)
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := cb.ExecuteContext(ctx, succeedingHandler)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("cb.ExecuteContext, expected context.DeadlineExceeded, got %v", err)
+		}
+		if rejections := cb.Counts().BulkheadRejections; rejections != 0 {
+			t.Errorf("cb.Counts, expected no bulkhead rejection, got %d", rejections)
+		}
+	})
+}
+
+func TestBulkheadCountRejections(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 1, CountRejections: true}), circuitbreaker.WithMinRequest(2))
+	release, done := blockingCalls(t, cb, 1)
+
+	cb.ExecuteContext(context.Background(), succeedingHandler)
+	cb.ExecuteContext(context.Background(), succeedingHandler)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("cb.State after counted rejections, expected open, got %s", cb.State())
+	}
+
+	close(release)
+	<-done
+}
+
+func TestBulkheadSettings(t *testing.T) {
+	_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxQueue: 1}))
+	expectedErr := circuitbreaker.ErrInvalidSettingParam{Param: "Bulkhead.MaxConcurrent", Val: 0}
+	if err != expectedErr {
+		t.Errorf("circuitbreaker.NewSettings, expected %s, got %v", expectedErr, err)
+	}
+
+	cb := newTestBreaker(t, circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 1}))
+	release, done := blockingCalls(t, cb, 1)
+
+	if err := cb.UpdateSettings(circuitbreaker.WithBulkhead(circuitbreaker.Bulkhead{MaxConcurrent: 2})); err != nil {
+		t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+	}
+	if _, err := cb.ExecuteContext(context.Background(), succeedingHandler); err != nil {
+		t.Errorf("cb.ExecuteContext with a larger bulkhead, expected no err, got %s", err)
+	}
+
+	close(release)
+	<-done
+}
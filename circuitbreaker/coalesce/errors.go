@@ -0,0 +1,25 @@
+package coalesce
+
+import (
+	"fmt"
+)
+
+// ErrInvalidOption gets returned when creating a Group with an option out of its range
+type ErrInvalidOption struct {
+	Option string
+	Val    interface{}
+}
+
+func (e ErrInvalidOption) Error() string {
+	return fmt.Sprintf("invalid coalesce group option %s: %v", e.Option, e.Val)
+}
+
+// ErrBodyTooLarge gets returned to the callers sharing an execution whose response body is over the max body
+// size, only the first caller to get the response reads it
+type ErrBodyTooLarge struct {
+	MaxBodySize int64
+}
+
+func (e ErrBodyTooLarge) Error() string {
+	return fmt.Sprintf("coalesced response body over %d bytes, it went to another caller", e.MaxBodySize)
+}
@@ -0,0 +1,229 @@
+// Package coalesce lets identical concurrent calls through a circuit breaker share a single execution, so that
+// a recovering dependency gets one request, and the breaker one outcome, for all of them
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// DefaultMaxBodySize is the size of the largest body shared, larger bodies are streamed to a single caller
+const DefaultMaxBodySize int64 = 1 << 20
+
+// Option sets optional parameters of the Group
+type Option func(*Group)
+
+// WithMaxBodySize sets the size of the largest body shared, DefaultMaxBodySize by default
+func WithMaxBodySize(size int64) Option {
+	return func(g *Group) {
+		g.maxBodySize = size
+	}
+}
+
+// Group coalesces the calls made through a breaker by key: while a call is running, the calls made with the
+// same key wait for it and get a copy of its response instead of running themselves. Only that call goes
+// through the breaker, so it is admitted and recorded once, which matters most while the circuit is half-open
+// and probes are scarce.
+//
+// The body of a shared response is read in memory, every caller gets its own copy of it. A body over the max body
+// size is not read further, it is streamed to the first caller to get the response while the others get an
+// ErrBodyTooLarge. The shared execution runs until every caller waiting for it gave up, a caller whose context is
+// done returns the error of its context right away.
+type Group struct {
+	breaker     *circuitbreaker.Breaker
+	maxBodySize int64
+
+	mutex     sync.Mutex
+	calls     map[string]*call
+	coalesced atomic.Uint64
+}
+
+// call is an execution shared by the callers of a key
+type call struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	status      int
+	proto       string
+	header      http.Header
+	body        []byte
+	hasResponse bool
+	err         error
+	// stream is the rest of a body over the max body size, it goes to the caller that claims it first
+	stream      io.ReadCloser
+	maxBodySize int64
+	claimed     atomic.Bool
+}
+
+// NewGroup creates a group coalescing the calls made through the breaker
+func NewGroup(breaker *circuitbreaker.Breaker, opts ...Option) (*Group, error) {
+	g := &Group{breaker: breaker, maxBodySize: DefaultMaxBodySize, calls: map[string]*call{}}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if g.maxBodySize < 0 {
+		return nil, ErrInvalidOption{Option: "MaxBodySize", Val: g.maxBodySize}
+	}
+	return g, nil
+}
+
+// Execute is like Breaker.Execute, sharing the execution with the running call of the same key if any
+func (g *Group) Execute(key string, handler circuitbreaker.ExecuteHandler) (*http.Response, error) {
+	return g.ExecuteContext(context.Background(), key, func(ctx context.Context, name string) (*http.Response, error) {
+		return handler(name)
+	})
+}
+
+// ExecuteContext is like Breaker.ExecuteContext, sharing the execution with the running call of the same key
+// if any. The handler gets a context that carries the values of the context of the first caller, and is only
+// cancelled once every caller gave up.
+func (g *Group) ExecuteContext(ctx context.Context, key string, handler circuitbreaker.ExecuteContextHandler) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	shared, ok := g.calls[key]
+	if ok {
+		g.coalesced.Add(1)
+	} else {
+		shared = g.start(ctx, key, handler)
+	}
+	shared.waiters++
+	g.mutex.Unlock()
+
+	select {
+	case <-shared.done:
+		return shared.response()
+	case <-ctx.Done():
+		g.mutex.Lock()
+		shared.waiters--
+		if shared.waiters == 0 {
+			// later callers start over rather than joining a cancelled execution
+			shared.cancel()
+			g.forget(key, shared)
+			select {
+			case <-shared.done:
+				shared.discard()
+			default:
+			}
+		}
+		g.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Coalesced returns the number of calls that shared the execution of another call since the group was created
+func (g *Group) Coalesced() uint64 {
+	return g.coalesced.Load()
+}
+
+// start runs the shared execution of the key, it must be called with the lock held
+func (g *Group) start(ctx context.Context, key string, handler circuitbreaker.ExecuteContextHandler) *call {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	shared := &call{done: make(chan struct{}), cancel: cancel, maxBodySize: g.maxBodySize}
+	g.calls[key] = shared
+
+	go func() {
+		defer cancel()
+		resp, err := g.breaker.ExecuteContext(ctx, handler)
+		shared.store(resp, err)
+
+		g.mutex.Lock()
+		g.forget(key, shared)
+		abandoned := shared.waiters == 0
+		g.mutex.Unlock()
+		if abandoned {
+			shared.discard()
+		}
+		close(shared.done)
+	}()
+	return shared
+}
+
+// forget removes the execution of the key unless another one already replaced it, it must be called with the
+// lock held
+func (g *Group) forget(key string, shared *call) {
+	if g.calls[key] == shared {
+		delete(g.calls, key)
+	}
+}
+
+// store keeps the response so that it can be copied for every caller
+func (c *call) store(resp *http.Response, err error) {
+	c.err = err
+	if resp == nil {
+		return
+	}
+
+	c.hasResponse = true
+	c.status, c.proto, c.header = resp.StatusCode, resp.Proto, resp.Header
+	if resp.Body == nil {
+		return
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize+1))
+	if readErr != nil {
+		// a body cut short is not shared as if it were complete
+		resp.Body.Close()
+		c.hasResponse = false
+		if c.err == nil {
+			c.err = readErr
+		}
+		return
+	}
+	c.body = body
+	if int64(len(body)) > c.maxBodySize {
+		c.stream = resp.Body
+		return
+	}
+	resp.Body.Close()
+}
+
+// discard closes a body over the max body size that no caller claimed
+func (c *call) discard() {
+	if c.stream != nil && c.claimed.CompareAndSwap(false, true) {
+		c.stream.Close()
+	}
+}
+
+// response returns a copy of the shared response
+func (c *call) response() (*http.Response, error) {
+	if !c.hasResponse {
+		return nil, c.err
+	}
+
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader(c.body))
+	contentLength := int64(len(c.body))
+	if c.stream != nil {
+		if !c.claimed.CompareAndSwap(false, true) {
+			return nil, ErrBodyTooLarge{MaxBodySize: c.maxBodySize}
+		}
+		// the caller gets what was read followed by what is left
+		body = readCloser{Reader: io.MultiReader(bytes.NewReader(c.body), c.stream), Closer: c.stream}
+		contentLength = -1
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(c.status) + " " + http.StatusText(c.status),
+		StatusCode:    c.status,
+		Proto:         c.proto,
+		Header:        c.header.Clone(),
+		Body:          body,
+		ContentLength: contentLength,
+	}, c.err
+}
+
+// readCloser reads from a reader and closes another, which keeps the original body closable once read ahead
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
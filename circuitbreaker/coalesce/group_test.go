@@ -0,0 +1,211 @@
+package coalesce_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/coalesce"
+)
+
+// blockingHandler responds once released, it counts the calls it got
+func blockingHandler(release <-chan struct{}, calls *atomic.Int32) circuitbreaker.ExecuteContextHandler {
+	return func(ctx context.Context, name string) (*http.Response, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("paid"))}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	group, err := coalesce.NewGroup(breaker)
+	if err != nil {
+		t.Fatalf("coalesce.NewGroup, expected no err, got %s", err)
+	}
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := group.ExecuteContext(context.Background(), "order-1", blockingHandler(release, &calls))
+			if err != nil {
+				t.Errorf("group.ExecuteContext, expected no err, got %s", err)
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}()
+	}
+
+	for group.Coalesced() != 9 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("calls, expected a single execution, got %d", calls.Load())
+	}
+	for i, body := range bodies {
+		if body != "paid" {
+			t.Errorf("body of caller %d, expected its own copy of paid, got %q", i, body)
+		}
+	}
+	if aggregate := breaker.Aggregate(); aggregate.RequestCount != 1 {
+		t.Errorf("breaker.Aggregate, expected a single recorded outcome, got %d requests", aggregate.RequestCount)
+	}
+
+	// the execution is over, the next call runs again
+	group.ExecuteContext(context.Background(), "order-1", blockingHandler(release, &calls))
+	if calls.Load() != 2 {
+		t.Errorf("calls after the shared execution, expected 2, got %d", calls.Load())
+	}
+}
+
+func TestGroupKeys(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	group, err := coalesce.NewGroup(breaker)
+	if err != nil {
+		t.Fatalf("coalesce.NewGroup, expected no err, got %s", err)
+	}
+
+	release := make(chan struct{})
+	close(release)
+	var calls atomic.Int32
+	group.Execute("order-1", func(name string) (*http.Response, error) {
+		return group.ExecuteContext(context.Background(), "order-2", blockingHandler(release, &calls))
+	})
+
+	if calls.Load() != 1 || group.Coalesced() != 0 {
+		t.Errorf("calls with different keys, expected them not to be coalesced, got %d coalesced", group.Coalesced())
+	}
+}
+
+func TestGroupCancel(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	group, err := coalesce.NewGroup(breaker)
+	if err != nil {
+		t.Fatalf("coalesce.NewGroup, expected no err, got %s", err)
+	}
+
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = group.ExecuteContext(ctx, "order-1", blockingHandler(make(chan struct{}), &calls))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("group.ExecuteContext, expected context.Canceled, got %v", err)
+	}
+
+	// the abandoned execution is cancelled and forgotten
+	release := make(chan struct{})
+	close(release)
+	resp, err := group.ExecuteContext(context.Background(), "order-1", blockingHandler(release, &calls))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("group.ExecuteContext after the cancellation, expected a new execution, got %v", err)
+	}
+}
+
+func TestGroupMaxBodySize(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	_, err := coalesce.NewGroup(breaker, coalesce.WithMaxBodySize(-1))
+	if expectedErr := (coalesce.ErrInvalidOption{Option: "MaxBodySize", Val: int64(-1)}); err != expectedErr {
+		t.Errorf("coalesce.NewGroup with a negative max body size, expected %s, got %v", expectedErr, err)
+	}
+
+	group, err := coalesce.NewGroup(breaker, coalesce.WithMaxBodySize(4))
+	if err != nil {
+		t.Fatalf("coalesce.NewGroup, expected no err, got %s", err)
+	}
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	errs := make([]error, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := group.ExecuteContext(context.Background(), "order-1", func(ctx context.Context, name string) (*http.Response, error) {
+				calls.Add(1)
+				<-release
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("paid in full"))}, nil
+			})
+			if errs[i] = err; err == nil {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				bodies[i] = string(body)
+			}
+		}()
+	}
+
+	for group.Coalesced() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	streamed, tooLarge := 0, 0
+	for i := range bodies {
+		var errTooLarge coalesce.ErrBodyTooLarge
+		switch {
+		case errs[i] == nil && bodies[i] == "paid in full":
+			streamed++
+		case errors.As(errs[i], &errTooLarge):
+			tooLarge++
+		default:
+			t.Errorf("caller %d, expected the whole body or ErrBodyTooLarge, got %q and %v", i, bodies[i], errs[i])
+		}
+	}
+	if streamed != 1 || tooLarge != 2 {
+		t.Errorf("callers, expected 1 to get the body and 2 ErrBodyTooLarge, got %d and %d", streamed, tooLarge)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls, expected a single execution, got %d", calls.Load())
+	}
+}
+
+// failingReader returns part of a body and then fails
+type failingReader struct {
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, "pa"), nil
+}
+
+func TestGroupTruncatedBody(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	group, err := coalesce.NewGroup(breaker)
+	if err != nil {
+		t.Fatalf("coalesce.NewGroup, expected no err, got %s", err)
+	}
+
+	failed := errors.New("failed")
+	resp, err := group.ExecuteContext(context.Background(), "order-1", func(ctx context.Context, name string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(&failingReader{})}, failed
+	})
+	if resp != nil || !errors.Is(err, failed) {
+		t.Errorf("group.ExecuteContext with a body cut short, expected no response and the call error, got %v and %v", resp, err)
+	}
+}
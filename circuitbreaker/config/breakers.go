@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Keys of a breaker definition, as written in files
+const (
+	KeyName                  = "name"
+	KeyFailureRate           = "failure_rate"
+	KeyRecoveryRate          = "recovery_rate"
+	KeyCooldown              = "cooldown"
+	KeyMaxRequestsOnHalfOpen = "max_requests_on_half_open"
+	KeyMinRequests           = "min_requests"
+	KeyGaugeType             = "gauge.type"
+	KeyGaugeSize             = "gauge.size"
+	KeyFailureStatuses       = "classify.failure_statuses"
+	KeyIgnoredStatuses       = "classify.ignored_statuses"
+)
+
+// settingKeys maps the parameters reported by circuitbreaker.Settings.Validate to their key
+var settingKeys = map[string]string{
+	"FailureRate":          KeyFailureRate,
+	"RecoveryRate":         KeyRecoveryRate,
+	"CooldownDuration":     KeyCooldown,
+	"MaxRequestOnHalfOpen": KeyMaxRequestsOnHalfOpen,
+}
+
+// LoadBreakers loads the file, applies the environment overrides and creates the breakers keyed by name, opts
+// are applied to every breaker after its definition, for instance to set a logger
+func LoadBreakers(path string, opts ...circuitbreaker.SettingsOption) (map[string]*circuitbreaker.Breaker, error) {
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg.NewBreakers(opts...)
+}
+
+// Validate checks every breaker, all the errors found are returned joined
+func (c *Config) Validate() error {
+	var errs []error
+	names := map[string]bool{}
+	for i, breaker := range c.Breakers {
+		if breaker.Name == "" {
+			errs = append(errs, ErrInvalidKey{Key: fmt.Sprintf("breakers[%d].%s", i, KeyName), Err: ErrMissingName})
+			continue
+		}
+		if names[breaker.Name] {
+			errs = append(errs, ErrInvalidKey{Key: fmt.Sprintf("breakers[%d].%s", i, KeyName), Err: ErrDuplicateName})
+			continue
+		}
+		names[breaker.Name] = true
+
+		if _, err := breaker.Settings(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewBreakers validates the configuration and creates its breakers keyed by name
+func (c *Config) NewBreakers(opts ...circuitbreaker.SettingsOption) (map[string]*circuitbreaker.Breaker, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	breakers := make(map[string]*circuitbreaker.Breaker, len(c.Breakers))
+	for _, definition := range c.Breakers {
+		settings, err := definition.Settings(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		breaker, err := circuitbreaker.NewBreakerWithSettings(settings)
+		if err != nil {
+			return nil, err
+		}
+		breakers[definition.Name] = breaker
+	}
+	return breakers, nil
+}
+
+// Settings builds and validates the settings of the breaker, opts are applied after the definition
+func (b Breaker) Settings(opts ...circuitbreaker.SettingsOption) (*circuitbreaker.Settings, error) {
+	options, err := b.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := circuitbreaker.NewSettings(b.Name, append(options, opts...)...)
+	var invalid circuitbreaker.ErrInvalidSettingParam
+	if errors.As(err, &invalid) {
+		if key, ok := settingKeys[invalid.Param]; ok {
+			return nil, ErrInvalidKey{Key: b.key(key), Err: err}
+		}
+	}
+	return settings, err
+}
+
+// Options turns the definition into settings options, it only checks that values can be parsed, Settings also
+// checks their ranges
+func (b Breaker) Options() ([]circuitbreaker.SettingsOption, error) {
+	var opts []circuitbreaker.SettingsOption
+
+	if b.FailureRate != nil {
+		opts = append(opts, circuitbreaker.WithFailureRate(*b.FailureRate))
+	}
+	if b.RecoveryRate != nil {
+		opts = append(opts, circuitbreaker.WithRecoveryRate(*b.RecoveryRate))
+	}
+	if b.Cooldown != "" {
+		cooldown, err := time.ParseDuration(b.Cooldown)
+		if err != nil {
+			return nil, ErrInvalidKey{Key: b.key(KeyCooldown), Err: err}
+		}
+		opts = append(opts, circuitbreaker.WithCooldownDuration(cooldown))
+	}
+	if b.MaxRequestsOnHalfOpen != nil {
+		opts = append(opts, circuitbreaker.WithMaxRequestOnHalfOpen(*b.MaxRequestsOnHalfOpen))
+	}
+	if b.MinRequests != nil {
+		if *b.MinRequests < 0 {
+			return nil, ErrInvalidKey{Key: b.key(KeyMinRequests), Err: fmt.Errorf("must not be negative, got %d", *b.MinRequests)}
+		}
+		opts = append(opts, circuitbreaker.WithMinRequest(*b.MinRequests))
+	}
+
+	gauge, err := b.gauge()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, circuitbreaker.WithGauge(gauge))
+
+	classifyOpts, err := b.classify()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, classifyOpts...), nil
+}
+
+func (b Breaker) gauge() (gauges.Gauge, error) {
+	size := circuitbreaker.DefaultWindowSize
+	if b.Gauge.Size != nil {
+		if *b.Gauge.Size <= 0 {
+			return nil, ErrInvalidKey{Key: b.key(KeyGaugeSize), Err: fmt.Errorf("must be positive, got %d", *b.Gauge.Size)}
+		}
+		size = *b.Gauge.Size
+	}
+
+	switch b.Gauge.Type {
+	case "", FixedWindowGauge:
+		return gauges.NewFixedWindowGauge(size), nil
+	default:
+		return nil, ErrInvalidKey{Key: b.key(KeyGaugeType), Err: fmt.Errorf("%w %q", ErrUnknownGauge, b.Gauge.Type)}
+	}
+}
+
+// key returns the key of a field of the breaker, or the environment variable it was overridden with
+func (b Breaker) key(field string) string {
+	if variable, ok := b.env[field]; ok {
+		return variable
+	}
+	return fmt.Sprintf("breakers[%s].%s", b.Name, field)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// statusRange matches the statuses from min to max included
+type statusRange struct {
+	min, max int
+}
+
+type statuses []statusRange
+
+func (s statuses) match(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, r := range s {
+		if resp.StatusCode >= r.min && resp.StatusCode <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+func parseStatus(s string) (statusRange, error) {
+	s = strings.TrimSpace(s)
+
+	if len(s) == 3 && strings.HasSuffix(strings.ToLower(s), "xx") {
+		class, err := strconv.Atoi(s[:1])
+		if err != nil || class < 1 || class > 5 {
+			return statusRange{}, ErrInvalidStatus{Val: s}
+		}
+		return statusRange{min: class * 100, max: class*100 + 99}, nil
+	}
+
+	low, high, isRange := strings.Cut(s, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(low))
+	if err != nil {
+		return statusRange{}, ErrInvalidStatus{Val: s}
+	}
+
+	max := min
+	if isRange {
+		if max, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+			return statusRange{}, ErrInvalidStatus{Val: s}
+		}
+	}
+
+	if min < 100 || max > 599 || min > max {
+		return statusRange{}, ErrInvalidStatus{Val: s}
+	}
+	return statusRange{min: min, max: max}, nil
+}
+
+func (b Breaker) parseStatuses(key string, values []string) (statuses, error) {
+	parsed := make(statuses, 0, len(values))
+	for i, value := range values {
+		r, err := parseStatus(value)
+		if err != nil {
+			return nil, ErrInvalidKey{Key: fmt.Sprintf("%s[%d]", b.key(key), i), Err: err}
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// classify turns the rules into the IsSuccessful and IsIgnored handlers, the defaults of the breaker are kept
+// when no rule is given
+func (b Breaker) classify() ([]circuitbreaker.SettingsOption, error) {
+	var opts []circuitbreaker.SettingsOption
+
+	failures, err := b.parseStatuses(KeyFailureStatuses, b.Classify.FailureStatuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(failures) > 0 {
+		opts = append(opts, circuitbreaker.WithIsSuccessfulHandler(func(resp *http.Response, err error) bool {
+			return err == nil && !failures.match(resp)
+		}))
+	}
+
+	ignored, err := b.parseStatuses(KeyIgnoredStatuses, b.Classify.IgnoredStatuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(ignored) > 0 || b.Classify.IgnoreCanceled {
+		ignoreCanceled := b.Classify.IgnoreCanceled
+		opts = append(opts, circuitbreaker.WithIsIgnoredHandler(func(resp *http.Response, err error) bool {
+			if ignoreCanceled && errors.Is(err, context.Canceled) {
+				return true
+			}
+			return err == nil && ignored.match(resp)
+		}))
+	}
+	return opts, nil
+}
@@ -0,0 +1,160 @@
+// Package config defines breakers in YAML, JSON or TOML files, with environment variables overriding the values
+// of the file, and turns them into ready to use breakers.
+//
+// A file lists the breakers with their thresholds, gauge and classification rules, any key left out keeps the
+// default of the circuitbreaker package:
+//
+//	breakers:
+//	  - name: Orders.Payments
+//	    failure_rate: 20
+//	    recovery_rate: 50
+//	    cooldown: 30s
+//	    max_requests_on_half_open: 10
+//	    min_requests: 20
+//	    gauge:
+//	      type: fixed-window
+//	      size: 100
+//	    classify:
+//	      failure_statuses: ["5xx", "429"]
+//	      ignored_statuses: ["404"]
+//	      ignore_canceled: true
+//
+// Errors point to the offending key, such as breakers[Orders.Payments].cooldown, or to the environment variable
+// that overrode it.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format of a configuration file
+type Format string
+
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+	TOML Format = "toml"
+)
+
+// Gauge types
+const (
+	FixedWindowGauge = "fixed-window"
+)
+
+// Config is the content of a configuration file
+type Config struct {
+	Breakers []Breaker `json:"breakers" yaml:"breakers" toml:"breakers"`
+}
+
+// Breaker defines a breaker, nil and empty values keep the defaults of the circuitbreaker package
+type Breaker struct {
+	Name                  string   `json:"name" yaml:"name" toml:"name"`
+	FailureRate           *float64 `json:"failure_rate,omitempty" yaml:"failure_rate" toml:"failure_rate"`
+	RecoveryRate          *float64 `json:"recovery_rate,omitempty" yaml:"recovery_rate" toml:"recovery_rate"`
+	Cooldown              string   `json:"cooldown,omitempty" yaml:"cooldown" toml:"cooldown"`
+	MaxRequestsOnHalfOpen *int     `json:"max_requests_on_half_open,omitempty" yaml:"max_requests_on_half_open" toml:"max_requests_on_half_open"`
+	MinRequests           *int     `json:"min_requests,omitempty" yaml:"min_requests" toml:"min_requests"`
+	Gauge                 Gauge    `json:"gauge" yaml:"gauge" toml:"gauge"`
+	Classify              Classify `json:"classify" yaml:"classify" toml:"classify"`
+
+	// env maps the keys overridden by ApplyEnv to their variable, so that errors point to the variable
+	env map[string]string
+}
+
+// Gauge selects the gauge of a breaker, FixedWindowGauge of circuitbreaker.DefaultWindowSize by default
+type Gauge struct {
+	Type string `json:"type,omitempty" yaml:"type" toml:"type"`
+	Size *int   `json:"size,omitempty" yaml:"size" toml:"size"`
+}
+
+// equal reports whether both definitions select the same gauge
+func (g Gauge) equal(other Gauge) bool {
+	if g.Type != other.Type || (g.Size == nil) != (other.Size == nil) {
+		return false
+	}
+	return g.Size == nil || *g.Size == *other.Size
+}
+
+// Classify holds the rules deciding the outcome of calls. Statuses are given as exact codes ("429"), classes
+// ("5xx") or ranges ("500-504"). Errors returned by the handler are always failures.
+type Classify struct {
+	// FailureStatuses are the response statuses counted as failures
+	FailureStatuses []string `json:"failure_statuses,omitempty" yaml:"failure_statuses" toml:"failure_statuses"`
+	// IgnoredStatuses are the response statuses left out of the gauge
+	IgnoredStatuses []string `json:"ignored_statuses,omitempty" yaml:"ignored_statuses" toml:"ignored_statuses"`
+	// IgnoreCanceled leaves calls cancelled by the caller out of the gauge
+	IgnoreCanceled bool `json:"ignore_canceled,omitempty" yaml:"ignore_canceled" toml:"ignore_canceled"`
+}
+
+// Load reads a configuration file, its format is given by its extension
+func Load(path string) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes a configuration, unknown keys are rejected so that typos do not go unnoticed
+func Parse(data []byte, format Format) (*Config, error) {
+	cfg := &Config{}
+
+	switch format {
+	case YAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case JSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, err
+		}
+	case TOML:
+		metadata, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return nil, err
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return nil, ErrInvalidKey{Key: undecoded[0].String(), Err: ErrUnknownKey}
+		}
+	default:
+		return nil, ErrUnknownFormat{Format: string(format)}
+	}
+	return cfg, nil
+}
+
+func formatOf(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".json":
+		return JSON, nil
+	case ".toml":
+		return TOML, nil
+	default:
+		return "", ErrUnknownFormat{Format: ext}
+	}
+}
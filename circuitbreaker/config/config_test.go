@@ -0,0 +1,377 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/config"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+const yamlConfig = `
+breakers:
+  - name: Orders.Payments
+    failure_rate: 20
+    recovery_rate: 50
+    cooldown: 45s
+    max_requests_on_half_open: 5
+    min_requests: 30
+    gauge:
+      type: fixed-window
+      size: 50
+    classify:
+      failure_statuses: ["5xx", "429"]
+      ignored_statuses: ["404"]
+      ignore_canceled: true
+  - name: search
+`
+
+const jsonConfig = `{
+  "breakers": [
+    {
+      "name": "Orders.Payments",
+      "failure_rate": 20,
+      "recovery_rate": 50,
+      "cooldown": "45s",
+      "max_requests_on_half_open": 5,
+      "min_requests": 30,
+      "gauge": {"type": "fixed-window", "size": 50},
+      "classify": {"failure_statuses": ["5xx", "429"], "ignored_statuses": ["404"], "ignore_canceled": true}
+    },
+    {"name": "search"}
+  ]
+}`
+
+const tomlConfig = `
+[[breakers]]
+name = "Orders.Payments"
+failure_rate = 20.0
+recovery_rate = 50.0
+cooldown = "45s"
+max_requests_on_half_open = 5
+min_requests = 30
+
+[breakers.gauge]
+type = "fixed-window"
+size = 50
+
+[breakers.classify]
+failure_statuses = ["5xx", "429"]
+ignored_statuses = ["404"]
+ignore_canceled = true
+
+[[breakers]]
+name = "search"
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("os.WriteFile, expected no err, got %s", err)
+	}
+	return path
+}
+
+func noEnv(string) (string, bool) {
+	return "", false
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func response(status int) *http.Response {
+	return &http.Response{StatusCode: status}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"breakers.yaml": yamlConfig,
+		"breakers.json": jsonConfig,
+		"breakers.toml": tomlConfig,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := config.Load(writeFile(t, name, content))
+			if err != nil {
+				t.Fatalf("config.Load, expected no err, got %s", err)
+			}
+
+			breakers, err := cfg.NewBreakers()
+			if err != nil {
+				t.Fatalf("Config.NewBreakers, expected no err, got %s", err)
+			}
+			if len(breakers) != 2 {
+				t.Fatalf("Config.NewBreakers, expected 2 breakers, got %d", len(breakers))
+			}
+
+			thresholds := breakers["Orders.Payments"].Settings.Thresholds
+			if thresholds.FailureRate != 20 || thresholds.RecoveryRate != 50 {
+				t.Errorf("rates, expected 20 and 50, got %v and %v", thresholds.FailureRate, thresholds.RecoveryRate)
+			}
+			if thresholds.CooldownDuration != 45*time.Second {
+				t.Errorf("cooldown, expected 45s, got %s", thresholds.CooldownDuration)
+			}
+			if thresholds.MaxRequestOnHalfOpen != 5 || thresholds.MinRequests != 30 {
+				t.Errorf("requests, expected 5 and 30, got %d and %d", thresholds.MaxRequestOnHalfOpen, thresholds.MinRequests)
+			}
+			if snapshot, _ := gauges.LocalSnapshot(breakers["Orders.Payments"].Settings.Gauge); len(snapshot.Measurements) != 50 {
+				t.Errorf("gauge size, expected 50, got %d", len(snapshot.Measurements))
+			}
+
+			defaults := breakers["search"].Settings.Thresholds
+			if defaults.CooldownDuration != 30*time.Second || defaults.FailureRate != 10 {
+				t.Errorf("defaults, expected 30s and 10, got %s and %v", defaults.CooldownDuration, defaults.FailureRate)
+			}
+		})
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	files := map[string]string{
+		"breakers.yaml": "breakers:\n  - name: search\n    coldown: 10s\n",
+		"breakers.json": `{"breakers": [{"name": "search", "coldown": "10s"}]}`,
+		"breakers.toml": "[[breakers]]\nname = \"search\"\ncoldown = \"10s\"\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.Load(writeFile(t, name, content))
+			if err == nil || !strings.Contains(err.Error(), "coldown") {
+				t.Errorf("config.Load, expected an error naming coldown, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := config.Load(writeFile(t, "breakers.ini", ""))
+
+	var unknown config.ErrUnknownFormat
+	if !errors.As(err, &unknown) || unknown.Format != ".ini" {
+		t.Errorf("config.Load, expected ErrUnknownFormat for .ini, got %v", err)
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		key     string
+		err     error
+	}{
+		"cooldown": {
+			content: "breakers:\n  - name: search\n    cooldown: soon\n",
+			key:     "breakers[search].cooldown",
+		},
+		"failure rate": {
+			content: "breakers:\n  - name: search\n    failure_rate: 150\n",
+			key:     "breakers[search].failure_rate",
+		},
+		"gauge type": {
+			content: "breakers:\n  - name: search\n    gauge:\n      type: sliding\n",
+			key:     "breakers[search].gauge.type",
+			err:     config.ErrUnknownGauge,
+		},
+		"gauge size": {
+			content: "breakers:\n  - name: search\n    gauge:\n      size: 0\n",
+			key:     "breakers[search].gauge.size",
+		},
+		"status": {
+			content: "breakers:\n  - name: search\n    classify:\n      failure_statuses: [\"5xx\", \"6xx\"]\n",
+			key:     "breakers[search].classify.failure_statuses[1]",
+		},
+		"missing name": {
+			content: "breakers:\n  - cooldown: 10s\n",
+			key:     "breakers[0].name",
+			err:     config.ErrMissingName,
+		},
+		"duplicate name": {
+			content: "breakers:\n  - name: search\n  - name: search\n",
+			key:     "breakers[1].name",
+			err:     config.ErrDuplicateName,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := config.Parse([]byte(tc.content), config.YAML)
+			if err != nil {
+				t.Fatalf("config.Parse, expected no err, got %s", err)
+			}
+
+			_, err = cfg.NewBreakers()
+			var invalid config.ErrInvalidKey
+			if !errors.As(err, &invalid) || invalid.Key != tc.key {
+				t.Fatalf("Config.NewBreakers, expected ErrInvalidKey for %s, got %v", tc.key, err)
+			}
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("Config.NewBreakers, expected %s, got %s", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg, err := config.Parse([]byte(yamlConfig), config.YAML)
+	if err != nil {
+		t.Fatalf("config.Parse, expected no err, got %s", err)
+	}
+
+	err = cfg.ApplyEnv(env(map[string]string{
+		"CIRCUITBREAKER_ORDERS_PAYMENTS_COOLDOWN":     "2m",
+		"CIRCUITBREAKER_ORDERS_PAYMENTS_FAILURE_RATE": "35.5",
+		"CIRCUITBREAKER_SEARCH_MIN_REQUESTS":          "5",
+	}))
+	if err != nil {
+		t.Fatalf("Config.ApplyEnv, expected no err, got %s", err)
+	}
+
+	breakers, err := cfg.NewBreakers()
+	if err != nil {
+		t.Fatalf("Config.NewBreakers, expected no err, got %s", err)
+	}
+
+	payments := breakers["Orders.Payments"].Settings.Thresholds
+	if payments.CooldownDuration != 2*time.Minute || payments.FailureRate != 35.5 {
+		t.Errorf("overrides, expected 2m and 35.5, got %s and %v", payments.CooldownDuration, payments.FailureRate)
+	}
+	if payments.RecoveryRate != 50 {
+		t.Errorf("file value, expected 50, got %v", payments.RecoveryRate)
+	}
+	if min := breakers["search"].Settings.Thresholds.MinRequests; min != 5 {
+		t.Errorf("override, expected 5, got %d", min)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	cfg, _ := config.Parse([]byte(yamlConfig), config.YAML)
+
+	err := cfg.ApplyEnv(env(map[string]string{"CIRCUITBREAKER_SEARCH_GAUGE_SIZE": "large"}))
+	var invalid config.ErrInvalidKey
+	if !errors.As(err, &invalid) || invalid.Key != "CIRCUITBREAKER_SEARCH_GAUGE_SIZE" {
+		t.Errorf("Config.ApplyEnv, expected ErrInvalidKey for the variable, got %v", err)
+	}
+
+	// an explicit size of 0 is rejected the same way as in the file
+	cfg, _ = config.Parse([]byte(yamlConfig), config.YAML)
+	if err := cfg.ApplyEnv(env(map[string]string{"CIRCUITBREAKER_SEARCH_GAUGE_SIZE": "0"})); err != nil {
+		t.Fatalf("Config.ApplyEnv, expected no err, got %s", err)
+	}
+	if _, err := cfg.NewBreakers(); !errors.As(err, &invalid) || invalid.Key != "CIRCUITBREAKER_SEARCH_GAUGE_SIZE" {
+		t.Errorf("Config.NewBreakers, expected ErrInvalidKey for the variable, got %v", err)
+	}
+
+	cfg, _ = config.Parse([]byte(yamlConfig), config.YAML)
+	if err := cfg.ApplyEnv(env(map[string]string{"CIRCUITBREAKER_SEARCH_RECOVERY_RATE": "0"})); err != nil {
+		t.Fatalf("Config.ApplyEnv, expected no err, got %s", err)
+	}
+
+	_, err = cfg.NewBreakers()
+	if !errors.As(err, &invalid) || invalid.Key != "CIRCUITBREAKER_SEARCH_RECOVERY_RATE" {
+		t.Errorf("Config.NewBreakers, expected ErrInvalidKey for the variable, got %v", err)
+	}
+}
+
+func TestApplyEnvNameCollision(t *testing.T) {
+	content := "breakers:\n  - name: orders.payments\n  - name: orders-payments\n"
+	cfg, err := config.Parse([]byte(content), config.YAML)
+	if err != nil {
+		t.Fatalf("config.Parse, expected no err, got %s", err)
+	}
+
+	err = cfg.ApplyEnv(noEnv)
+	var invalid config.ErrInvalidKey
+	if !errors.As(err, &invalid) || invalid.Key != "breakers[1].name" || !errors.Is(err, config.ErrEnvNameCollision) {
+		t.Errorf("Config.ApplyEnv, expected ErrEnvNameCollision for breakers[1].name, got %v", err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if name := config.EnvName("Orders.Payments-v2", config.KeyMaxRequestsOnHalfOpen); name != "CIRCUITBREAKER_ORDERS_PAYMENTS_V2_MAX_REQUESTS_ON_HALF_OPEN" {
+		t.Errorf("config.EnvName, expected CIRCUITBREAKER_ORDERS_PAYMENTS_V2_MAX_REQUESTS_ON_HALF_OPEN, got %s", name)
+	}
+	if name := config.EnvName("search", config.KeyFailureStatuses); name != "" {
+		t.Errorf("config.EnvName, expected no variable for statuses, got %s", name)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cfg, _ := config.Parse([]byte(yamlConfig), config.YAML)
+	if err := cfg.ApplyEnv(noEnv); err != nil {
+		t.Fatalf("Config.ApplyEnv, expected no err, got %s", err)
+	}
+
+	settings, err := cfg.Breakers[0].Settings()
+	if err != nil {
+		t.Fatalf("Breaker.Settings, expected no err, got %s", err)
+	}
+
+	successful := map[int]bool{200: true, 302: true, 404: true, 429: false, 500: false, 503: false}
+	for status, expected := range successful {
+		if got := settings.IsSuccessful(response(status), nil); got != expected {
+			t.Errorf("IsSuccessful(%d), expected %t, got %t", status, expected, got)
+		}
+	}
+	if settings.IsSuccessful(nil, fmt.Errorf("dial: %w", errors.New("refused"))) {
+		t.Errorf("IsSuccessful with an error, expected false, got true")
+	}
+
+	if !settings.IsIgnored(response(404), nil) {
+		t.Errorf("IsIgnored(404), expected true, got false")
+	}
+	if settings.IsIgnored(response(500), nil) {
+		t.Errorf("IsIgnored(500), expected false, got true")
+	}
+	if !settings.IsIgnored(nil, fmt.Errorf("call: %w", context.Canceled)) {
+		t.Errorf("IsIgnored with context.Canceled, expected true, got false")
+	}
+
+	defaults, _ := cfg.Breakers[1].Settings()
+	if defaults.IsIgnored != nil {
+		t.Errorf("IsIgnored without rules, expected nil, got a handler")
+	}
+	if !defaults.IsSuccessful(response(500), nil) {
+		t.Errorf("default IsSuccessful(500), expected true, got false")
+	}
+}
+
+func TestStatusRanges(t *testing.T) {
+	cfg, _ := config.Parse([]byte("breakers:\n  - name: search\n    classify:\n      failure_statuses: [\"500-504\"]\n"), config.YAML)
+
+	settings, err := cfg.Breakers[0].Settings()
+	if err != nil {
+		t.Fatalf("Breaker.Settings, expected no err, got %s", err)
+	}
+
+	for status, expected := range map[int]bool{499: true, 500: false, 504: false, 505: true} {
+		if got := settings.IsSuccessful(response(status), nil); got != expected {
+			t.Errorf("IsSuccessful(%d), expected %t, got %t", status, expected, got)
+		}
+	}
+}
+
+func TestLoadBreakers(t *testing.T) {
+	path := writeFile(t, "breakers.yaml", yamlConfig)
+	t.Setenv("CIRCUITBREAKER_SEARCH_COOLDOWN", "5s")
+
+	breakers, err := config.LoadBreakers(path)
+	if err != nil {
+		t.Fatalf("config.LoadBreakers, expected no err, got %s", err)
+	}
+	if cooldown := breakers["search"].Settings.Thresholds.CooldownDuration; cooldown != 5*time.Second {
+		t.Errorf("cooldown, expected 5s, got %s", cooldown)
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EnvPrefix starts the name of every environment variable read by ApplyEnv
+const EnvPrefix = "CIRCUITBREAKER"
+
+// envKeys maps the suffix of environment variables to the key they override
+var envKeys = []struct {
+	suffix string
+	key    string
+}{
+	{"FAILURE_RATE", KeyFailureRate},
+	{"RECOVERY_RATE", KeyRecoveryRate},
+	{"COOLDOWN", KeyCooldown},
+	{"MAX_REQUESTS_ON_HALF_OPEN", KeyMaxRequestsOnHalfOpen},
+	{"MIN_REQUESTS", KeyMinRequests},
+	{"GAUGE_TYPE", KeyGaugeType},
+	{"GAUGE_SIZE", KeyGaugeSize},
+}
+
+// EnvName returns the environment variable overriding a key of the named breaker, the name is upper cased and
+// every character other than a letter or a digit replaced with an underscore, for instance
+// CIRCUITBREAKER_ORDERS_PAYMENTS_COOLDOWN for the cooldown of Orders.Payments
+func EnvName(breaker, key string) string {
+	for _, k := range envKeys {
+		if k.key == key {
+			return EnvPrefix + "_" + envSegment(breaker) + "_" + k.suffix
+		}
+	}
+	return ""
+}
+
+func envSegment(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
+
+// ApplyEnv overrides the values of the breakers with the environment variables found by lookup, usually
+// os.LookupEnv. Values that cannot be parsed are reported with the name of their variable, and breakers whose
+// names map to the same variables with ErrEnvNameCollision, whether or not the variables are set.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	segments := map[string]string{}
+	for i, breaker := range c.Breakers {
+		if breaker.Name == "" {
+			continue
+		}
+		segment := envSegment(breaker.Name)
+		if other, ok := segments[segment]; ok && other != breaker.Name {
+			return ErrInvalidKey{Key: fmt.Sprintf("breakers[%d].%s", i, KeyName), Err: fmt.Errorf("%w %q", ErrEnvNameCollision, other)}
+		}
+		segments[segment] = breaker.Name
+	}
+
+	for i := range c.Breakers {
+		breaker := &c.Breakers[i]
+		for _, k := range envKeys {
+			variable := EnvName(breaker.Name, k.key)
+			value, ok := lookup(variable)
+			if !ok {
+				continue
+			}
+
+			if err := breaker.set(k.key, strings.TrimSpace(value)); err != nil {
+				return ErrInvalidKey{Key: variable, Err: err}
+			}
+			if breaker.env == nil {
+				breaker.env = map[string]string{}
+			}
+			breaker.env[k.key] = variable
+		}
+	}
+	return nil
+}
+
+func (b *Breaker) set(key, value string) error {
+	switch key {
+	case KeyFailureRate, KeyRecoveryRate:
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if key == KeyFailureRate {
+			b.FailureRate = &rate
+		} else {
+			b.RecoveryRate = &rate
+		}
+	case KeyCooldown:
+		if _, err := time.ParseDuration(value); err != nil {
+			return err
+		}
+		b.Cooldown = value
+	case KeyMaxRequestsOnHalfOpen, KeyMinRequests:
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if key == KeyMaxRequestsOnHalfOpen {
+			b.MaxRequestsOnHalfOpen = &count
+		} else {
+			b.MinRequests = &count
+		}
+	case KeyGaugeType:
+		b.Gauge.Type = value
+	case KeyGaugeSize:
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		b.Gauge.Size = &size
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKey gets wrapped in ErrInvalidKey for keys that do not configure anything
+var ErrUnknownKey = errors.New("unknown key")
+
+// ErrMissingName gets wrapped in ErrInvalidKey for breakers without a name
+var ErrMissingName = errors.New("a name is required")
+
+// ErrDuplicateName gets wrapped in ErrInvalidKey when two breakers share a name
+var ErrDuplicateName = errors.New("name is already used by another breaker")
+
+// ErrEnvNameCollision gets wrapped in ErrInvalidKey when two breakers with different names are overridden by the
+// same environment variables, such as orders.payments and orders-payments
+var ErrEnvNameCollision = errors.New("name maps to the same environment variables as breaker")
+
+// ErrUnknownGauge gets wrapped in ErrInvalidKey for gauge types that do not exist
+var ErrUnknownGauge = errors.New("unknown gauge type")
+
+// ErrInvalidKey points at the key of the configuration, or the environment variable, holding an invalid value
+type ErrInvalidKey struct {
+	Key string
+	Err error
+}
+
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Err)
+}
+
+func (e ErrInvalidKey) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnknownFormat gets returned for files whose format cannot be told from their extension
+type ErrUnknownFormat struct {
+	Format string
+}
+
+func (e ErrUnknownFormat) Error() string {
+	return fmt.Sprintf("unknown configuration format %q, expected yaml, json or toml", e.Format)
+}
+
+// ErrInvalidStatus gets wrapped in ErrInvalidKey for statuses that are neither a code, a class nor a range
+type ErrInvalidStatus struct {
+	Val string
+}
+
+func (e ErrInvalidStatus) Error() string {
+	return fmt.Sprintf("invalid status %q, expected a code such as 429, a class such as 5xx or a range such as 500-504", e.Val)
+}
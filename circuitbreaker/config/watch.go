@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// DefaultWatchInterval is how often a Watcher checks its file for changes
+const DefaultWatchInterval = 2 * time.Second
+
+// WatchOption configures a Watcher
+type WatchOption func(*Watcher)
+
+// WithWatchInterval sets how often the file is checked for changes
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithSettingsOptions sets options applied to every breaker after its definition, as with LoadBreakers
+func WithSettingsOptions(opts ...circuitbreaker.SettingsOption) WatchOption {
+	return func(w *Watcher) {
+		w.settingsOpts = append(w.settingsOpts, opts...)
+	}
+}
+
+// WithLogger logs reloads and the reloads that failed
+func WithLogger(logger *slog.Logger) WatchOption {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// WithOnReload is called back after every reload triggered by a change of the file, err is nil when the file
+// was applied
+func WithOnReload(handler func(err error)) WatchOption {
+	return func(w *Watcher) {
+		w.onReload = handler
+	}
+}
+
+// Watcher keeps the breakers defined in a file in sync with it. Changes to thresholds, gauges and
+// classification rules are applied to the running breakers with circuitbreaker.Breaker.UpdateSettings, while
+// breakers added to or removed from the file are only picked up on restart.
+type Watcher struct {
+	path         string
+	interval     time.Duration
+	settingsOpts []circuitbreaker.SettingsOption
+	logger       *slog.Logger
+	onReload     func(err error)
+
+	breakers map[string]*circuitbreaker.Breaker
+
+	mutex       sync.Mutex
+	definitions map[string]Breaker
+	modTime     time.Time
+	size        int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Watch loads the file and creates its breakers like LoadBreakers, then checks the file every interval and
+// applies its changes until the watcher is closed
+func Watch(path string, opts ...WatchOption) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		interval: DefaultWatchInterval,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	// the file is stat-ed first so that a change made while loading is picked up by the first check
+	if info, err := os.Stat(path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+
+	cfg, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	if w.breakers, err = cfg.NewBreakers(w.settingsOpts...); err != nil {
+		return nil, err
+	}
+	w.definitions = definitions(cfg)
+
+	w.wg.Add(1)
+	go w.watch()
+	return w, nil
+}
+
+// Breakers returns the breakers keyed by name, the map must not be modified
+func (w *Watcher) Breakers() map[string]*circuitbreaker.Breaker {
+	return w.breakers
+}
+
+// Reload reads the file and applies it to the breakers, nothing is applied when the file is invalid. A breaker
+// can still refuse its new settings when they do not fit the settings it was given outside of the file, the
+// breakers already updated are then rolled back to their previous settings.
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	cfg, err := w.load()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	// every breaker is checked before any of them is updated, so that a file is applied entirely or not at all
+	type update struct {
+		breaker  *circuitbreaker.Breaker
+		option   circuitbreaker.SettingsOption
+		rollback circuitbreaker.SettingsOption
+	}
+	var updates []update
+	var added []string
+	for _, definition := range cfg.Breakers {
+		breaker, ok := w.breakers[definition.Name]
+		if !ok {
+			added = append(added, definition.Name)
+			continue
+		}
+
+		previous := w.definitions[definition.Name]
+		if reflect.DeepEqual(previous, definition) {
+			continue
+		}
+
+		settings, err := definition.Settings(w.settingsOpts...)
+		if err != nil {
+			return err
+		}
+		replaceGauge := !previous.Gauge.equal(definition.Gauge)
+		updates = append(updates, update{
+			breaker:  breaker,
+			option:   reloaded(settings, replaceGauge),
+			rollback: reloaded(breaker.CurrentSettings(), replaceGauge),
+		})
+	}
+
+	for i, u := range updates {
+		if err := u.breaker.UpdateSettings(u.option); err != nil {
+			errs := []error{err}
+			for _, applied := range updates[:i] {
+				errs = append(errs, applied.breaker.UpdateSettings(applied.rollback))
+			}
+			return errors.Join(errs...)
+		}
+	}
+
+	defined := definitions(cfg)
+	var removed []string
+	for name := range w.breakers {
+		if _, ok := defined[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	// breakers that are gone from the file keep their last definition
+	for _, name := range removed {
+		defined[name] = w.definitions[name]
+	}
+	w.definitions = defined
+
+	w.logReload(len(updates), added, removed)
+	return nil
+}
+
+// Close stops watching the file, the breakers keep their current settings
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+func (w *Watcher) load() (*Config, error) {
+	cfg, err := Load(w.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (w *Watcher) watch() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				err := w.Reload()
+				if err != nil {
+					w.logReloadError(err)
+				}
+				if w.onReload != nil {
+					w.onReload(err)
+				}
+			}
+		}
+	}
+}
+
+// changed reports whether the file was modified since the last check, a file that cannot be read is reported
+// once, when it starts failing
+func (w *Watcher) changed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var modTime time.Time
+	var size int64 = -1
+	if info, err := os.Stat(w.path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	if modTime.Equal(w.modTime) && size == w.size {
+		return false
+	}
+	w.modTime, w.size = modTime, size
+	return true
+}
+
+func (w *Watcher) logReload(updated int, added, removed []string) {
+	if w.logger == nil {
+		return
+	}
+
+	w.logger.Info("circuit breaker configuration reloaded",
+		slog.String("path", w.path),
+		slog.Int("updated", updated),
+	)
+	if len(added) > 0 || len(removed) > 0 {
+		w.logger.Warn("circuit breakers added to or removed from the configuration are only applied on restart",
+			slog.String("path", w.path),
+			slog.Any("added", added),
+			slog.Any("removed", removed),
+		)
+	}
+}
+
+func (w *Watcher) logReloadError(err error) {
+	if w.logger == nil {
+		return
+	}
+
+	w.logger.Error("could not reload circuit breaker configuration",
+		slog.String("path", w.path),
+		slog.String("error", err.Error()),
+	)
+}
+
+// reloaded carries over the parts of the settings that are defined in files, the gauge is only replaced when
+// its definition changed so that it keeps its readings otherwise
+func reloaded(settings *circuitbreaker.Settings, replaceGauge bool) circuitbreaker.SettingsOption {
+	return func(s *circuitbreaker.Settings) {
+		s.Thresholds = settings.Thresholds
+		s.IsSuccessful = settings.IsSuccessful
+		s.IsIgnored = settings.IsIgnored
+		if replaceGauge {
+			s.Gauge = settings.Gauge
+		}
+	}
+}
+
+func definitions(cfg *Config) map[string]Breaker {
+	defined := make(map[string]Breaker, len(cfg.Breakers))
+	for _, definition := range cfg.Breakers {
+		defined[definition.Name] = definition
+	}
+	return defined
+}
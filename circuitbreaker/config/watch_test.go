@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/config"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+const watchedConfig = `
+breakers:
+  - name: search
+    failure_rate: 20
+    cooldown: 10s
+`
+
+// rewrite replaces the file and moves its modification time forward, so that the change is noticed even on file
+// systems with a coarse time resolution
+func rewrite(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("os.WriteFile, expected no err, got %s", err)
+	}
+	modTime := time.Now().Add(age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("os.Chtimes, expected no err, got %s", err)
+	}
+}
+
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(time.Second):
+		t.Fatalf("watcher, expected a reload, got none")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "breakers.yaml", watchedConfig)
+	reloads := make(chan error, 10)
+	watcher, err := config.Watch(path,
+		config.WithWatchInterval(10*time.Millisecond),
+		config.WithOnReload(func(err error) { reloads <- err }),
+	)
+	if err != nil {
+		t.Fatalf("config.Watch, expected no err, got %s", err)
+	}
+	defer watcher.Close()
+
+	breaker := watcher.Breakers()["search"]
+	if rate := breaker.CurrentSettings().Thresholds.FailureRate; rate != 20 {
+		t.Fatalf("failure rate, expected 20, got %v", rate)
+	}
+
+	t.Run("Change", func(t *testing.T) {
+		rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 35\n", time.Second)
+		if err := waitReload(t, reloads); err != nil {
+			t.Fatalf("reload, expected no err, got %s", err)
+		}
+
+		thresholds := breaker.CurrentSettings().Thresholds
+		if thresholds.FailureRate != 35 {
+			t.Errorf("failure rate, expected 35, got %v", thresholds.FailureRate)
+		}
+		if thresholds.CooldownDuration != circuitbreaker.DefaultCooldownDuration {
+			t.Errorf("removed cooldown, expected the default, got %s", thresholds.CooldownDuration)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 35\n    cooldown: soon\n", 2*time.Second)
+
+		var invalid config.ErrInvalidKey
+		if err := waitReload(t, reloads); !errors.As(err, &invalid) || invalid.Key != "breakers[search].cooldown" {
+			t.Errorf("reload, expected ErrInvalidKey for breakers[search].cooldown, got %v", err)
+		}
+		if cooldown := breaker.CurrentSettings().Thresholds.CooldownDuration; cooldown != circuitbreaker.DefaultCooldownDuration {
+			t.Errorf("cooldown after an invalid reload, expected the default, got %s", cooldown)
+		}
+	})
+
+	t.Run("Gauge", func(t *testing.T) {
+		breaker.Execute(func(name string) (*http.Response, error) { return nil, errors.New("down") })
+		gauge := breaker.CurrentSettings().Gauge
+
+		rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 50\n", 3*time.Second)
+		if err := waitReload(t, reloads); err != nil {
+			t.Fatalf("reload, expected no err, got %s", err)
+		}
+		if breaker.CurrentSettings().Gauge != gauge {
+			t.Errorf("gauge, expected to be kept when its definition did not change")
+		}
+
+		rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 50\n    gauge:\n      size: 20\n", 4*time.Second)
+		if err := waitReload(t, reloads); err != nil {
+			t.Fatalf("reload, expected no err, got %s", err)
+		}
+		if snapshot, _ := gauges.LocalSnapshot(breaker.CurrentSettings().Gauge); len(snapshot.Measurements) != 20 {
+			t.Errorf("gauge, expected a new gauge of 20 measurements")
+		}
+		if failures := breaker.Aggregate().FailureCount; failures != 1 {
+			t.Errorf("new gauge, expected the previous failure, got %d failures", failures)
+		}
+
+		gauge = breaker.CurrentSettings().Gauge
+		rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 40\n    gauge:\n      size: 20\n", 5*time.Second)
+		if err := waitReload(t, reloads); err != nil {
+			t.Fatalf("reload, expected no err, got %s", err)
+		}
+		if breaker.CurrentSettings().Gauge != gauge {
+			t.Errorf("gauge, expected to be kept when its size did not change")
+		}
+	})
+}
+
+func TestWatchRollback(t *testing.T) {
+	path := writeFile(t, "breakers.yaml", "breakers:\n  - name: search\n    failure_rate: 20\n  - name: payments\n    failure_rate: 20\n")
+	watcher, err := config.Watch(path, config.WithWatchInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("config.Watch, expected no err, got %s", err)
+	}
+	defer watcher.Close()
+
+	search, payments := watcher.Breakers()["search"], watcher.Breakers()["payments"]
+	if err := payments.UpdateSettings(circuitbreaker.WithShedding(circuitbreaker.Shedding{WarningFailureRate: 15})); err != nil {
+		t.Fatalf("payments.UpdateSettings, expected no err, got %s", err)
+	}
+
+	// the file is valid but payments refuses a failure rate under its warning rate, search was updated first
+	rewrite(t, path, "breakers:\n  - name: search\n    failure_rate: 30\n  - name: payments\n    failure_rate: 10\n", time.Second)
+	err = watcher.Reload()
+	expectedErr := circuitbreaker.ErrInvalidSettingParam{Param: "Shedding.WarningFailureRate", Val: 15.0}
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("watcher.Reload, expected %s, got %v", expectedErr, err)
+	}
+	if rate := search.CurrentSettings().Thresholds.FailureRate; rate != 20 {
+		t.Errorf("search failure rate, expected to be rolled back to 20, got %v", rate)
+	}
+	if rate := payments.CurrentSettings().Thresholds.FailureRate; rate != 20 {
+		t.Errorf("payments failure rate, expected 20, got %v", rate)
+	}
+}
+
+func TestWatchEnv(t *testing.T) {
+	path := writeFile(t, "breakers.yaml", watchedConfig)
+	watcher, err := config.Watch(path, config.WithWatchInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("config.Watch, expected no err, got %s", err)
+	}
+	defer watcher.Close()
+
+	t.Setenv("CIRCUITBREAKER_SEARCH_MIN_REQUESTS", "50")
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Watcher.Reload, expected no err, got %s", err)
+	}
+	if min := watcher.Breakers()["search"].CurrentSettings().Thresholds.MinRequests; min != 50 {
+		t.Errorf("min requests, expected 50, got %d", min)
+	}
+}
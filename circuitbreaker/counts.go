@@ -0,0 +1,149 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Transition describes a move of the circuit breaker from one state to another
+type Transition struct {
+	From State
+	To   State
+}
+
+// Counts holds cumulative totals of the calls made through a breaker since it was created. Unlike the gauge
+// they are never reset, which makes them suitable for exporting as monotonic counters.
+type Counts struct {
+	// Successes are calls the IsSuccessful handler accepted
+	Successes uint64
+	// Failures are calls the IsSuccessful handler rejected, excluding timeouts
+	Failures uint64
+	// Timeouts are failed calls whose error was context.DeadlineExceeded
+	Timeouts uint64
+	// Ignored are calls whose outcome was not recorded because of the IsIgnored handler
+	Ignored uint64
+	// Rejections are calls that were not executed because the breaker did not permit them
+	Rejections uint64
+	// BulkheadRejections are calls permitted by the breaker that were not executed because its bulkhead was full
+	BulkheadRejections uint64
+	// LimitRejections are calls that were not executed because the Limiter of the breaker was at its limit
+	LimitRejections uint64
+	// Throttled are calls that were not executed because the Throttle of the breaker rejected them
+	Throttled uint64
+	// RateLimited are calls that were not executed because the RateLimit of the breaker was reached
+	RateLimited uint64
+	// Shed are calls that were not executed because the Shedding of the breaker rejected their criticality
+	Shed uint64
+	// Retries are attempts made by the Retry policy of the breaker after a failed attempt, each attempt is also
+	// counted by its outcome
+	Retries uint64
+	// RetriesDenied are retries that were not attempted because the RetryBudget of the breaker was exhausted
+	RetriesDenied uint64
+	// Hedges are attempts started by the Hedge of the breaker on top of a slow attempt
+	Hedges uint64
+	// Transitions counts every state change keyed by its source and target state
+	Transitions map[Transition]uint64
+}
+
+// counter keeps the running Counts, it has its own lock so recording never waits on the breaker lock
+type counter struct {
+	mutex  sync.Mutex
+	counts Counts
+}
+
+func newCounter() *counter {
+	return &counter{counts: Counts{Transitions: map[Transition]uint64{}}}
+}
+
+func (c *counter) recordCall(successful bool, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch {
+	case successful:
+		c.counts.Successes++
+	case errors.Is(err, context.DeadlineExceeded):
+		c.counts.Timeouts++
+	default:
+		c.counts.Failures++
+	}
+}
+
+func (c *counter) recordIgnored() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Ignored++
+}
+
+func (c *counter) recordRejection() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Rejections++
+}
+
+func (c *counter) recordBulkheadRejection() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.BulkheadRejections++
+}
+
+func (c *counter) recordLimitRejection() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.LimitRejections++
+}
+
+func (c *counter) recordThrottled() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Throttled++
+}
+
+func (c *counter) recordRetry() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Retries++
+}
+
+func (c *counter) recordRetryDenied() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.RetriesDenied++
+}
+
+func (c *counter) recordHedge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Hedges++
+}
+
+func (c *counter) recordRateLimited() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.RateLimited++
+}
+
+func (c *counter) recordShed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Shed++
+}
+
+func (c *counter) recordTransition(from, to State) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts.Transitions[Transition{From: from, To: to}]++
+}
+
+func (c *counter) snapshot() Counts {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counts := c.counts
+	counts.Transitions = make(map[Transition]uint64, len(c.counts.Transitions))
+	for transition, count := range c.counts.Transitions {
+		counts.Transitions[transition] = count
+	}
+	return counts
+}
@@ -0,0 +1,290 @@
+// Package dashboard serves a self-contained HTML status page for a set of breakers, in the spirit of the Hystrix
+// dashboard. The page and its assets are embedded in the binary, it polls the JSON endpoints below, relative to
+// where the handler is mounted:
+//
+//	GET  /                          the status page
+//	GET  /api/breakers              status of every breaker along with its recent transitions
+//	POST /api/breakers/{name}/force force a state, body admin.ForceRequest
+//	POST /api/breakers/{name}/reset reset the breaker, optional body admin.ResetRequest
+//
+// Mount it behind a trailing slash, for example with http.StripPrefix("/dashboard", handler) on "/dashboard/",
+// so that the page resolves the endpoints relative to itself. The POST endpoints only accept application/json
+// requests, which a page of another site cannot send without the consent of the browser, and record the actions
+// in an audit log, see WithAudit.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+//go:embed static
+var static embed.FS
+
+const (
+	// DefaultTransitionHistory is the number of transitions kept per breaker
+	DefaultTransitionHistory = 10
+	// DefaultRefreshInterval is how often the page polls the status of the breakers
+	DefaultRefreshInterval = 2 * time.Second
+)
+
+// ErrNotJSON gets returned when a control request is not sent as application/json
+var ErrNotJSON = errors.New("control requests must be sent as application/json")
+
+// ErrInvalidTransitionHistory gets returned when the transition history is given a negative size
+type ErrInvalidTransitionHistory struct {
+	Size int
+}
+
+func (e ErrInvalidTransitionHistory) Error() string {
+	return fmt.Sprintf("transition history must not be negative, got %d", e.Size)
+}
+
+// Breaker is the status of a breaker shown on the page
+type Breaker struct {
+	admin.Breaker
+	// CooldownRemaining is the time left before an open breaker moves to half-open, in milliseconds
+	CooldownRemaining int64 `json:"cooldown_remaining_ms"`
+	// Transitions are the most recent transitions, newest first
+	Transitions []admin.Event `json:"transitions"`
+}
+
+// Option customises the handler
+type Option func(*Handler)
+
+// WithTransitionHistory sets how many transitions are kept per breaker, NewHandler returns
+// ErrInvalidTransitionHistory when it is negative
+func WithTransitionHistory(size int) Option {
+	return func(h *Handler) {
+		h.historySize = size
+	}
+}
+
+// WithRefreshInterval sets how often the page polls the status of the breakers
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.refreshInterval = interval
+	}
+}
+
+// auditor records the actions taken from the page, it is an *admin.AuditLog of the dashboard or the *admin.Handler
+// given to WithAudit
+type auditor interface {
+	Record(r *http.Request, entry admin.AuditEntry)
+	Audit() []admin.AuditEntry
+}
+
+// WithAudit records the actions taken from the page in the audit log of the admin handler, by default the
+// dashboard keeps an audit log of its own
+func WithAudit(handler *admin.Handler) Option {
+	return func(h *Handler) {
+		h.audit = handler
+	}
+}
+
+// Handler serves the dashboard. It subscribes to the breakers when created to record their transitions, Close
+// releases the subscriptions.
+type Handler struct {
+	breakers        map[string]*circuitbreaker.Breaker
+	subscriptions   []*circuitbreaker.Subscription
+	historySize     int
+	refreshInterval time.Duration
+	mux             *http.ServeMux
+	audit           auditor
+
+	mutex       sync.Mutex
+	transitions map[string][]admin.Event
+}
+
+var _ http.Handler = &Handler{}
+
+// NewHandler creates a dashboard for the breakers, their names must be unique
+func NewHandler(breakers []*circuitbreaker.Breaker, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		breakers:        map[string]*circuitbreaker.Breaker{},
+		historySize:     DefaultTransitionHistory,
+		refreshInterval: DefaultRefreshInterval,
+		mux:             http.NewServeMux(),
+		transitions:     map[string][]admin.Event{},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.historySize < 0 {
+		return nil, ErrInvalidTransitionHistory{Size: h.historySize}
+	}
+	if h.audit == nil {
+		audit, err := admin.NewAuditLog(admin.DefaultAuditSize)
+		if err != nil {
+			return nil, err
+		}
+		h.audit = audit
+	}
+
+	for _, breaker := range breakers {
+		name := breaker.Name()
+		if _, ok := h.breakers[name]; ok {
+			h.Close()
+			return nil, admin.ErrDuplicateBreaker{Name: name}
+		}
+		h.breakers[name] = breaker
+
+		sub := breaker.Subscribe(circuitbreaker.DefaultEventBufferSize, circuitbreaker.StateTransition)
+		h.subscriptions = append(h.subscriptions, sub)
+		go h.record(sub)
+	}
+
+	h.mux.HandleFunc("GET /{$}", h.index)
+	h.mux.HandleFunc("GET /api/breakers", h.list)
+	h.mux.HandleFunc("POST /api/breakers/{name}/force", h.withBreaker(h.force))
+	h.mux.HandleFunc("POST /api/breakers/{name}/reset", h.withBreaker(h.reset))
+	return h, nil
+}
+
+// Close stops recording transitions
+func (h *Handler) Close() {
+	for _, sub := range h.subscriptions {
+		sub.Close()
+	}
+}
+
+// Audit returns the most recent actions taken from the page, and through the admin handler given to WithAudit
+// if any, oldest first
+func (h *Handler) Audit() []admin.AuditEntry {
+	return h.audit.Audit()
+}
+
+// Breakers returns the status of every breaker sorted by name
+func (h *Handler) Breakers() []Breaker {
+	views := make([]Breaker, 0, len(h.breakers))
+	for _, breaker := range h.breakers {
+		views = append(views, h.status(breaker))
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Name < views[j].Name
+	})
+	return views
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) record(sub *circuitbreaker.Subscription) {
+	for event := range sub.Events() {
+		h.mutex.Lock()
+		transitions := append([]admin.Event{admin.NewEvent(event)}, h.transitions[event.Name]...)
+		if len(transitions) > h.historySize {
+			transitions = transitions[:h.historySize]
+		}
+		h.transitions[event.Name] = transitions
+		h.mutex.Unlock()
+	}
+}
+
+func (h *Handler) status(breaker *circuitbreaker.Breaker) Breaker {
+	view := Breaker{Breaker: admin.NewBreaker(breaker), Transitions: []admin.Event{}}
+	if until, ok := breaker.OpenUntil(); ok {
+		if remaining := time.Until(until); remaining > 0 {
+			view.CooldownRemaining = remaining.Milliseconds()
+		}
+	}
+
+	h.mutex.Lock()
+	view.Transitions = append(view.Transitions, h.transitions[breaker.Name()]...)
+	h.mutex.Unlock()
+	return view
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	page, err := static.ReadFile("static/index.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(page)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		RefreshInterval int64     `json:"refresh_interval_ms"`
+		Breakers        []Breaker `json:"breakers"`
+	}{
+		RefreshInterval: h.refreshInterval.Milliseconds(),
+		Breakers:        h.Breakers(),
+	})
+}
+
+func (h *Handler) force(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	var req admin.ForceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	state, err := circuitbreaker.ParseState(req.State)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	breaker.ForceState(state)
+	h.audit.Record(r, admin.AuditEntry{Breaker: breaker.Name(), Action: admin.ActionForce, State: state.String(), Reason: req.Reason})
+	writeJSON(w, http.StatusOK, h.status(breaker))
+}
+
+func (h *Handler) reset(w http.ResponseWriter, r *http.Request, breaker *circuitbreaker.Breaker) {
+	var req admin.ResetRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	breaker.Reset()
+	h.audit.Record(r, admin.AuditEntry{Breaker: breaker.Name(), Action: admin.ActionReset, Reason: req.Reason})
+	writeJSON(w, http.StatusOK, h.status(breaker))
+}
+
+// withBreaker also rejects requests that are not sent as JSON, every endpoint using it changes the breaker
+func (h *Handler) withBreaker(handler func(http.ResponseWriter, *http.Request, *circuitbreaker.Breaker)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, ErrNotJSON)
+			return
+		}
+
+		name := r.PathValue("name")
+		breaker, ok := h.breakers[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, admin.ErrUnknownBreaker{Name: name})
+			return
+		}
+		handler(w, r, breaker)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, admin.Error{Error: err.Error()})
+}
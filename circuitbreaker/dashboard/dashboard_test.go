@@ -0,0 +1,229 @@
+package dashboard_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/dashboard"
+)
+
+type list struct {
+	RefreshInterval int64               `json:"refresh_interval_ms"`
+	Breakers        []dashboard.Breaker `json:"breakers"`
+}
+
+func newHandler(t *testing.T, names ...string) (*dashboard.Handler, map[string]*circuitbreaker.Breaker) {
+	t.Helper()
+
+	breakers := map[string]*circuitbreaker.Breaker{}
+	var all []*circuitbreaker.Breaker
+	for _, name := range names {
+		breaker, _ := circuitbreaker.NewBreaker(name)
+		breakers[name] = breaker
+		all = append(all, breaker)
+	}
+
+	handler, err := dashboard.NewHandler(all)
+	if err != nil {
+		t.Fatalf("dashboard.NewHandler, expected no err, got %s", err)
+	}
+	t.Cleanup(handler.Close)
+	return handler, breakers
+}
+
+func do(t *testing.T, handler http.Handler, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if out != nil {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s, could not decode body: %s", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestIndex(t *testing.T) {
+	handler, _ := newHandler(t, "payments")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /, expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("GET / Content-Type, expected text/html, got %s", contentType)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "api/breakers") || strings.Contains(body, "src=\"http") {
+		t.Errorf("GET /, expected a self-contained page polling api/breakers")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /missing, expected %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestList(t *testing.T) {
+	handler, _ := newHandler(t, "payments", "inventory")
+
+	var body list
+	if code := do(t, handler, http.MethodGet, "/api/breakers", nil, &body); code != http.StatusOK {
+		t.Fatalf("GET /api/breakers, expected %d, got %d", http.StatusOK, code)
+	}
+
+	if body.RefreshInterval != dashboard.DefaultRefreshInterval.Milliseconds() {
+		t.Errorf("refresh interval, expected %d, got %d", dashboard.DefaultRefreshInterval.Milliseconds(), body.RefreshInterval)
+	}
+	if len(body.Breakers) != 2 || body.Breakers[0].Name != "inventory" || body.Breakers[1].Name != "payments" {
+		t.Fatalf("breakers, expected inventory and payments, got %+v", body.Breakers)
+	}
+	if body.Breakers[0].State != "closed" || body.Breakers[0].CooldownRemaining != 0 {
+		t.Errorf("inventory, expected closed without cooldown, got %+v", body.Breakers[0])
+	}
+}
+
+func TestForceAndReset(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	var view dashboard.Breaker
+	code := do(t, handler, http.MethodPost, "/api/breakers/payments/force", admin.ForceRequest{State: "open"}, &view)
+	if code != http.StatusOK || breakers["payments"].State() != circuitbreaker.Open {
+		t.Fatalf("force open, expected %d and open, got %d and %s", http.StatusOK, code, breakers["payments"].State())
+	}
+
+	cooldown := breakers["payments"].Settings.Thresholds.CooldownDuration.Milliseconds()
+	if view.CooldownRemaining <= 0 || view.CooldownRemaining > cooldown {
+		t.Errorf("cooldown remaining, expected within (0, %d], got %d", cooldown, view.CooldownRemaining)
+	}
+
+	view = dashboard.Breaker{}
+	if code := do(t, handler, http.MethodPost, "/api/breakers/payments/reset", nil, &view); code != http.StatusOK {
+		t.Fatalf("reset, expected %d, got %d", http.StatusOK, code)
+	}
+	if view.State != "closed" || view.CooldownRemaining != 0 {
+		t.Errorf("reset, expected closed without cooldown, got %+v", view)
+	}
+
+	// transitions are recorded asynchronously from the breaker events
+	deadline := time.Now().Add(time.Second)
+	var body list
+	for time.Now().Before(deadline) {
+		do(t, handler, http.MethodGet, "/api/breakers", nil, &body)
+		if len(body.Breakers[0].Transitions) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	transitions := body.Breakers[0].Transitions
+	if len(transitions) != 2 {
+		t.Fatalf("transitions, expected 2, got %+v", transitions)
+	}
+	if transitions[0].From != "open" || transitions[0].State != "closed" || transitions[1].From != "closed" || transitions[1].State != "open" {
+		t.Errorf("transitions, expected open to closed then closed to open, got %+v", transitions)
+	}
+}
+
+func TestTransitionHistory(t *testing.T) {
+	payments, _ := circuitbreaker.NewBreaker("payments")
+	_, err := dashboard.NewHandler([]*circuitbreaker.Breaker{payments}, dashboard.WithTransitionHistory(-1))
+	if err != (dashboard.ErrInvalidTransitionHistory{Size: -1}) {
+		t.Fatalf("dashboard.NewHandler with history -1, expected ErrInvalidTransitionHistory, got %v", err)
+	}
+
+	handler, err := dashboard.NewHandler([]*circuitbreaker.Breaker{payments}, dashboard.WithTransitionHistory(1))
+	if err != nil {
+		t.Fatalf("dashboard.NewHandler with history 1, expected no err, got %s", err)
+	}
+	t.Cleanup(handler.Close)
+
+	payments.ForceState(circuitbreaker.Open)
+	payments.Reset()
+	deadline := time.Now().Add(time.Second)
+	var transitions []admin.Event
+	for time.Now().Before(deadline) {
+		transitions = handler.Breakers()[0].Transitions
+		if len(transitions) == 1 && transitions[0].State == "closed" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("transitions, expected only open to closed, got %+v", transitions)
+}
+
+func TestAudit(t *testing.T) {
+	payments, _ := circuitbreaker.NewBreaker("payments")
+	adminHandler, _ := admin.NewHandler([]*circuitbreaker.Breaker{payments})
+	handler, err := dashboard.NewHandler([]*circuitbreaker.Breaker{payments}, dashboard.WithAudit(adminHandler))
+	if err != nil {
+		t.Fatalf("dashboard.NewHandler, expected no err, got %s", err)
+	}
+	defer handler.Close()
+
+	do(t, handler, http.MethodPost, "/api/breakers/payments/force", admin.ForceRequest{State: "open", Reason: "incident"}, nil)
+	do(t, handler, http.MethodPost, "/api/breakers/payments/reset", nil, nil)
+
+	entries := adminHandler.Audit()
+	if len(entries) != 2 {
+		t.Fatalf("adminHandler.Audit, expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Action != admin.ActionForce || entries[0].State != "open" || entries[0].Reason != "incident" || entries[1].Action != admin.ActionReset {
+		t.Errorf("adminHandler.Audit, expected force open then reset, got %+v", entries)
+	}
+	if len(handler.Audit()) != 2 {
+		t.Errorf("handler.Audit, expected the entries of the admin handler, got %+v", handler.Audit())
+	}
+}
+
+func TestFormPostRejected(t *testing.T) {
+	handler, breakers := newHandler(t, "payments")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/breakers/payments/force", strings.NewReader("state=open"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType || breakers["payments"].State() != circuitbreaker.Closed {
+		t.Errorf("form post, expected %d and closed, got %d and %s", http.StatusUnsupportedMediaType, rec.Code, breakers["payments"].State())
+	}
+	if len(handler.Audit()) != 0 {
+		t.Errorf("handler.Audit, expected no entry, got %+v", handler.Audit())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	handler, _ := newHandler(t, "payments")
+
+	var apiErr admin.Error
+	if code := do(t, handler, http.MethodPost, "/api/breakers/unknown/reset", nil, &apiErr); code != http.StatusNotFound {
+		t.Errorf("reset unknown, expected %d, got %d", http.StatusNotFound, code)
+	}
+
+	if code := do(t, handler, http.MethodPost, "/api/breakers/payments/force", admin.ForceRequest{State: "ajar"}, &apiErr); code != http.StatusBadRequest {
+		t.Errorf("force ajar, expected %d, got %d", http.StatusBadRequest, code)
+	}
+
+	payments, _ := circuitbreaker.NewBreaker("payments")
+	if _, err := dashboard.NewHandler([]*circuitbreaker.Breaker{payments, payments}); err == nil {
+		t.Errorf("dashboard.NewHandler with duplicates, expected err, got nil")
+	}
+}
@@ -2,6 +2,7 @@ package circuitbreaker
 
 import (
 	"fmt"
+	"time"
 )
 
 //ErrInvalidSettingParam gets thrown when a setting value is not valid
@@ -24,3 +25,71 @@ type ErrRequestNotPermitted struct {
 func (ernp ErrRequestNotPermitted) Error() string {
 	return fmt.Sprintf("circuit breaker not permitting requests, name : %s, state: %s", ernp.Name, ernp.State)
 }
+
+// ErrBulkheadFull gets returned when a call could not get a slot in the bulkhead of the breaker, Waited is how
+// long it waited in the queue
+type ErrBulkheadFull struct {
+	Name          string
+	MaxConcurrent int
+	Waited        time.Duration
+}
+
+func (ebf ErrBulkheadFull) Error() string {
+	if ebf.Waited > 0 {
+		return fmt.Sprintf("circuit breaker bulkhead full, name : %s, max concurrent: %d, waited: %s", ebf.Name, ebf.MaxConcurrent, ebf.Waited)
+	}
+	return fmt.Sprintf("circuit breaker bulkhead full, name : %s, max concurrent: %d", ebf.Name, ebf.MaxConcurrent)
+}
+
+// ErrLimitExceeded gets returned when a call was not permitted because as many calls as the Limiter of the
+// breaker allows are already running
+type ErrLimitExceeded struct {
+	Name  string
+	Limit int
+}
+
+func (ele ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("circuit breaker concurrency limit reached, name : %s, limit: %d", ele.Name, ele.Limit)
+}
+
+// ErrThrottled gets returned when a call was rejected by the Throttle of the breaker, Probability is the
+// probability calls were rejected with
+type ErrThrottled struct {
+	Name        string
+	Probability float64
+}
+
+func (et ErrThrottled) Error() string {
+	return fmt.Sprintf("circuit breaker throttled call, name : %s, probability: %.2f", et.Name, et.Probability)
+}
+
+//ErrUnknownState gets returned when parsing a string that does not name a state
+type ErrUnknownState struct {
+	Val string
+}
+
+func (eus ErrUnknownState) Error() string {
+	return fmt.Sprintf("unknown circuit breaker state %q", eus.Val)
+}
+
+// ErrRateLimited gets returned when a call was rejected by the RateLimit of the breaker, Rate is the number of
+// calls per second allowed at the time
+type ErrRateLimited struct {
+	Name string
+	Rate float64
+}
+
+func (erl ErrRateLimited) Error() string {
+	return fmt.Sprintf("circuit breaker rate limit reached, name : %s, rate: %.2f/s", erl.Name, erl.Rate)
+}
+
+// ErrShed gets returned when a call was rejected by the Shedding of the breaker because of its Criticality
+type ErrShed struct {
+	Name        string
+	Criticality Criticality
+	State       State
+}
+
+func (es ErrShed) Error() string {
+	return fmt.Sprintf("circuit breaker shed call, name : %s, criticality: %s, state: %s", es.Name, es.Criticality, es.State)
+}
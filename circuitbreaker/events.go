@@ -0,0 +1,230 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType describes the decision or change an Event reports
+type EventType int
+
+const (
+	// CallPermitted is emitted when a call is admitted, right before its handler runs
+	CallPermitted EventType = iota + 1
+	// CallRejected is emitted when a call is not permitted by the breaker
+	CallRejected
+	// CallSucceeded is emitted when a call finished and was classified as a success
+	CallSucceeded
+	// CallFailed is emitted when a call finished and was classified as a failure
+	CallFailed
+	// CallIgnored is emitted when a call finished but its outcome was not recorded, see Settings.IsIgnored
+	CallIgnored
+	// StateTransition is emitted whenever the state changes, whatever the cause
+	StateTransition
+	// Reset is emitted when Breaker.Reset is called
+	Reset
+	// Forced is emitted when Breaker.ForceState is called, even if the state did not change
+	Forced
+	// Pinned is emitted when Breaker.Pin is called
+	Pinned
+	// Unpinned is emitted when a pin expires or is released with Breaker.Unpin
+	Unpinned
+	// SettingsUpdated is emitted when Breaker.UpdateSettings puts new settings in effect
+	SettingsUpdated
+	// BulkheadRejected is emitted when a call was permitted by the breaker but could not get a slot in its
+	// bulkhead, see Bulkhead
+	BulkheadRejected
+	// LimitRejected is emitted when a call was not permitted because the Limiter of the breaker was at its limit
+	LimitRejected
+	// Throttled is emitted when a call was rejected by the Throttle of the breaker
+	Throttled
+	// RetryBudgetExhausted is emitted when a failed call was not retried because the RetryBudget of the breaker
+	// was exhausted
+	RetryBudgetExhausted
+	// Hedged is emitted when the Hedge of the breaker starts another attempt of a slow call
+	Hedged
+	// RateLimited is emitted when a call was rejected by the RateLimit of the breaker
+	RateLimited
+	// Shed is emitted when a call was rejected by the Shedding of the breaker because of its criticality
+	Shed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case CallPermitted:
+		return "call-permitted"
+	case CallRejected:
+		return "call-rejected"
+	case CallSucceeded:
+		return "call-succeeded"
+	case CallFailed:
+		return "call-failed"
+	case CallIgnored:
+		return "call-ignored"
+	case StateTransition:
+		return "state-transition"
+	case Reset:
+		return "reset"
+	case Forced:
+		return "forced"
+	case Pinned:
+		return "pinned"
+	case Unpinned:
+		return "unpinned"
+	case SettingsUpdated:
+		return "settings-updated"
+	case BulkheadRejected:
+		return "bulkhead-rejected"
+	case LimitRejected:
+		return "limit-rejected"
+	case Throttled:
+		return "throttled"
+	case RetryBudgetExhausted:
+		return "retry-budget-exhausted"
+	case Hedged:
+		return "hedged"
+	case RateLimited:
+		return "rate-limited"
+	case Shed:
+		return "shed"
+	default:
+		return "unknown event"
+	}
+}
+
+// IsStateChange reports whether the event type is about the state of the breaker rather than a single call,
+// which makes it rare enough to be streamed to operators
+func (t EventType) IsStateChange() bool {
+	switch t {
+	case StateTransition, Reset, Forced, Pinned, Unpinned, SettingsUpdated:
+		return true
+	default:
+		return false
+	}
+}
+
+// StateChangeTypes returns the event types for which IsStateChange is true, to subscribe to them only
+func StateChangeTypes() []EventType {
+	return []EventType{StateTransition, Reset, Forced, Pinned, Unpinned, SettingsUpdated}
+}
+
+// Event describes a single decision taken by a breaker
+type Event struct {
+	Type EventType
+	// Name of the breaker that emitted the event
+	Name string
+	// Time at which the event happened
+	Time time.Time
+	// State of the breaker when the call was admitted or rejected, or the new state for StateTransition,
+	// Reset, Forced, Pinned and SettingsUpdated events
+	State State
+	// From is the previous state for StateTransition, Reset, Forced, Pinned and SettingsUpdated events
+	From State
+	// Duration of the handler for CallSucceeded, CallFailed and CallIgnored events, or the delay after which
+	// the call was hedged for Hedged events
+	Duration time.Duration
+	// Err returned by the handler, or the rejection error for CallRejected, BulkheadRejected,
+	// LimitRejected, Throttled, RateLimited and Shed events, or the error of the attempt that was not retried for
+	// RetryBudgetExhausted events
+	Err error
+	// Reason given for Pinned and Unpinned events
+	Reason string
+	// Attempt of the call, starting at 1, for CallPermitted, CallSucceeded, CallFailed, CallIgnored,
+	// RetryBudgetExhausted and Hedged events, the attempts started by a hedge share the attempt they hedge
+	Attempt int
+}
+
+// EventListener is called with every event of the breaker it was registered with, see WithEventListener
+type EventListener func(Event)
+
+// DefaultEventBufferSize is the number of events a subscription buffers before dropping new ones
+const DefaultEventBufferSize int = 64
+
+// Subscription delivers the events of a breaker on a buffered channel. Events are never waited on, if the
+// buffer is full the event is dropped and counted, so a slow subscriber cannot slow down calls.
+type Subscription struct {
+	events  chan Event
+	dropped uint64
+	broker  *eventBroker
+	once    sync.Once
+	// types the subscription delivers, nil for every type
+	types map[EventType]bool
+}
+
+// Events returns the channel events are delivered on, it is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events were discarded because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops the delivery of events and closes the events channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.unsubscribe(s)
+	})
+}
+
+type eventBroker struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscriptions: map[*Subscription]struct{}{}}
+}
+
+func (eb *eventBroker) subscribe(bufferSize int, types []EventType) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+
+	s := &Subscription{events: make(chan Event, bufferSize), broker: eb}
+	if len(types) > 0 {
+		s.types = map[EventType]bool{}
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+	eb.subscriptions[s] = struct{}{}
+	return s
+}
+
+func (eb *eventBroker) unsubscribe(s *Subscription) {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+	delete(eb.subscriptions, s)
+	close(s.events)
+}
+
+func (eb *eventBroker) publish(event Event) {
+	eb.mutex.RLock()
+	defer eb.mutex.RUnlock()
+
+	for s := range eb.subscriptions {
+		if s.types != nil && !s.types[event.Type] {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// listen runs the listener on its own goroutine for every event until the subscription is closed
+func listen(s *Subscription, listener EventListener) {
+	go func() {
+		for event := range s.Events() {
+			listener(event)
+		}
+	}()
+}
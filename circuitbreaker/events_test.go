@@ -0,0 +1,193 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func receiveEvents(t *testing.T, sub *circuitbreaker.Subscription, n int) []circuitbreaker.Event {
+	t.Helper()
+
+	events := make([]circuitbreaker.Event, 0, n)
+	for len(events) < n {
+		select {
+		case event := <-sub.Events():
+			events = append(events, event)
+		case <-time.After(time.Second):
+			t.Fatalf("sub.Events, expected %d events, got %d", n, len(events))
+		}
+	}
+	return events
+}
+
+func compareEventTypes(t *testing.T, expected []circuitbreaker.EventType, events []circuitbreaker.Event) {
+	t.Helper()
+
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Errorf("event %d, expected %s, got %s", i, expected[i], event.Type)
+		}
+		if event.Name != "test" {
+			t.Errorf("event %d, name expected test, got %s", i, event.Name)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("event %d, expected time to be set", i)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("Calls", func(t *testing.T) {
+		var IsIgnored circuitbreaker.IsIgnoredHandler = func(r *http.Response, e error) bool {
+			return errors.Is(e, context.Canceled)
+		}
+
+		gauge := gauges.NewFixedWindowGauge(10)
+		settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithGauge(gauge), circuitbreaker.WithIsIgnoredHandler(IsIgnored))
+		cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+		sub := cb.Subscribe(0)
+		defer sub.Close()
+
+		failure := errors.New("failed")
+		for _, err := range []error{nil, failure, context.Canceled} {
+			cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+				return nil, err
+			})
+		}
+		cb.ForceState(circuitbreaker.Open)
+		cb.Execute(func(name string) (*http.Response, error) {
+			return nil, nil
+		})
+
+		events := receiveEvents(t, sub, 9)
+		compareEventTypes(t, []circuitbreaker.EventType{
+			circuitbreaker.CallPermitted, circuitbreaker.CallSucceeded,
+			circuitbreaker.CallPermitted, circuitbreaker.CallFailed,
+			circuitbreaker.CallPermitted, circuitbreaker.CallIgnored,
+			circuitbreaker.Forced, circuitbreaker.StateTransition,
+			circuitbreaker.CallRejected,
+		}, events)
+
+		if events[3].Err != failure {
+			t.Errorf("CallFailed event, err expected %s, got %v", failure, events[3].Err)
+		}
+
+		if aggregate := cb.Aggregate(); aggregate.RequestCount != 2 {
+			t.Errorf("cb.Aggregate, ignored call should not be recorded, expected 2 requests, got %d", aggregate.RequestCount)
+		}
+
+		if transition := events[7]; transition.From != circuitbreaker.Closed || transition.State != circuitbreaker.Open {
+			t.Errorf("StateTransition event, expected closed to open, got %s to %s", transition.From, transition.State)
+		}
+	})
+
+	t.Run("TransitionsNotifyOnStateChangeOnce", func(t *testing.T) {
+		var mutex sync.Mutex
+		var transitions []circuitbreaker.Transition
+		var OnStateChange circuitbreaker.OnStateChangeHandler = func(name string, from, to circuitbreaker.State) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			transitions = append(transitions, circuitbreaker.Transition{From: from, To: to})
+		}
+
+		settings, _ := circuitbreaker.NewSettings("test",
+			circuitbreaker.WithOnStateChangeHandler(OnStateChange),
+			circuitbreaker.WithCooldownDuration(10*time.Millisecond))
+		cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+		sub := cb.Subscribe(0)
+		defer sub.Close()
+
+		cb.ForceState(circuitbreaker.Open)
+		events := receiveEvents(t, sub, 3)
+		compareEventTypes(t, []circuitbreaker.EventType{
+			circuitbreaker.Forced, circuitbreaker.StateTransition, circuitbreaker.StateTransition,
+		}, events)
+
+		cb.Reset()
+		events = receiveEvents(t, sub, 2)
+		compareEventTypes(t, []circuitbreaker.EventType{circuitbreaker.Reset, circuitbreaker.StateTransition}, events)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		expected := []circuitbreaker.Transition{
+			{From: circuitbreaker.Closed, To: circuitbreaker.Open},
+			{From: circuitbreaker.Open, To: circuitbreaker.HalfOpen},
+			{From: circuitbreaker.HalfOpen, To: circuitbreaker.Closed},
+		}
+		if len(transitions) != len(expected) {
+			t.Fatalf("OnStateChange, expected %v, got %v", expected, transitions)
+		}
+		for i := range expected {
+			if transitions[i] != expected[i] {
+				t.Errorf("OnStateChange %d, expected %+v, got %+v", i, expected[i], transitions[i])
+			}
+		}
+	})
+
+	t.Run("SlowSubscriberDropsEvents", func(t *testing.T) {
+		cb, _ := circuitbreaker.NewBreaker("test")
+		sub := cb.Subscribe(1)
+
+		cb.ForceState(circuitbreaker.Closed)
+		cb.ForceState(circuitbreaker.Closed)
+
+		if sub.Dropped() != 1 {
+			t.Errorf("sub.Dropped, expected 1, got %d", sub.Dropped())
+		}
+
+		sub.Close()
+		sub.Close()
+		if _, ok := <-sub.Events(); !ok {
+			t.Errorf("sub.Events, expected buffered event to still be readable after close")
+		}
+	})
+
+	t.Run("FilteredSubscription", func(t *testing.T) {
+		cb, _ := circuitbreaker.NewBreaker("test")
+		sub := cb.Subscribe(1, circuitbreaker.StateChangeTypes()...)
+		defer sub.Close()
+
+		for range 10 {
+			cb.ExecuteContext(context.Background(), succeedingHandler)
+		}
+		cb.ForceState(circuitbreaker.Open)
+
+		events := receiveEvents(t, sub, 1)
+		if events[0].Type != circuitbreaker.Forced || sub.Dropped() != 1 {
+			t.Errorf("filtered subscription, expected the forced event and the transition dropped, got %s and %d dropped", events[0].Type, sub.Dropped())
+		}
+	})
+
+	t.Run("EventListener", func(t *testing.T) {
+		received := make(chan circuitbreaker.Event, 1)
+		settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithEventListener(func(event circuitbreaker.Event) {
+			received <- event
+		}))
+		cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+		cb.ForceState(circuitbreaker.Closed)
+
+		select {
+		case event := <-received:
+			if event.Type != circuitbreaker.Forced {
+				t.Errorf("listener event, expected %s, got %s", circuitbreaker.Forced, event.Type)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("listener, expected an event")
+		}
+
+		cb.Close()
+		cb.ForceState(circuitbreaker.Open)
+		select {
+		case event := <-received:
+			t.Errorf("listener after cb.Close, expected no event, got %s", event.Type)
+		case <-time.After(20 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Package fallback serves the last good response of a call when a circuit breaker rejects it or the call fails
+package fallback
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// DefaultMaxStaleness is how long a response can be served after it was cached
+const DefaultMaxStaleness time.Duration = 5 * time.Minute
+
+// DefaultMaxEntries is the number of responses kept, the least recently used ones are evicted first
+const DefaultMaxEntries int = 1000
+
+// DefaultMaxBodySize is the size of the largest body cached, larger responses are not cached
+const DefaultMaxBodySize int64 = 1 << 20
+
+// StaleWarning is the Warning header value set on the responses served from the cache, as defined by RFC 7234
+const StaleWarning = `110 - "Response is Stale"`
+
+// Option sets optional parameters of the Cache
+type Option func(*Cache)
+
+// WithMaxStaleness sets how long a response can be served after it was cached, DefaultMaxStaleness by default
+func WithMaxStaleness(staleness time.Duration) Option {
+	return func(c *Cache) {
+		c.maxStaleness = staleness
+	}
+}
+
+// WithMaxEntries sets the number of responses kept, DefaultMaxEntries by default
+func WithMaxEntries(entries int) Option {
+	return func(c *Cache) {
+		c.maxEntries = entries
+	}
+}
+
+// WithMaxBodySize sets the size of the largest body cached, DefaultMaxBodySize by default
+func WithMaxBodySize(size int64) Option {
+	return func(c *Cache) {
+		c.maxBodySize = size
+	}
+}
+
+// Cache wraps a breaker, keeping the last successful response of every key so that it can be served instead
+// when the breaker rejects the call or the call fails. Successful calls are always made, the cache is only read
+// on failure, and the responses it serves are marked stale with a Warning header, see IsStale. Responses older
+// than the max staleness are never served.
+//
+// Calls are classified with the IsSuccessful and IsIgnored handlers of the breaker. Ignored calls and calls whose
+// context is done before running are returned as they are.
+type Cache struct {
+	breaker      *circuitbreaker.Breaker
+	maxStaleness time.Duration
+	maxEntries   int
+	maxBodySize  int64
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// entry is a cached response, its body is read in full
+type entry struct {
+	key      string
+	status   int
+	proto    string
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// NewCache creates a cache serving the last good responses of the calls made through the breaker
+func NewCache(breaker *circuitbreaker.Breaker, opts ...Option) (*Cache, error) {
+	c := &Cache{
+		breaker:      breaker,
+		maxStaleness: DefaultMaxStaleness,
+		maxEntries:   DefaultMaxEntries,
+		maxBodySize:  DefaultMaxBodySize,
+		entries:      map[string]*list.Element{},
+		lru:          list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.maxStaleness <= 0 {
+		return nil, ErrInvalidOption{Option: "MaxStaleness", Val: c.maxStaleness}
+	}
+	if c.maxEntries <= 0 {
+		return nil, ErrInvalidOption{Option: "MaxEntries", Val: c.maxEntries}
+	}
+	if c.maxBodySize < 0 {
+		return nil, ErrInvalidOption{Option: "MaxBodySize", Val: c.maxBodySize}
+	}
+	return c, nil
+}
+
+// Execute is like Breaker.Execute, falling back to the last good response of the key
+func (c *Cache) Execute(key string, handler circuitbreaker.ExecuteHandler) (*http.Response, error) {
+	return c.ExecuteContext(context.Background(), key, func(ctx context.Context, name string) (*http.Response, error) {
+		return handler(name)
+	})
+}
+
+// ExecuteContext is like Breaker.ExecuteContext, falling back to the last good response of the key. A successful
+// response is cached before being returned, its body can still be read by the caller.
+func (c *Cache) ExecuteContext(ctx context.Context, key string, handler circuitbreaker.ExecuteContextHandler) (*http.Response, error) {
+	ran := false
+	resp, err := c.breaker.ExecuteContext(ctx, func(ctx context.Context, name string) (*http.Response, error) {
+		ran = true
+		return handler(ctx, name)
+	})
+
+	settings := c.breaker.CurrentSettings()
+	switch {
+	case !ran && ctx.Err() != nil:
+		return resp, err
+	case ran && settings.IsIgnored != nil && settings.IsIgnored(resp, err):
+		return resp, err
+	case ran && settings.IsSuccessful(resp, err):
+		c.store(key, resp)
+		return resp, err
+	}
+
+	stale, ok := c.lookup(key)
+	if !ok {
+		return resp, err
+	}
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	return stale, nil
+}
+
+// IsStale reports whether the response was served from a Cache
+func IsStale(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get("Warning") == StaleWarning
+}
+
+// Len returns the number of responses cached
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// Remove forgets the response of the key
+func (c *Cache) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// store caches the response, reading its body and replacing it with a copy for the caller
+func (c *Cache) store(key string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		if resp.ContentLength > c.maxBodySize {
+			return
+		}
+		read, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize+1))
+		// the caller gets what was read followed by what is left
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(read), resp.Body), Closer: resp.Body}
+		if err != nil || int64(len(read)) > c.maxBodySize {
+			return
+		}
+		body = read
+	}
+
+	stored := &entry{
+		key:      key,
+		status:   resp.StatusCode,
+		proto:    resp.Proto,
+		header:   resp.Header.Clone(),
+		body:     body,
+		storedAt: time.Now(),
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = stored
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(stored)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// lookup returns a stale copy of the response of the key, unless it is missing or too old
+func (c *Cache) lookup(key string) (*http.Response, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*entry)
+	age := time.Since(cached.storedAt)
+	if age > c.maxStaleness {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+
+	header := cached.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Warning", StaleWarning)
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	return &http.Response{
+		Status:        strconv.Itoa(cached.status) + " " + http.StatusText(cached.status),
+		StatusCode:    cached.status,
+		Proto:         cached.proto,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.body)),
+		ContentLength: int64(len(cached.body)),
+	}, true
+}
+
+// readCloser reads from a reader and closes another, which keeps the original body closable once read ahead
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,153 @@
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/fallback"
+)
+
+func respond(body string) circuitbreaker.ExecuteContextHandler {
+	return func(ctx context.Context, name string) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+func fail(ctx context.Context, name string) (*http.Response, error) {
+	return nil, errors.New("failed")
+}
+
+func newCache(t *testing.T, opts ...fallback.Option) (*fallback.Cache, *circuitbreaker.Breaker) {
+	t.Helper()
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	cache, err := fallback.NewCache(breaker, opts...)
+	if err != nil {
+		t.Fatalf("fallback.NewCache, expected no err, got %s", err)
+	}
+	return cache, breaker
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("io.ReadAll, expected no err, got %s", err)
+	}
+	return string(body)
+}
+
+func TestCache(t *testing.T) {
+	cache, breaker := newCache(t)
+
+	resp, err := cache.ExecuteContext(context.Background(), "order-1", respond("paid"))
+	if err != nil || fallback.IsStale(resp) {
+		t.Fatalf("cache.ExecuteContext, expected a fresh response, got %v", err)
+	}
+	if body := readBody(t, resp); body != "paid" {
+		t.Errorf("body of the fresh response, expected paid, got %s", body)
+	}
+
+	testCases := map[string]func(){
+		"Failed":   func() {},
+		"Rejected": func() { breaker.Trip() },
+	}
+	for name, setup := range testCases {
+		t.Run(name, func(t *testing.T) {
+			setup()
+			resp, err := cache.ExecuteContext(context.Background(), "order-1", fail)
+			if err != nil || !fallback.IsStale(resp) {
+				t.Fatalf("cache.ExecuteContext, expected a stale response, got %v", err)
+			}
+			if body := readBody(t, resp); body != "paid" || resp.StatusCode != http.StatusOK {
+				t.Errorf("stale response, expected 200 paid, got %d %s", resp.StatusCode, body)
+			}
+			if contentType := resp.Header.Get("Content-Type"); contentType != "text/plain" {
+				t.Errorf("stale response, expected the cached headers, got content type %q", contentType)
+			}
+		})
+	}
+
+	_, err = cache.ExecuteContext(context.Background(), "order-2", fail)
+	var notPermitted circuitbreaker.ErrRequestNotPermitted
+	if !errors.As(err, &notPermitted) {
+		t.Errorf("cache.ExecuteContext without a cached response, expected ErrRequestNotPermitted, got %v", err)
+	}
+}
+
+func TestCacheExecute(t *testing.T) {
+	cache, breaker := newCache(t)
+	cache.Execute("order-1", func(name string) (*http.Response, error) {
+		return respond("paid")(context.Background(), name)
+	})
+
+	breaker.Trip()
+	resp, err := cache.Execute("order-1", func(name string) (*http.Response, error) {
+		t.Fatal("handler, expected the breaker to reject the call")
+		return nil, nil
+	})
+	if err != nil || !fallback.IsStale(resp) {
+		t.Errorf("cache.Execute, expected a stale response instead of the rejection, got %v", err)
+	}
+}
+
+func TestCacheMaxStaleness(t *testing.T) {
+	cache, _ := newCache(t, fallback.WithMaxStaleness(10*time.Millisecond))
+	cache.ExecuteContext(context.Background(), "order-1", respond("paid"))
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := cache.ExecuteContext(context.Background(), "order-1", fail); err == nil {
+		t.Error("cache.ExecuteContext, expected the failure once the response is too old")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("cache.Len, expected the old response to be dropped, got %d", cache.Len())
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	cache, _ := newCache(t, fallback.WithMaxEntries(2))
+	for i := 1; i <= 3; i++ {
+		cache.ExecuteContext(context.Background(), fmt.Sprintf("order-%d", i), respond("paid"))
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("cache.Len, expected 2, got %d", cache.Len())
+	}
+	if _, err := cache.ExecuteContext(context.Background(), "order-1", fail); err == nil {
+		t.Error("cache.ExecuteContext, expected the least recently used response to be evicted")
+	}
+	if _, err := cache.ExecuteContext(context.Background(), "order-3", fail); err != nil {
+		t.Errorf("cache.ExecuteContext, expected the latest response, got %s", err)
+	}
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	cache, _ := newCache(t, fallback.WithMaxBodySize(4))
+	resp, _ := cache.ExecuteContext(context.Background(), "order-1", respond("refunded"))
+
+	if body := readBody(t, resp); body != "refunded" {
+		t.Errorf("body, expected the whole body to be returned, got %s", body)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("cache.Len, expected the large response not to be cached, got %d", cache.Len())
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	breaker, _ := circuitbreaker.NewBreaker("payments")
+	_, err := fallback.NewCache(breaker, fallback.WithMaxEntries(0))
+	expectedErr := fallback.ErrInvalidOption{Option: "MaxEntries", Val: 0}
+	if err != expectedErr {
+		t.Errorf("fallback.NewCache, expected %s, got %v", expectedErr, err)
+	}
+}
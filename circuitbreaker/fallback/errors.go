@@ -0,0 +1,15 @@
+package fallback
+
+import (
+	"fmt"
+)
+
+// ErrInvalidOption gets returned when creating a Cache with an option out of its range
+type ErrInvalidOption struct {
+	Option string
+	Val    interface{}
+}
+
+func (e ErrInvalidOption) Error() string {
+	return fmt.Sprintf("invalid fallback cache option %s: %v", e.Option, e.Val)
+}
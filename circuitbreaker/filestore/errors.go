@@ -0,0 +1,38 @@
+package filestore
+
+import (
+	"fmt"
+)
+
+// ErrCorruptSnapshot gets returned when a file cannot be turned back into a snapshot
+type ErrCorruptSnapshot struct {
+	Path string
+	Err  error
+}
+
+func (e ErrCorruptSnapshot) Error() string {
+	return fmt.Sprintf("corrupt circuit breaker snapshot %s: %s", e.Path, e.Err)
+}
+
+func (e ErrCorruptSnapshot) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnsupportedVersion gets returned for files written with a schema this version of the store does not know
+type ErrUnsupportedVersion struct {
+	Version int
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported snapshot version %d, expected %d", e.Version, Version)
+}
+
+// ErrNameMismatch gets returned when a file holds the snapshot of another breaker
+type ErrNameMismatch struct {
+	Expected string
+	Got      string
+}
+
+func (e ErrNameMismatch) Error() string {
+	return fmt.Sprintf("snapshot belongs to %q, expected %q", e.Got, e.Expected)
+}
@@ -0,0 +1,164 @@
+// Package filestore implements circuitbreaker.StateStore with one JSON file per breaker in a directory.
+//
+// Files are replaced atomically, so a crash while saving leaves the previous snapshot in place. Every file
+// records the version of its schema, files written by a newer version or that cannot be decoded are reported as
+// errors by Load, in which case the breaker starts closed and the file is overwritten on its next save.
+package filestore
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Version of the schema of the files written by the store
+const Version = 1
+
+// Store saves snapshots as files in a directory
+type Store struct {
+	dir string
+}
+
+var _ circuitbreaker.StateStore = &Store{}
+
+// record is the content of a file
+type record struct {
+	Version   int           `json:"version"`
+	Name      string        `json:"name"`
+	SavedAt   time.Time     `json:"saved_at"`
+	State     string        `json:"state"`
+	OpenUntil *time.Time    `json:"open_until,omitempty"`
+	Window    []measurement `json:"window,omitempty"`
+}
+
+// measurement is a gauges.Aggregate of the window, oldest first
+type measurement struct {
+	Requests  int `json:"requests"`
+	Failures  int `json:"failures"`
+	Successes int `json:"successes"`
+}
+
+// New creates the directory if it does not exist yet
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Path returns the file holding the snapshot of the named breaker
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".json")
+}
+
+func (s *Store) Load(name string) (circuitbreaker.Snapshot, bool, error) {
+	path := s.Path(name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return circuitbreaker.Snapshot{}, false, nil
+	}
+	if err != nil {
+		return circuitbreaker.Snapshot{}, false, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return circuitbreaker.Snapshot{}, false, ErrCorruptSnapshot{Path: path, Err: err}
+	}
+
+	snapshot, err := decode(rec, name)
+	if err != nil {
+		return circuitbreaker.Snapshot{}, false, ErrCorruptSnapshot{Path: path, Err: err}
+	}
+	return snapshot, true, nil
+}
+
+func (s *Store) Save(name string, snapshot circuitbreaker.Snapshot) error {
+	data, err := json.Marshal(encode(name, snapshot))
+	if err != nil {
+		return err
+	}
+
+	path := s.Path(name)
+	file, err := os.CreateTemp(s.dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func encode(name string, snapshot circuitbreaker.Snapshot) record {
+	rec := record{
+		Version: Version,
+		Name:    name,
+		SavedAt: snapshot.SavedAt,
+		State:   snapshot.State.String(),
+	}
+
+	if snapshot.State == circuitbreaker.Open {
+		openUntil := snapshot.OpenUntil
+		rec.OpenUntil = &openUntil
+	}
+
+	if snapshot.Gauge != nil {
+		for _, aggregate := range snapshot.Gauge.Measurements {
+			rec.Window = append(rec.Window, measurement{
+				Requests:  aggregate.RequestCount,
+				Failures:  aggregate.FailureCount,
+				Successes: aggregate.SuccessCount,
+			})
+		}
+	}
+	return rec
+}
+
+// decode converts a record of any supported version, older versions are migrated here as the schema evolves
+func decode(rec record, name string) (circuitbreaker.Snapshot, error) {
+	if rec.Version != Version {
+		return circuitbreaker.Snapshot{}, ErrUnsupportedVersion{Version: rec.Version}
+	}
+
+	if rec.Name != name {
+		return circuitbreaker.Snapshot{}, ErrNameMismatch{Expected: name, Got: rec.Name}
+	}
+
+	state, err := circuitbreaker.ParseState(rec.State)
+	if err != nil {
+		return circuitbreaker.Snapshot{}, err
+	}
+
+	snapshot := circuitbreaker.Snapshot{State: state, SavedAt: rec.SavedAt}
+	if rec.OpenUntil != nil {
+		snapshot.OpenUntil = *rec.OpenUntil
+	}
+
+	if rec.Window != nil {
+		snapshot.Gauge = &gauges.Snapshot{}
+		for _, m := range rec.Window {
+			snapshot.Gauge.Measurements = append(snapshot.Gauge.Measurements, gauges.Aggregate{
+				RequestCount: m.Requests,
+				FailureCount: m.Failures,
+				SuccessCount: m.Successes,
+			})
+		}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,131 @@
+package filestore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/filestore"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func newStore(t *testing.T) *filestore.Store {
+	t.Helper()
+
+	store, err := filestore.New(filepath.Join(t.TempDir(), "breakers"))
+	if err != nil {
+		t.Fatalf("filestore.New, expected no err, got %s", err)
+	}
+	return store
+}
+
+func TestRoundTrip(t *testing.T) {
+	store := newStore(t)
+	openUntil := time.Now().Add(time.Minute).Round(0)
+	saved := circuitbreaker.Snapshot{
+		State:     circuitbreaker.Open,
+		OpenUntil: openUntil,
+		Gauge:     &gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 1, FailureCount: 1}, {}}},
+		SavedAt:   time.Now().Round(0),
+	}
+
+	if err := store.Save("orders/payments", saved); err != nil {
+		t.Fatalf("Store.Save, expected no err, got %s", err)
+	}
+
+	loaded, ok, err := store.Load("orders/payments")
+	if err != nil || !ok {
+		t.Fatalf("Store.Load, expected snapshot, got ok %t and err %v", ok, err)
+	}
+
+	if loaded.State != saved.State || !loaded.OpenUntil.Equal(openUntil) || !loaded.SavedAt.Equal(saved.SavedAt) {
+		t.Errorf("Store.Load, expected %+v, got %+v", saved, loaded)
+	}
+	if loaded.Gauge == nil || len(loaded.Gauge.Measurements) != 2 || loaded.Gauge.Measurements[0] != saved.Gauge.Measurements[0] {
+		t.Errorf("Store.Load gauge, expected %+v, got %+v", saved.Gauge, loaded.Gauge)
+	}
+
+	if _, ok, err := store.Load("inventory"); ok || err != nil {
+		t.Errorf("Store.Load of a missing breaker, expected no snapshot and no err, got ok %t and err %v", ok, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		check   func(error) bool
+	}{
+		{"Truncated", `{"version":1,"name":"payments","sta`, func(err error) bool {
+			var corrupt filestore.ErrCorruptSnapshot
+			return errors.As(err, &corrupt)
+		}},
+		{"NewerVersion", `{"version":2,"name":"payments","state":"open"}`, func(err error) bool {
+			var unsupported filestore.ErrUnsupportedVersion
+			return errors.As(err, &unsupported) && unsupported.Version == 2
+		}},
+		{"OtherBreaker", `{"version":1,"name":"inventory","state":"open"}`, func(err error) bool {
+			var mismatch filestore.ErrNameMismatch
+			return errors.As(err, &mismatch)
+		}},
+		{"UnknownState", `{"version":1,"name":"payments","state":"ajar"}`, func(err error) bool {
+			var unknown circuitbreaker.ErrUnknownState
+			return errors.As(err, &unknown)
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := newStore(t)
+			if err := os.WriteFile(store.Path("payments"), []byte(c.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, ok, err := store.Load("payments")
+			if ok || !c.check(err) {
+				t.Errorf("Store.Load, expected %s error, got ok %t and err %v", c.name, ok, err)
+			}
+		})
+	}
+}
+
+func TestBreakerRestart(t *testing.T) {
+	store := newStore(t)
+	newBreaker := func() *circuitbreaker.Breaker {
+		settings, _ := circuitbreaker.NewSettings("payments",
+			circuitbreaker.WithStateStore(store),
+			circuitbreaker.WithCooldownDuration(time.Minute),
+		)
+		breaker, err := circuitbreaker.NewBreakerWithSettings(settings)
+		if err != nil {
+			t.Fatalf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+		}
+		return breaker
+	}
+
+	opened := newBreaker()
+	opened.ForceState(circuitbreaker.Open)
+	if err := opened.Save(); err != nil {
+		t.Fatalf("Breaker.Save, expected no err, got %s", err)
+	}
+
+	if state := newBreaker().State(); state != circuitbreaker.Open {
+		t.Errorf("state after restart, expected %s, got %s", circuitbreaker.Open, state)
+	}
+
+	// a corrupt file must not prevent the breaker from starting, and gets replaced on the next save
+	os.WriteFile(store.Path("payments"), []byte("garbage"), 0o644)
+	breaker := newBreaker()
+	if breaker.State() != circuitbreaker.Closed {
+		t.Errorf("state after corruption, expected %s, got %s", circuitbreaker.Closed, breaker.State())
+	}
+
+	breaker.ForceState(circuitbreaker.Open)
+	breaker.Save()
+	if _, ok, err := store.Load("payments"); !ok || err != nil {
+		t.Errorf("Store.Load after save, expected snapshot, got ok %t and err %v", ok, err)
+	}
+}
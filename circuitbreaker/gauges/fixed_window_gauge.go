@@ -11,6 +11,8 @@ type FixedWindowGauge struct {
 }
 
 var _ Gauge = &FixedWindowGauge{}
+var _ Snapshotter = &FixedWindowGauge{}
+var _ Restorer = &FixedWindowGauge{}
 
 func NewFixedWindowGauge(windowSize int) *FixedWindowGauge {
 	gauge := &FixedWindowGauge{
@@ -48,6 +50,40 @@ func (g *FixedWindowGauge) Reset() {
 	g.makeNewMeasurements()
 }
 
+func (g *FixedWindowGauge) Snapshot() Snapshot {
+	snapshot := Snapshot{Measurements: make([]Aggregate, 0, g.windowSize)}
+	for i := 1; i <= g.windowSize; i++ {
+		snapshot.Measurements = append(snapshot.Measurements, *g.measurements[(g.head+i)%g.windowSize])
+	}
+	return snapshot
+}
+
+// Restore keeps the most recent measurements of the snapshot when it holds more than the window size, so that
+// a snapshot taken with a different window size can still be restored
+func (g *FixedWindowGauge) Restore(snapshot Snapshot) error {
+	measurements := snapshot.Measurements
+	if len(measurements) > g.windowSize {
+		measurements = measurements[len(measurements)-g.windowSize:]
+	}
+
+	for _, measurement := range measurements {
+		if !measurement.valid() {
+			return ErrInvalidSnapshot
+		}
+	}
+
+	g.Reset()
+	offset := g.windowSize - len(measurements)
+	for i, measurement := range measurements {
+		*g.measurements[offset+i] = measurement
+		g.totalAggregate.RequestCount += measurement.RequestCount
+		g.totalAggregate.FailureCount += measurement.FailureCount
+		g.totalAggregate.SuccessCount += measurement.SuccessCount
+	}
+	g.head = g.windowSize - 1
+	return nil
+}
+
 func (g *FixedWindowGauge) slideWindow() {
 	tail := g.tail()
 	oldMeasurement := g.measurements[tail]
@@ -101,3 +101,60 @@ func TestFixedWindowGauge(t *testing.T) {
 		}
 	})
 }
+
+func TestFixedWindowGaugeSnapshot(t *testing.T) {
+	t.Run("TestRoundTrip", func(t *testing.T) {
+		gauge := gauges.NewFixedWindowGauge(3)
+		for _, outcome := range []gauges.Outcome{gauges.Failure, gauges.Success, gauges.Failure, gauges.Failure} {
+			gauge.LogReading(outcome)
+		}
+
+		restored := gauges.NewFixedWindowGauge(3)
+		if err := restored.Restore(gauge.Snapshot()); err != nil {
+			t.Fatalf("Restore error expected nil got %s", err)
+		}
+
+		if restored.OverallAggregate() != gauge.OverallAggregate() {
+			t.Errorf("OverallAggregate expected %+v got %+v", gauge.OverallAggregate(), restored.OverallAggregate())
+		}
+
+		// the oldest reading, a success, must be the next one evicted
+		restored.LogReading(gauges.Failure)
+		expectedAggregate := gauges.Aggregate{RequestCount: 3, FailureCount: 3}
+		if restored.OverallAggregate() != expectedAggregate {
+			t.Errorf("OverallAggregate after eviction expected %+v got %+v", expectedAggregate, restored.OverallAggregate())
+		}
+	})
+
+	t.Run("TestSmallerWindow", func(t *testing.T) {
+		gauge := gauges.NewFixedWindowGauge(4)
+		for _, outcome := range []gauges.Outcome{gauges.Failure, gauges.Failure, gauges.Success, gauges.Success} {
+			gauge.LogReading(outcome)
+		}
+
+		restored := gauges.NewFixedWindowGauge(2)
+		if err := restored.Restore(gauge.Snapshot()); err != nil {
+			t.Fatalf("Restore error expected nil got %s", err)
+		}
+
+		expectedAggregate := gauges.Aggregate{RequestCount: 2, SuccessCount: 2}
+		if restored.OverallAggregate() != expectedAggregate {
+			t.Errorf("OverallAggregate expected %+v got %+v", expectedAggregate, restored.OverallAggregate())
+		}
+	})
+
+	t.Run("TestInvalid", func(t *testing.T) {
+		gauge := gauges.NewFixedWindowGauge(3)
+		gauge.LogReading(gauges.Failure)
+
+		snapshot := gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 2, FailureCount: 1}}}
+		if err := gauge.Restore(snapshot); err != gauges.ErrInvalidSnapshot {
+			t.Errorf("Restore error expected ErrInvalidSnapshot got %v", err)
+		}
+
+		expectedAggregate := gauges.Aggregate{RequestCount: 1, FailureCount: 1}
+		if gauge.OverallAggregate() != expectedAggregate {
+			t.Errorf("OverallAggregate after invalid restore expected %+v got %+v", expectedAggregate, gauge.OverallAggregate())
+		}
+	})
+}
@@ -2,7 +2,6 @@ package gauges
 
 import (
 	"errors"
-	"fmt"
 )
 
 // Outcome is a type used to describe the different request outcomes
@@ -27,11 +26,11 @@ func (o Outcome) String() string {
 // Aggregate is used to keep track of the current performance of the outbound requests
 type Aggregate struct {
 	// Keep track of total requests in the snapshot
-	RequestCount int
+	RequestCount int `json:"requests"`
 	// Keep track of requests that have failed
-	FailureCount int
+	FailureCount int `json:"failures"`
 	// Keep track of request that succeeded
-	SuccessCount int
+	SuccessCount int `json:"successes"`
 }
 
 func (a *Aggregate) record(outcome Outcome) {
@@ -57,7 +56,6 @@ func (a *Aggregate) reset() {
 }
 
 func (a *Aggregate) FailureRate() float64 {
-	fmt.Println(a.FailureCount)
 	if a.RequestCount > 0 {
 		return 100 * float64(a.FailureCount) / float64(a.RequestCount)
 	}
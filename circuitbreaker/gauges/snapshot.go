@@ -0,0 +1,81 @@
+package gauges
+
+import (
+	"errors"
+)
+
+// Snapshot is a copy of the readings held by a gauge window, oldest first. Snapshots are plain values that can
+// be serialised, and the snapshots of several gauges can be merged.
+type Snapshot struct {
+	Measurements []Aggregate `json:"measurements"`
+}
+
+// Snapshotter is implemented by gauges whose readings can be copied in a snapshot
+type Snapshotter interface {
+	Snapshot() Snapshot
+}
+
+// Restorer is implemented by gauges whose readings can be replaced with the ones of a snapshot
+type Restorer interface {
+	Restore(Snapshot) error
+}
+
+// ErrInvalidSnapshot gets returned when restoring measurements whose counts do not add up
+var ErrInvalidSnapshot = errors.New("invalid gauge snapshot, counts do not add up")
+
+// ErrRestoreUnsupported gets returned when restoring a snapshot in a gauge that does not implement Restorer
+var ErrRestoreUnsupported = errors.New("gauge does not support restoring snapshots")
+
+// LocalSnapshot copies the readings logged in the gauge itself, leaving out the remote readings of a UnionGauge.
+// ok is false when the gauge does not implement Snapshotter.
+func LocalSnapshot(gauge Gauge) (snapshot Snapshot, ok bool) {
+	if union, isUnion := gauge.(*UnionGauge); isUnion {
+		gauge = union.Local()
+	}
+	snapshotter, ok := gauge.(Snapshotter)
+	if !ok {
+		return Snapshot{}, false
+	}
+	return snapshotter.Snapshot(), true
+}
+
+// Aggregate sums the measurements of the snapshot
+func (s Snapshot) Aggregate() Aggregate {
+	var total Aggregate
+	for _, measurement := range s.Measurements {
+		total = total.Merge(measurement)
+	}
+	return total
+}
+
+// Merge adds the readings of both snapshots, aligning their most recent measurements. The result is as long as
+// the longest of the two.
+func (s Snapshot) Merge(other Snapshot) Snapshot {
+	longest, shortest := s.Measurements, other.Measurements
+	if len(shortest) > len(longest) {
+		longest, shortest = shortest, longest
+	}
+
+	merged := Snapshot{Measurements: make([]Aggregate, len(longest))}
+	copy(merged.Measurements, longest)
+
+	offset := len(longest) - len(shortest)
+	for i, measurement := range shortest {
+		merged.Measurements[offset+i] = merged.Measurements[offset+i].Merge(measurement)
+	}
+	return merged
+}
+
+// Merge adds the counts of both aggregates
+func (a Aggregate) Merge(other Aggregate) Aggregate {
+	return Aggregate{
+		RequestCount: a.RequestCount + other.RequestCount,
+		FailureCount: a.FailureCount + other.FailureCount,
+		SuccessCount: a.SuccessCount + other.SuccessCount,
+	}
+}
+
+func (a Aggregate) valid() bool {
+	return a.RequestCount >= 0 && a.FailureCount >= 0 && a.SuccessCount >= 0 &&
+		a.RequestCount == a.FailureCount+a.SuccessCount
+}
@@ -0,0 +1,63 @@
+package gauges_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func TestSnapshotMerge(t *testing.T) {
+	older := gauges.Snapshot{Measurements: []gauges.Aggregate{
+		{RequestCount: 1, FailureCount: 1},
+		{RequestCount: 2, SuccessCount: 2},
+		{RequestCount: 1, SuccessCount: 1},
+	}}
+	shorter := gauges.Snapshot{Measurements: []gauges.Aggregate{
+		{RequestCount: 3, FailureCount: 3},
+	}}
+
+	merged := older.Merge(shorter)
+	expected := gauges.Snapshot{Measurements: []gauges.Aggregate{
+		{RequestCount: 1, FailureCount: 1},
+		{RequestCount: 2, SuccessCount: 2},
+		{RequestCount: 4, FailureCount: 3, SuccessCount: 1},
+	}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Merge expected %+v got %+v", expected, merged)
+	}
+
+	if reversed := shorter.Merge(older); !reflect.DeepEqual(reversed, expected) {
+		t.Errorf("Merge in the other order expected %+v got %+v", expected, reversed)
+	}
+
+	if len(older.Measurements) != 3 || older.Measurements[2].RequestCount != 1 {
+		t.Errorf("Merge must not modify its receiver, got %+v", older)
+	}
+
+	expectedAggregate := gauges.Aggregate{RequestCount: 7, FailureCount: 4, SuccessCount: 3}
+	if aggregate := merged.Aggregate(); aggregate != expectedAggregate {
+		t.Errorf("Aggregate expected %+v got %+v", expectedAggregate, aggregate)
+	}
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	snapshot := gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 2, FailureCount: 1, SuccessCount: 1}}}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("json.Marshal error expected nil got %s", err)
+	}
+
+	expectedJSON := `{"measurements":[{"requests":2,"failures":1,"successes":1}]}`
+	if string(data) != expectedJSON {
+		t.Errorf("json.Marshal expected %s got %s", expectedJSON, data)
+	}
+
+	var decoded gauges.Snapshot
+	json.Unmarshal(data, &decoded)
+	if !reflect.DeepEqual(decoded, snapshot) {
+		t.Errorf("json.Unmarshal expected %+v got %+v", snapshot, decoded)
+	}
+}
@@ -0,0 +1,115 @@
+package gauges
+
+import (
+	"sort"
+	"sync"
+)
+
+// UnionGauge reflects the union of a local gauge and of snapshots received from other sources, such as other
+// instances of the service. Readings are logged in the local gauge, while the aggregate and the snapshot include
+// the remote readings too, so a breaker using the gauge trips on the outcomes seen by all the sources.
+//
+// Remote snapshots are replaced as a whole by SetRemote, it is up to the caller to share LocalSnapshot with the
+// other sources rather than Snapshot(), which would count remote readings twice. It is safe for concurrent use.
+type UnionGauge struct {
+	mutex   sync.RWMutex
+	local   Gauge
+	remotes map[string]Snapshot
+}
+
+var _ Gauge = &UnionGauge{}
+var _ Snapshotter = &UnionGauge{}
+var _ Restorer = &UnionGauge{}
+
+func NewUnionGauge(local Gauge) *UnionGauge {
+	return &UnionGauge{
+		local:   local,
+		remotes: map[string]Snapshot{},
+	}
+}
+
+// Local returns the gauge the readings are logged in
+func (g *UnionGauge) Local() Gauge {
+	return g.local
+}
+
+// SetRemote replaces the readings of the source
+func (g *UnionGauge) SetRemote(source string, snapshot Snapshot) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.remotes[source] = snapshot
+}
+
+// RemoveRemote forgets the readings of the source
+func (g *UnionGauge) RemoveRemote(source string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.remotes, source)
+}
+
+// Sources returns the sources whose readings are included, sorted
+func (g *UnionGauge) Sources() []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	sources := make([]string, 0, len(g.remotes))
+	for source := range g.remotes {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func (g *UnionGauge) LogReading(outcome Outcome) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.local.LogReading(outcome)
+}
+
+func (g *UnionGauge) OverallAggregate() Aggregate {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	total := g.local.OverallAggregate()
+	for _, remote := range g.remotes {
+		total = total.Merge(remote.Aggregate())
+	}
+	return total
+}
+
+// Snapshot merges the readings of the local gauge, when it implements Snapshotter, with the remote ones
+func (g *UnionGauge) Snapshot() Snapshot {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	var snapshot Snapshot
+	if snapshotter, ok := g.local.(Snapshotter); ok {
+		snapshot = snapshotter.Snapshot()
+	}
+	for _, remote := range g.remotes {
+		snapshot = snapshot.Merge(remote)
+	}
+	return snapshot
+}
+
+// Reset clears the local gauge and forgets the remote readings, the breaker resets its gauge when it starts
+// probing a dependency again and older readings from other sources no longer apply
+func (g *UnionGauge) Reset() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.local.Reset()
+	g.remotes = map[string]Snapshot{}
+}
+
+// Restore restores the local gauge, remote readings are left as they are
+func (g *UnionGauge) Restore(snapshot Snapshot) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	restorer, ok := g.local.(Restorer)
+	if !ok {
+		return ErrRestoreUnsupported
+	}
+	return restorer.Restore(snapshot)
+}
@@ -0,0 +1,62 @@
+package gauges_test
+
+import (
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func TestUnionGauge(t *testing.T) {
+	newGauge := func() *gauges.UnionGauge {
+		gauge := gauges.NewUnionGauge(gauges.NewFixedWindowGauge(3))
+		gauge.LogReading(gauges.Success)
+		gauge.SetRemote("orders-2", gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 2, FailureCount: 2}}})
+		gauge.SetRemote("orders-3", gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 1, FailureCount: 1}}})
+		return gauge
+	}
+
+	t.Run("TestOverallAggregate", func(t *testing.T) {
+		gauge := newGauge()
+
+		expectedAggregate := gauges.Aggregate{RequestCount: 4, FailureCount: 3, SuccessCount: 1}
+		if aggregate := gauge.OverallAggregate(); aggregate != expectedAggregate {
+			t.Errorf("OverallAggregate expected %+v got %+v", expectedAggregate, aggregate)
+		}
+
+		expectedLocal := gauges.Aggregate{RequestCount: 1, SuccessCount: 1}
+		if local := gauge.Local().OverallAggregate(); local != expectedLocal {
+			t.Errorf("Local().OverallAggregate expected %+v got %+v", expectedLocal, local)
+		}
+
+		if snapshot := gauge.Snapshot(); snapshot.Aggregate() != expectedAggregate {
+			t.Errorf("Snapshot().Aggregate expected %+v got %+v", expectedAggregate, snapshot.Aggregate())
+		}
+	})
+
+	t.Run("TestSetRemoteReplaces", func(t *testing.T) {
+		gauge := newGauge()
+		gauge.SetRemote("orders-2", gauges.Snapshot{})
+		gauge.RemoveRemote("orders-3")
+
+		expectedAggregate := gauges.Aggregate{RequestCount: 1, SuccessCount: 1}
+		if aggregate := gauge.OverallAggregate(); aggregate != expectedAggregate {
+			t.Errorf("OverallAggregate expected %+v got %+v", expectedAggregate, aggregate)
+		}
+
+		if sources := gauge.Sources(); len(sources) != 1 || sources[0] != "orders-2" {
+			t.Errorf("Sources expected [orders-2] got %v", sources)
+		}
+	})
+
+	t.Run("TestReset", func(t *testing.T) {
+		gauge := newGauge()
+		gauge.Reset()
+
+		if aggregate := gauge.OverallAggregate(); aggregate != (gauges.Aggregate{}) {
+			t.Errorf("OverallAggregate after Reset expected empty got %+v", aggregate)
+		}
+		if sources := gauge.Sources(); len(sources) != 0 {
+			t.Errorf("Sources after Reset expected none got %v", sources)
+		}
+	})
+}
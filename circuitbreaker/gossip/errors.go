@@ -0,0 +1,24 @@
+package gossip
+
+import (
+	"fmt"
+)
+
+// ErrDuplicateBreaker gets returned when registering a breaker whose name is already taken
+type ErrDuplicateBreaker struct {
+	Name string
+}
+
+func (e ErrDuplicateBreaker) Error() string {
+	return fmt.Sprintf("circuit breaker %s is already registered", e.Name)
+}
+
+// ErrUnexpectedStatus gets returned when a peer does not accept a digest
+type ErrUnexpectedStatus struct {
+	Peer   string
+	Status int
+}
+
+func (e ErrUnexpectedStatus) Error() string {
+	return fmt.Sprintf("gossip peer %s answered with status %d", e.Peer, e.Status)
+}
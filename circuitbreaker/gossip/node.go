@@ -0,0 +1,522 @@
+// Package gossip shares the state of breakers between instances of a service without any external store.
+//
+// Every node serves an endpoint receiving the digests of its peers, and periodically sends its own digest, the
+// state and gauge aggregate of each of its breakers, to every peer of a static list. Peers answer with their
+// digest, so a single exchange updates both sides. Each node then merges the digests into a cluster view per
+// breaker, weighting every node with a configurable Weight, and opens its closed breaker when the cluster
+// aggregate crosses the thresholds of the breaker or when the weighted share of nodes reporting it open reaches
+// the quorum.
+//
+// Digests older than the maximum age are left out of the view, so nodes that stop answering stop counting. Only
+// digests sent from the hosts of the peers are accepted, and breakers opened because of the cluster view do not
+// count towards the quorum, so that they cannot keep each other open.
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultInterval is how often digests are sent to peers
+	DefaultInterval = time.Second
+	// DefaultMaxAge is how long the digest of a peer counts after it was received
+	DefaultMaxAge = 5 * time.Second
+	// DefaultQuorum is the weighted share of nodes that must report a breaker open for the others to open theirs
+	DefaultQuorum = 0.5
+	// MaxDigestSize is the size in bytes over which a digest is refused
+	MaxDigestSize = 1 << 20
+)
+
+// Weight returns how much the digest of a node counts in the cluster view, age is how long ago it was received
+// and is zero for the local node
+type Weight func(node string, local bool, age time.Duration) float64
+
+// EqualWeight counts every node the same
+func EqualWeight(node string, local bool, age time.Duration) float64 {
+	return 1
+}
+
+// DecayingWeight halves the weight of a peer digest every halfLife, the local node always weighs 1
+func DecayingWeight(halfLife time.Duration) Weight {
+	return func(node string, local bool, age time.Duration) float64 {
+		if local {
+			return 1
+		}
+		return math.Pow(0.5, float64(age)/float64(halfLife))
+	}
+}
+
+// Option customises the node
+type Option func(*Node)
+
+// WithPeers sets the gossip endpoints of the other nodes, digests are only accepted from their hosts
+func WithPeers(urls ...string) Option {
+	return func(n *Node) {
+		n.peers = urls
+	}
+}
+
+// WithInterval sets how often digests are sent to peers
+func WithInterval(interval time.Duration) Option {
+	return func(n *Node) {
+		n.interval = interval
+	}
+}
+
+// WithMaxAge sets how long the digest of a peer counts after it was received
+func WithMaxAge(age time.Duration) Option {
+	return func(n *Node) {
+		n.maxAge = age
+	}
+}
+
+// WithWeight sets how much each node counts in the cluster view, EqualWeight by default
+func WithWeight(weight Weight) Option {
+	return func(n *Node) {
+		n.weight = weight
+	}
+}
+
+// WithQuorum sets the weighted share of nodes reporting a breaker open above which the node opens its own, a
+// quorum above 1 never opens breakers because of the state of others
+func WithQuorum(quorum float64) Option {
+	return func(n *Node) {
+		n.quorum = quorum
+	}
+}
+
+// WithNodeID sets the id the node sends its digests under, a random one is used by default
+func WithNodeID(id string) Option {
+	return func(n *Node) {
+		n.id = id
+	}
+}
+
+// WithHTTPClient sets the client used to reach peers, it should have a timeout shorter than the interval
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Node) {
+		n.client = client
+	}
+}
+
+// WithLogger logs breakers opened because of the cluster view and peers that cannot be reached
+func WithLogger(logger *slog.Logger) Option {
+	return func(n *Node) {
+		n.logger = logger
+	}
+}
+
+// Digest is what nodes exchange
+type Digest struct {
+	Node     string    `json:"node"`
+	Breakers []Summary `json:"breakers"`
+}
+
+// Summary is the state of a breaker on a node
+type Summary struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Requests  int    `json:"requests"`
+	Failures  int    `json:"failures"`
+	Successes int    `json:"successes"`
+	// Gossiped is set when the breaker was opened because of the cluster view rather than its own outcomes
+	Gossiped bool `json:"gossiped,omitempty"`
+}
+
+// View is the merged state of a breaker across the nodes whose digest is recent enough
+type View struct {
+	// Aggregate is the weighted sum of the aggregates of every node, rounded
+	Aggregate gauges.Aggregate
+	// Nodes is the number of nodes reporting the breaker, the local one included
+	Nodes int
+	// OpenShare is the weighted share of those nodes reporting the breaker open, nodes whose breaker was opened
+	// because of the cluster view are left out of it
+	OpenShare float64
+}
+
+type received struct {
+	digest Digest
+	at     time.Time
+}
+
+// Node gossips the state of the breakers registered with it, it is an http.Handler receiving the digests of
+// peers and must be mounted where they send them
+type Node struct {
+	id       string
+	peers    []string
+	interval time.Duration
+	maxAge   time.Duration
+	weight   Weight
+	quorum   float64
+	client   *http.Client
+	logger   *slog.Logger
+
+	mutex    sync.Mutex
+	breakers map[string]*circuitbreaker.Breaker
+	digests  map[string]received
+	// tripped holds the end of the cooldown of the breakers opened because of the cluster view
+	tripped map[string]time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ http.Handler = &Node{}
+
+// NewNode starts gossiping with the peers, Close stops it
+func NewNode(opts ...Option) *Node {
+	n := &Node{
+		id:       uuid.NewString(),
+		interval: DefaultInterval,
+		maxAge:   DefaultMaxAge,
+		weight:   EqualWeight,
+		quorum:   DefaultQuorum,
+		client:   &http.Client{Timeout: DefaultInterval},
+		breakers: map[string]*circuitbreaker.Breaker{},
+		digests:  map[string]received{},
+		tripped:  map[string]time.Time{},
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	n.wg.Add(1)
+	go n.loop(ctx)
+	return n
+}
+
+// Register starts gossiping the state of the breaker, names must be unique and match across nodes
+func (n *Node) Register(breaker *circuitbreaker.Breaker) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	name := breaker.Name()
+	if _, ok := n.breakers[name]; ok {
+		return ErrDuplicateBreaker{Name: name}
+	}
+	n.breakers[name] = breaker
+	return nil
+}
+
+// Close stops gossiping, the breakers keep working on their own
+func (n *Node) Close() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+// Peers returns the ids of the peers whose digest is recent enough to count, sorted
+func (n *Node) Peers() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var peers []string
+	now := time.Now()
+	for id, r := range n.digests {
+		if now.Sub(r.at) <= n.maxAge {
+			peers = append(peers, id)
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// View merges the local state of the named breaker with the recent digests of peers
+func (n *Node) View(name string) (View, bool) {
+	n.mutex.Lock()
+	breaker, ok := n.breakers[name]
+	digests := make([]received, 0, len(n.digests))
+	for _, r := range n.digests {
+		digests = append(digests, r)
+	}
+	n.mutex.Unlock()
+
+	if !ok {
+		return View{}, false
+	}
+
+	var failures, successes, open, voters float64
+	add := func(node string, local bool, age time.Duration, summary Summary) {
+		weight := n.weight(node, local, age)
+		failures += weight * float64(summary.Failures)
+		successes += weight * float64(summary.Successes)
+		if summary.Gossiped {
+			return
+		}
+		voters += weight
+		if summary.State == circuitbreaker.Open.String() {
+			open += weight
+		}
+	}
+
+	add(n.id, true, 0, n.summarize(breaker))
+	view := View{Nodes: 1}
+
+	now := time.Now()
+	for _, r := range digests {
+		age := now.Sub(r.at)
+		if age > n.maxAge {
+			continue
+		}
+		for _, summary := range r.digest.Breakers {
+			if summary.Name == name {
+				add(r.digest.Node, false, age, summary)
+				view.Nodes++
+			}
+		}
+	}
+
+	view.Aggregate = gauges.Aggregate{
+		FailureCount: int(math.Round(failures)),
+		SuccessCount: int(math.Round(successes)),
+	}
+	view.Aggregate.RequestCount = view.Aggregate.FailureCount + view.Aggregate.SuccessCount
+	if voters > 0 {
+		view.OpenShare = open / voters
+	}
+	return view, true
+}
+
+// ServeHTTP receives the digest of a peer and answers with the digest of the node
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !n.fromPeer(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var digest Digest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDigestSize)).Decode(&digest); err != nil || digest.Node == "" {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid digest", http.StatusBadRequest)
+		return
+	}
+	n.receive(digest)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.digest())
+}
+
+// fromPeer reports whether the request was sent from the host of one of the peers
+func (n *Node) fromPeer(r *http.Request) bool {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	for _, peer := range n.peers {
+		u, err := url.Parse(peer)
+		if err != nil {
+			continue
+		}
+		host := u.Hostname()
+		if ip := net.ParseIP(host); ip != nil {
+			if ip.Equal(net.ParseIP(remote)) {
+				return true
+			}
+			continue
+		}
+		addrs, err := net.DefaultResolver.LookupHost(r.Context(), host)
+		if err == nil && slices.Contains(addrs, remote) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) receive(digest Digest) {
+	if digest.Node == n.id {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.digests[digest.Node] = received{digest: digest, at: time.Now()}
+}
+
+func (n *Node) digest() Digest {
+	n.mutex.Lock()
+	breakers := make([]*circuitbreaker.Breaker, 0, len(n.breakers))
+	for _, breaker := range n.breakers {
+		breakers = append(breakers, breaker)
+	}
+	n.mutex.Unlock()
+
+	digest := Digest{Node: n.id, Breakers: make([]Summary, 0, len(breakers))}
+	for _, breaker := range breakers {
+		digest.Breakers = append(digest.Breakers, n.summarize(breaker))
+	}
+	return digest
+}
+
+func (n *Node) summarize(breaker *circuitbreaker.Breaker) Summary {
+	aggregate := breaker.Aggregate()
+	summary := Summary{
+		Name:      breaker.Name(),
+		State:     breaker.State().String(),
+		Requests:  aggregate.RequestCount,
+		Failures:  aggregate.FailureCount,
+		Successes: aggregate.SuccessCount,
+	}
+
+	// the breaker is still in the open period the cluster view started, rather than one of its own
+	until, open := breaker.OpenUntil()
+	n.mutex.Lock()
+	tripped, ok := n.tripped[summary.Name]
+	n.mutex.Unlock()
+	summary.Gossiped = open && ok && until.Equal(tripped)
+	return summary
+}
+
+func (n *Node) loop(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.exchange(ctx)
+			n.prune()
+			n.evaluate()
+		}
+	}
+}
+
+// exchange sends the digest to every peer in parallel and keeps their answers
+func (n *Node) exchange(ctx context.Context) {
+	body, _ := json.Marshal(n.digest())
+
+	var wg sync.WaitGroup
+	for _, peer := range n.peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+
+			digest, err := n.send(ctx, peer, body)
+			if err != nil {
+				if n.logger != nil && ctx.Err() == nil {
+					n.logger.Debug("circuit breaker gossip peer unreachable", slog.String("peer", peer), slog.String("error", err.Error()))
+				}
+				return
+			}
+			n.receive(digest)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+func (n *Node) send(ctx context.Context, peer string, body []byte) (Digest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(body))
+	if err != nil {
+		return Digest{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Digest{}, ErrUnexpectedStatus{Peer: peer, Status: resp.StatusCode}
+	}
+
+	var digest Digest
+	err = json.NewDecoder(io.LimitReader(resp.Body, MaxDigestSize)).Decode(&digest)
+	return digest, err
+}
+
+// prune forgets the digests that are too old to count, nodes get a new id when they restart
+func (n *Node) prune() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	for id, r := range n.digests {
+		if now.Sub(r.at) > n.maxAge {
+			delete(n.digests, id)
+		}
+	}
+}
+
+// evaluate only opens closed breakers, one that is half-open already waited for its cooldown and is better
+// placed to tell whether the dependency recovered
+func (n *Node) evaluate() {
+	n.mutex.Lock()
+	breakers := make([]*circuitbreaker.Breaker, 0, len(n.breakers))
+	for _, breaker := range n.breakers {
+		breakers = append(breakers, breaker)
+	}
+	n.mutex.Unlock()
+
+	for _, breaker := range breakers {
+		if breaker.State() != circuitbreaker.Closed {
+			continue
+		}
+
+		view, ok := n.View(breaker.Name())
+		if !ok || view.Nodes < 2 {
+			continue
+		}
+
+		exceeded := breaker.CurrentSettings().Thresholds.Exceeded(view.Aggregate)
+		if !(exceeded || view.OpenShare >= n.quorum) || !breaker.Trip() {
+			continue
+		}
+		if until, ok := breaker.OpenUntil(); ok {
+			n.mutex.Lock()
+			n.tripped[breaker.Name()] = until
+			n.mutex.Unlock()
+		}
+		if n.logger != nil {
+			n.logger.Warn("circuit breaker opened by the cluster view",
+				slog.String("name", breaker.Name()),
+				slog.Int("nodes", view.Nodes),
+				slog.Float64("open_share", view.OpenShare),
+				aggregateAttr(view.Aggregate),
+			)
+		}
+	}
+}
+
+func aggregateAttr(aggregate gauges.Aggregate) slog.Attr {
+	return slog.Group("cluster_aggregate",
+		slog.Int("requests", aggregate.RequestCount),
+		slog.Int("failures", aggregate.FailureCount),
+		slog.Float64("failure_rate", aggregate.FailureRate()),
+	)
+}
@@ -0,0 +1,210 @@
+package gossip_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gossip"
+)
+
+const interval = 10 * time.Millisecond
+
+type member struct {
+	node     *gossip.Node
+	payments *circuitbreaker.Breaker
+	server   *httptest.Server
+}
+
+// newCluster starts size nodes on loopback, each listing all the others as peers
+func newCluster(t *testing.T, size int, opts ...gossip.Option) []*member {
+	t.Helper()
+
+	members := make([]*member, size)
+	urls := make([]string, size)
+	for i := range members {
+		m := &member{}
+		m.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.node.ServeHTTP(w, r)
+		}))
+		members[i] = m
+		urls[i] = "http://" + m.server.Listener.Addr().String()
+	}
+
+	for i, m := range members {
+		var peers []string
+		for j, url := range urls {
+			if j != i {
+				peers = append(peers, url)
+			}
+		}
+
+		nodeOpts := append([]gossip.Option{gossip.WithPeers(peers...), gossip.WithInterval(interval)}, opts...)
+		m.node = gossip.NewNode(nodeOpts...)
+		t.Cleanup(m.node.Close)
+
+		settings, _ := circuitbreaker.NewSettings("payments",
+			circuitbreaker.WithMinRequest(10),
+			circuitbreaker.WithFailureRate(50),
+			circuitbreaker.WithCooldownDuration(time.Minute),
+		)
+		m.payments, _ = circuitbreaker.NewBreakerWithSettings(settings)
+		if err := m.node.Register(m.payments); err != nil {
+			t.Fatalf("Node.Register, expected no err, got %s", err)
+		}
+
+		m.server.Start()
+		t.Cleanup(m.server.Close)
+	}
+	return members
+}
+
+func (m *member) fail(times int) {
+	for i := 0; i < times; i++ {
+		m.payments.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+			return nil, errors.New("payments unavailable")
+		})
+	}
+}
+
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s, expected within 2s", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClusterAggregateOpensBreakers(t *testing.T) {
+	cluster := newCluster(t, 3, gossip.WithQuorum(2))
+
+	// no node sees enough requests to open on its own
+	for _, m := range cluster {
+		m.fail(4)
+	}
+
+	for _, m := range cluster {
+		eventually(t, "every node opens", func() bool { return m.payments.State() == circuitbreaker.Open })
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	cluster := newCluster(t, 3)
+	eventually(t, "nodes know each other", func() bool { return len(cluster[2].node.Peers()) == 2 })
+
+	cluster[0].payments.ForceState(circuitbreaker.Open)
+	time.Sleep(10 * interval)
+	if state := cluster[2].payments.State(); state != circuitbreaker.Closed {
+		t.Errorf("one node of three open, expected %s, got %s", circuitbreaker.Closed, state)
+	}
+
+	cluster[1].payments.ForceState(circuitbreaker.Open)
+	eventually(t, "two nodes of three open the third", func() bool { return cluster[2].payments.State() == circuitbreaker.Open })
+}
+
+func TestWeight(t *testing.T) {
+	ignorePeers := func(node string, local bool, age time.Duration) float64 {
+		if local {
+			return 1
+		}
+		return 0
+	}
+	cluster := newCluster(t, 2, gossip.WithWeight(ignorePeers))
+	eventually(t, "nodes know each other", func() bool { return len(cluster[1].node.Peers()) == 1 })
+
+	cluster[0].fail(10)
+	time.Sleep(10 * interval)
+
+	view, _ := cluster[1].node.View("payments")
+	if view.Nodes != 2 || view.Aggregate.RequestCount != 0 || view.OpenShare != 0 {
+		t.Errorf("view with peers weighing nothing, expected 2 nodes and nothing else, got %+v", view)
+	}
+	if state := cluster[1].payments.State(); state != circuitbreaker.Closed {
+		t.Errorf("state with peers weighing nothing, expected %s, got %s", circuitbreaker.Closed, state)
+	}
+}
+
+func TestUnreachablePeer(t *testing.T) {
+	cluster := newCluster(t, 2, gossip.WithMaxAge(5*interval))
+	eventually(t, "nodes know each other", func() bool { return len(cluster[0].node.Peers()) == 1 })
+
+	cluster[1].server.Close()
+	cluster[1].node.Close()
+	eventually(t, "unreachable peer is forgotten", func() bool { return len(cluster[0].node.Peers()) == 0 })
+
+	cluster[0].fail(10)
+	if state := cluster[0].payments.State(); state != circuitbreaker.Open {
+		t.Errorf("local state without peers, expected %s, got %s", circuitbreaker.Open, state)
+	}
+}
+
+func TestDecayingWeight(t *testing.T) {
+	weight := gossip.DecayingWeight(time.Second)
+	if w := weight("local", true, time.Hour); w != 1 {
+		t.Errorf("DecayingWeight of the local node, expected 1, got %f", w)
+	}
+	if w := weight("peer", false, time.Second); w != 0.5 {
+		t.Errorf("DecayingWeight after one half life, expected 0.5, got %f", w)
+	}
+}
+
+func TestGossipedTripsDoNotVote(t *testing.T) {
+	cluster := newCluster(t, 4)
+	eventually(t, "nodes know each other", func() bool { return len(cluster[3].node.Peers()) == 3 })
+
+	cluster[0].payments.ForceState(circuitbreaker.Open)
+	cluster[1].payments.ForceState(circuitbreaker.Open)
+	eventually(t, "two nodes of four open the others", func() bool {
+		return cluster[2].payments.State() == circuitbreaker.Open && cluster[3].payments.State() == circuitbreaker.Open
+	})
+
+	// the nodes opened by the cluster view must not count once the ones that opened first recover, pinning keeps
+	// the view from opening them again before every node got their new state
+	cluster[0].payments.Pin(circuitbreaker.Closed, time.Now().Add(time.Hour), "recovered")
+	cluster[1].payments.Pin(circuitbreaker.Closed, time.Now().Add(time.Hour), "recovered")
+	time.Sleep(10 * interval)
+	if view, _ := cluster[0].node.View("payments"); view.Nodes != 4 || view.OpenShare != 0 {
+		t.Errorf("view with only gossiped trips, expected 4 nodes and an open share of 0, got %+v", view)
+	}
+}
+
+func TestUnknownSender(t *testing.T) {
+	node := gossip.NewNode(gossip.WithPeers("http://198.51.100.7:8080"), gossip.WithInterval(time.Hour))
+	defer node.Close()
+
+	body := `{"node":"intruder","breakers":[{"name":"payments","state":"open"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/gossip", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	node.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("digest from a host that is not a peer, expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	if peers := node.Peers(); len(peers) != 0 {
+		t.Errorf("node.Peers, expected the digest to be dropped, got %v", peers)
+	}
+}
+
+func TestDigestTooLarge(t *testing.T) {
+	node := gossip.NewNode(gossip.WithPeers("http://198.51.100.7:8080"), gossip.WithInterval(time.Hour))
+	defer node.Close()
+
+	body := `{"node":"` + strings.Repeat("a", gossip.MaxDigestSize) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/gossip", strings.NewReader(body))
+	req.RemoteAddr = "198.51.100.7:40000"
+	rec := httptest.NewRecorder()
+	node.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("digest over the max size, expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
@@ -0,0 +1,288 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultHedgePercentile hedges calls slower than 95% of the recent successful calls
+const DefaultHedgePercentile float64 = 95.0
+
+// DefaultHedgeDelay is waited before hedging until enough latencies were recorded
+const DefaultHedgeDelay time.Duration = 100 * time.Millisecond
+
+// DefaultHedgeMinSamples is the number of latencies needed before the percentile is used
+const DefaultHedgeMinSamples int = 20
+
+// DefaultHedgeWindow is the number of latencies the percentile is taken from
+const DefaultHedgeWindow int = 100
+
+// DefaultHedgeMaxFailureRate disables hedging once more than 5% of the calls held by the gauge failed
+const DefaultHedgeMaxFailureRate float64 = 5.0
+
+// errHedgeLost cancels the attempts that were still running once another attempt of the call succeeded
+var errHedgeLost = errors.New("circuitbreaker: another attempt of the hedged call succeeded")
+
+// Hedge reduces tail latency by starting another attempt of a call that has not returned after a delay, taken as
+// a percentile of the latency of recent successful calls. The first attempt to succeed is returned and the others
+// are cancelled, their outcome is ignored. Only calls made with ExecuteHedged are hedged, so that only idempotent
+// calls are.
+//
+// Every attempt goes through the breaker like a call of its own, and only the outcome of the call is recorded in
+// the gauge. Hedging stops as soon as the circuit is not closed, or once the failure rate of the gauge goes over
+// MaxFailureRate, since more load would not help a failing dependency.
+type Hedge struct {
+	// Percentile of the latency of recent successful calls waited before hedging, DefaultHedgePercentile when 0
+	Percentile float64
+	// Delay waited before hedging until MinSamples latencies were recorded, DefaultHedgeDelay when 0
+	Delay time.Duration
+	// MinSamples is the number of latencies needed before the percentile is used, DefaultHedgeMinSamples when 0
+	MinSamples int
+	// Window is the number of latencies the percentile is taken from, DefaultHedgeWindow when 0
+	Window int
+	// MaxHedges is the number of attempts started on top of the first one, 1 when 0
+	MaxHedges int
+	// MaxFailureRate is the failure rate of the gauge, in percent, over which calls are no longer hedged,
+	// DefaultHedgeMaxFailureRate when 0
+	MaxFailureRate float64
+}
+
+// WithHedge hedges the calls made with ExecuteHedged, see Hedge
+func WithHedge(hedge Hedge) SettingsOption {
+	return func(s *Settings) {
+		if hedge.Percentile == 0 {
+			hedge.Percentile = DefaultHedgePercentile
+		}
+		if hedge.Delay == 0 {
+			hedge.Delay = DefaultHedgeDelay
+		}
+		if hedge.MinSamples == 0 {
+			hedge.MinSamples = DefaultHedgeMinSamples
+		}
+		if hedge.Window == 0 {
+			hedge.Window = DefaultHedgeWindow
+		}
+		if hedge.MaxHedges == 0 {
+			hedge.MaxHedges = 1
+		}
+		if hedge.MaxFailureRate == 0 {
+			hedge.MaxFailureRate = DefaultHedgeMaxFailureRate
+		}
+		s.Hedge = &hedge
+	}
+}
+
+func (h Hedge) validate() error {
+	if h.Percentile <= 0 || h.Percentile > 100 || math.IsNaN(h.Percentile) {
+		return ErrInvalidSettingParam{Param: "Hedge.Percentile", Val: h.Percentile}
+	}
+	if h.Delay <= 0 {
+		return ErrInvalidSettingParam{Param: "Hedge.Delay", Val: h.Delay}
+	}
+	if h.Window <= 0 {
+		return ErrInvalidSettingParam{Param: "Hedge.Window", Val: h.Window}
+	}
+	if h.MinSamples <= 0 || h.MinSamples > h.Window {
+		return ErrInvalidSettingParam{Param: "Hedge.MinSamples", Val: h.MinSamples}
+	}
+	if h.MaxHedges <= 0 {
+		return ErrInvalidSettingParam{Param: "Hedge.MaxHedges", Val: h.MaxHedges}
+	}
+	if h.MaxFailureRate < 0 || h.MaxFailureRate > 100 || math.IsNaN(h.MaxFailureRate) {
+		return ErrInvalidSettingParam{Param: "Hedge.MaxFailureRate", Val: h.MaxFailureRate}
+	}
+	return nil
+}
+
+// ExecuteHedged is like ExecuteContext but hedges the call when the settings have a Hedge, see Hedge. The handler
+// may run several times concurrently and must be idempotent.
+func (b *Breaker) ExecuteHedged(ctx context.Context, handler ExecuteContextHandler) (*http.Response, error) {
+	return b.execute(ctx, handler, true)
+}
+
+// HedgeDelay returns how long calls made with ExecuteHedged currently run before being hedged, 0 without a Hedge
+func (b *Breaker) HedgeDelay() time.Duration {
+	hedge := b.CurrentSettings().Hedge
+	if hedge == nil {
+		return 0
+	}
+	return b.latencies.percentile(*hedge)
+}
+
+// hedging reports whether calls can currently be hedged
+func (b *Breaker) hedging() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	hedge := b.Settings.Hedge
+	if hedge == nil || b.stateMachine.State() != Closed {
+		return false
+	}
+	aggregate := b.Settings.Gauge.OverallAggregate()
+	return aggregate.FailureRate() <= hedge.MaxFailureRate
+}
+
+// hedgedRun is the outcome of one of the attempts of a hedged call
+type hedgedRun struct {
+	attemptOutcome
+	// run is the index of the attempt among the ones started for the call
+	run int
+}
+
+// hedgedAttempt runs an attempt, starting more of them while it is slower than the hedge delay. The first
+// successful attempt is returned, or the last one that finished if none succeeded, a rejected attempt being only
+// returned if every attempt was rejected.
+func (b *Breaker) hedgedAttempt(ctx context.Context, settings *Settings, handler ExecuteContextHandler, attempt int) attemptOutcome {
+	hedge := settings.Hedge
+	if hedge == nil || !b.hedging() {
+		return b.attempt(ctx, settings, handler, attempt)
+	}
+
+	// every attempt has its own context, so that the losers are cancelled without the winner
+	cancels := make([]context.CancelCauseFunc, 0, hedge.MaxHedges+1)
+	results := make(chan hedgedRun, hedge.MaxHedges+1)
+	run := func() {
+		attemptCtx, cancel := context.WithCancelCause(ctx)
+		cancels = append(cancels, cancel)
+		go func(run int) {
+			results <- hedgedRun{attemptOutcome: b.attempt(attemptCtx, settings, handler, attempt), run: run}
+		}(len(cancels) - 1)
+	}
+	run()
+
+	delay := b.latencies.percentile(*hedge)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var outcome *hedgedRun
+	running, hedges := 1, 0
+	for running > 0 {
+		select {
+		case finished := <-results:
+			running--
+			if finished.result == attemptSucceeded {
+				if outcome != nil {
+					b.discardHedge(outcome.attemptOutcome)
+				}
+				b.drainHedges(results, running)
+				return won(finished, cancels)
+			}
+			if outcome == nil || finished.result != attemptRejected {
+				if outcome != nil {
+					b.discardHedge(outcome.attemptOutcome)
+				}
+				outcome = &finished
+			}
+		case <-timer.C:
+			if hedges == hedge.MaxHedges || !b.hedging() {
+				continue
+			}
+			hedges++
+			running++
+			b.counter.recordHedge()
+			b.publish(Event{Type: Hedged, State: b.State(), Duration: delay, Attempt: attempt})
+			run()
+			timer.Reset(delay)
+		}
+	}
+	return won(*outcome, cancels)
+}
+
+// won cancels the context of every attempt but the returned one, whose context is only cancelled once its body is
+// closed so that the body can still be read
+func won(finished hedgedRun, cancels []context.CancelCauseFunc) attemptOutcome {
+	for run, cancel := range cancels {
+		if run != finished.run {
+			cancel(errHedgeLost)
+		}
+	}
+	outcome, cancel := finished.attemptOutcome, cancels[finished.run]
+	if outcome.resp == nil || outcome.resp.Body == nil {
+		cancel(nil)
+		return outcome
+	}
+	outcome.resp.Body = cancelOnClose{ReadCloser: outcome.resp.Body, cancel: cancel}
+	return outcome
+}
+
+// cancelOnClose cancels the context of the attempt that returned the body once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel(nil)
+	return err
+}
+
+// discardHedge gives back the probe slot of an attempt whose outcome is not returned, and closes its response
+func (b *Breaker) discardHedge(outcome attemptOutcome) {
+	b.releaseProbe(outcome.probe)
+	if outcome.resp != nil && outcome.resp.Body != nil {
+		outcome.resp.Body.Close()
+	}
+}
+
+// drainHedges closes the responses of the attempts still running once they finish, and gives back their probe
+// slots
+func (b *Breaker) drainHedges(results <-chan hedgedRun, running int) {
+	if running == 0 {
+		return
+	}
+	go func() {
+		for range running {
+			finished := <-results
+			b.discardHedge(finished.attemptOutcome)
+		}
+	}()
+}
+
+// hedgeLost reports whether the attempt was cancelled because another attempt of the call succeeded
+func hedgeLost(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+// latencyWindow keeps the latencies of the latest successful calls
+type latencyWindow struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func (w *latencyWindow) record(latency time.Duration, size int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if cap(w.latencies) != size {
+		// the window was resized by new settings
+		w.latencies, w.next = make([]time.Duration, 0, size), 0
+	}
+	if len(w.latencies) < size {
+		w.latencies = append(w.latencies, latency)
+		return
+	}
+	w.latencies[w.next] = latency
+	w.next = (w.next + 1) % size
+}
+
+// percentile returns the hedge delay, the Delay of the hedge until it has enough latencies
+func (w *latencyWindow) percentile(hedge Hedge) time.Duration {
+	w.mutex.Lock()
+	latencies := slices.Clone(w.latencies)
+	w.mutex.Unlock()
+
+	if len(latencies) < hedge.MinSamples {
+		return hedge.Delay
+	}
+	slices.Sort(latencies)
+	rank := int(math.Ceil(hedge.Percentile/100*float64(len(latencies)))) - 1
+	return latencies[max(rank, 0)]
+}
@@ -0,0 +1,183 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// slowHandler takes the given time to succeed, or returns the error of the context if it is done first
+func slowHandler(delay time.Duration, calls *atomic.Int32) circuitbreaker.ExecuteContextHandler {
+	return func(ctx context.Context, name string) (*http.Response, error) {
+		calls.Add(1)
+		select {
+		case <-time.After(delay):
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestHedge(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithHedge(circuitbreaker.Hedge{Delay: 10 * time.Millisecond}), circuitbreaker.WithMinRequest(100))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	var calls atomic.Int32
+	lost := make(chan struct{})
+	resp, err := cb.ExecuteHedged(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(lost)
+			return nil, ctx.Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("cb.ExecuteHedged, expected the hedge to succeed, got %v", err)
+	}
+
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("first attempt, expected it to be cancelled")
+	}
+
+	events := receiveEvents(t, sub, 5)
+	if hedged := events[1]; hedged.Type != circuitbreaker.Hedged || hedged.Duration != 10*time.Millisecond {
+		t.Errorf("event, expected hedged after 10ms, got %s after %s", hedged.Type, hedged.Duration)
+	}
+	if ignored := events[4]; ignored.Type != circuitbreaker.CallIgnored {
+		t.Errorf("event, expected the cancelled attempt to be ignored, got %s", ignored.Type)
+	}
+
+	if counts := cb.Counts(); counts.Hedges != 1 || counts.Successes != 1 || counts.Ignored != 1 {
+		t.Errorf("cb.Counts, expected 1 hedge, 1 success and 1 ignored, got %+v", counts)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 1 || aggregate.SuccessCount != 1 {
+		t.Errorf("cb.Aggregate, expected a single success, got %d requests and %d successes", aggregate.RequestCount, aggregate.SuccessCount)
+	}
+}
+
+func TestHedgeWinnerBody(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		// the end of the body is only sent once the call returned
+		io.WriteString(w, "hedged ")
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, "response")
+	}))
+	defer server.Close()
+
+	cb := newTestBreaker(t, circuitbreaker.WithHedge(circuitbreaker.Hedge{Delay: 10 * time.Millisecond}), circuitbreaker.WithMinRequest(100))
+	resp, err := cb.ExecuteHedged(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		return server.Client().Do(req)
+	})
+	if err != nil {
+		t.Fatalf("cb.ExecuteHedged, expected no err, got %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "hedged response" {
+		t.Errorf("resp.Body, expected hedged response, got %q with %v", body, err)
+	}
+}
+
+func TestHedgeFailed(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithHedge(circuitbreaker.Hedge{Delay: 5 * time.Millisecond, MaxFailureRate: 100}), circuitbreaker.WithMinRequest(100))
+
+	var calls atomic.Int32
+	_, err := cb.ExecuteHedged(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil, errors.New("failed")
+	})
+	if err == nil || calls.Load() != 2 {
+		t.Errorf("cb.ExecuteHedged, expected both attempts to fail, got %d attempts with %v", calls.Load(), err)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 1 || aggregate.FailureCount != 1 {
+		t.Errorf("cb.Aggregate, expected a single failure, got %d requests and %d failures", aggregate.RequestCount, aggregate.FailureCount)
+	}
+}
+
+func TestHedgeDisabled(t *testing.T) {
+	testCases := map[string]func(cb *circuitbreaker.Breaker){
+		"HalfOpen": func(cb *circuitbreaker.Breaker) {
+			cb.ForceState(circuitbreaker.HalfOpen)
+		},
+		"FailureRate": func(cb *circuitbreaker.Breaker) {
+			cb.ExecuteContext(context.Background(), failingHandler)
+		},
+		"ExecuteContext": func(cb *circuitbreaker.Breaker) {},
+	}
+
+	for name, setup := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cb := newTestBreaker(t, circuitbreaker.WithHedge(circuitbreaker.Hedge{Delay: time.Millisecond}), circuitbreaker.WithMinRequest(100))
+			setup(cb)
+
+			var calls atomic.Int32
+			execute := cb.ExecuteHedged
+			if name == "ExecuteContext" {
+				execute = cb.ExecuteContext
+			}
+			if _, err := execute(context.Background(), slowHandler(20*time.Millisecond, &calls)); err != nil {
+				t.Fatalf("execute, expected no err, got %s", err)
+			}
+			if calls.Load() != 1 || cb.Counts().Hedges != 0 {
+				t.Errorf("execute, expected the call not to be hedged, got %d attempts", calls.Load())
+			}
+		})
+	}
+}
+
+func TestHedgeDelay(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithHedge(circuitbreaker.Hedge{Delay: time.Hour, MinSamples: 1, Window: 1}), circuitbreaker.WithMinRequest(100))
+	if delay := cb.HedgeDelay(); delay != time.Hour {
+		t.Errorf("cb.HedgeDelay without latencies, expected the hedge delay, got %s", delay)
+	}
+
+	var calls atomic.Int32
+	cb.ExecuteHedged(context.Background(), slowHandler(20*time.Millisecond, &calls))
+	if delay := cb.HedgeDelay(); delay < 20*time.Millisecond || delay > time.Second {
+		t.Errorf("cb.HedgeDelay, expected the latency of the call, got %s", delay)
+	}
+}
+
+func TestHedgeSettings(t *testing.T) {
+	testCases := map[string]struct {
+		hedge    circuitbreaker.Hedge
+		expected circuitbreaker.ErrInvalidSettingParam
+	}{
+		"Percentile":     {hedge: circuitbreaker.Hedge{Percentile: 120}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Hedge.Percentile", Val: 120.0}},
+		"MinSamples":     {hedge: circuitbreaker.Hedge{MinSamples: 20, Window: 10}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Hedge.MinSamples", Val: 20}},
+		"MaxFailureRate": {hedge: circuitbreaker.Hedge{MaxFailureRate: -1}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Hedge.MaxFailureRate", Val: -1.0}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithHedge(tc.hedge))
+			if err != tc.expected {
+				t.Errorf("circuitbreaker.NewSettings, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
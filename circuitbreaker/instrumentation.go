@@ -0,0 +1,24 @@
+package circuitbreaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Instrumentation receives a synchronous callback for every decision the breaker takes. The call related
+// callbacks get the context passed to ExecuteContext, so implementations can annotate the caller's span while
+// it is still active. Callbacks run on the hot path and should return quickly.
+type Instrumentation interface {
+	// CallRejected is called when a call was not permitted, state is the state at the time of admission
+	CallRejected(ctx context.Context, name string, state State, err error)
+	// CallPermitted is called right before the handler of an admitted call is run
+	CallPermitted(ctx context.Context, name string, state State)
+	// CallFinished is called once the handler returned and its outcome was classified
+	CallFinished(ctx context.Context, name string, state State, outcome gauges.Outcome, err error, duration time.Duration)
+	// CallIgnored is called once the handler returned and its outcome was left out of the gauge
+	CallIgnored(ctx context.Context, name string, state State, err error, duration time.Duration)
+	// StateChanged is called after the breaker transitioned, whether on its own or when forced
+	StateChanged(name string, from State, to State)
+}
@@ -0,0 +1,43 @@
+package limit
+
+import (
+	"sync"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// AIMD grows the limit by one for every call that succeeds while the limit is in use, and multiplies it by the
+// backoff ratio for every call that fails or is slower than the timeout. It reacts to failures only, which
+// makes it a good fit for dependencies whose latency says little about their load.
+type AIMD struct {
+	mutex  sync.Mutex
+	config config
+	limit  float64
+}
+
+// NewAIMD creates an AIMD limiter, see WithBackoffRatio and WithTimeout
+func NewAIMD(opts ...Option) *AIMD {
+	config := newConfig(opts)
+	return &AIMD{config: config, limit: float64(config.initial)}
+}
+
+// Limit returns the number of calls currently allowed to run at once
+func (l *AIMD) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// OnSample adjusts the limit to a finished call
+func (l *AIMD) OnSample(sample circuitbreaker.Sample) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	switch {
+	case sample.Dropped || sample.RTT > l.config.timeout:
+		l.limit *= l.config.backoffRatio
+	case !appLimited(sample.InFlight, l.limit):
+		l.limit++
+	}
+	l.limit = l.config.clamp(l.limit)
+}
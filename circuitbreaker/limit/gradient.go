@@ -0,0 +1,54 @@
+package limit
+
+import (
+	"math"
+	"sync"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// Gradient compares the latency of every call with the latency without load, taken as the lowest latency seen.
+// While calls are within the tolerance the limit grows by the square root of the limit, and it shrinks in
+// proportion as calls get slower. Changes are smoothed to avoid oscillating, and failures shrink the limit by
+// the backoff ratio.
+type Gradient struct {
+	mutex    sync.Mutex
+	config   config
+	limit    float64
+	baseline baseline
+}
+
+// NewGradient creates a Gradient limiter, see WithSmoothing and WithTolerance
+func NewGradient(opts ...Option) *Gradient {
+	config := newConfig(opts)
+	return &Gradient{config: config, limit: float64(config.initial)}
+}
+
+// Limit returns the number of calls currently allowed to run at once
+func (l *Gradient) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// OnSample adjusts the limit to a finished call
+func (l *Gradient) OnSample(sample circuitbreaker.Sample) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if sample.Dropped {
+		// a call that failed fast says nothing about the latency without load
+		l.limit = l.config.clamp(l.limit * l.config.backoffRatio)
+		return
+	}
+	if !l.baseline.observe(sample, &l.limit, l.config) {
+		return
+	}
+	if appLimited(sample.InFlight, l.limit) {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.config.tolerance*float64(l.baseline.rtt)/float64(sample.RTT)))
+	limit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.config.clamp(l.limit*(1-l.config.smoothing) + limit*l.config.smoothing)
+}
@@ -0,0 +1,188 @@
+// Package limit provides Limiters adapting the number of calls a breaker lets run at once to the latency and
+// failures of a dependency, after the algorithms of Netflix's concurrency-limits:
+//
+//   - AIMD grows the limit by one while calls succeed and cuts it by a ratio on failures or timeouts
+//   - Vegas estimates the queue building up at the dependency from the latency over the lowest latency seen
+//   - Gradient shrinks the limit in proportion as calls get slower than the lowest latency seen
+//
+// The limiters are plugged into breakers with circuitbreaker.WithLimiter.
+package limit
+
+import (
+	"math"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// Defaults shared by the limiters
+const (
+	DefaultInitialLimit = 20
+	DefaultMinLimit     = 1
+	DefaultMaxLimit     = 1000
+)
+
+// Defaults of AIMD
+const (
+	DefaultBackoffRatio = 0.9
+	DefaultTimeout      = 5 * time.Second
+)
+
+// Defaults of Gradient
+const (
+	DefaultSmoothing = 0.2
+	DefaultTolerance = 1.5
+)
+
+// probeFactor sets how often Vegas and Gradient measure the latency without load again, every probeFactor
+// times the limit calls
+const probeFactor = 30
+
+// Option configures a limiter, options that do not apply to a limiter are ignored by it
+type Option func(*config)
+
+type config struct {
+	initial      int
+	min          int
+	max          int
+	backoffRatio float64
+	timeout      time.Duration
+	smoothing    float64
+	tolerance    float64
+}
+
+// WithInitialLimit sets the limit used until calls are observed
+func WithInitialLimit(limit int) Option {
+	return func(c *config) {
+		c.initial = limit
+	}
+}
+
+// WithMinLimit sets the lowest limit, it is at least 1 so that the dependency keeps being probed
+func WithMinLimit(limit int) Option {
+	return func(c *config) {
+		c.min = limit
+	}
+}
+
+// WithMaxLimit sets the highest limit
+func WithMaxLimit(limit int) Option {
+	return func(c *config) {
+		c.max = limit
+	}
+}
+
+// WithBackoffRatio sets the ratio AIMD multiplies the limit by on a failure or a timeout, and Gradient on a
+// failure, between 0.5 and 1
+func WithBackoffRatio(ratio float64) Option {
+	return func(c *config) {
+		c.backoffRatio = ratio
+	}
+}
+
+// WithTimeout sets the latency over which AIMD handles a call as a failure
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithSmoothing sets how much of a new limit Gradient applies at once, between 0 and 1
+func WithSmoothing(smoothing float64) Option {
+	return func(c *config) {
+		c.smoothing = smoothing
+	}
+}
+
+// WithTolerance sets how much slower than the latency without load Gradient lets calls get before it lowers
+// the limit, 1.5 tolerates calls 50% slower
+func WithTolerance(tolerance float64) Option {
+	return func(c *config) {
+		c.tolerance = tolerance
+	}
+}
+
+// newConfig applies the options and brings the values back into their ranges
+func newConfig(opts []Option) config {
+	c := config{
+		initial:      DefaultInitialLimit,
+		min:          DefaultMinLimit,
+		max:          DefaultMaxLimit,
+		backoffRatio: DefaultBackoffRatio,
+		timeout:      DefaultTimeout,
+		smoothing:    DefaultSmoothing,
+		tolerance:    DefaultTolerance,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.min = max(c.min, 1)
+	c.max = max(c.max, c.min)
+	c.initial = min(max(c.initial, c.min), c.max)
+	if c.backoffRatio < 0.5 || c.backoffRatio >= 1 {
+		c.backoffRatio = DefaultBackoffRatio
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.smoothing <= 0 || c.smoothing > 1 {
+		c.smoothing = DefaultSmoothing
+	}
+	if c.tolerance < 1 {
+		c.tolerance = DefaultTolerance
+	}
+	return c
+}
+
+func (c config) clamp(limit float64) float64 {
+	return math.Min(math.Max(limit, float64(c.min)), float64(c.max))
+}
+
+// appLimited reports whether the calls are too few to tell anything about a higher limit, the limit is then
+// kept rather than grown
+func appLimited(inFlight int, limit float64) bool {
+	return float64(inFlight)*2 < limit
+}
+
+// baseline tracks the latency of the dependency without load, taken as the lowest latency seen. It is measured
+// again from time to time so that a dependency that got slower for good does not keep the limit down: the limit
+// is halved and the first call admitted under the new limit gives the new baseline.
+type baseline struct {
+	rtt     time.Duration
+	samples int
+	probing bool
+}
+
+// observe updates the baseline with a call that succeeded, dropped calls must not be observed since a call
+// failing fast would give a baseline no successful call can match. It returns false when the call must not be
+// used to adjust the limit.
+func (b *baseline) observe(sample circuitbreaker.Sample, limit *float64, c config) bool {
+	if sample.RTT <= 0 {
+		return false
+	}
+
+	if b.probing {
+		// calls admitted before the limit was halved still queue at the dependency
+		if sample.InFlight > int(*limit) {
+			return false
+		}
+		b.probing = false
+		b.rtt = sample.RTT
+		return false
+	}
+
+	b.samples++
+	if b.samples >= int(*limit)*probeFactor {
+		b.samples = 0
+		b.probing = true
+		*limit = c.clamp(*limit / 2)
+		return false
+	}
+
+	if b.rtt == 0 || sample.RTT < b.rtt {
+		b.rtt = sample.RTT
+		return false
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package limit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/limit"
+)
+
+// dependency is a synthetic latency curve, calls over the capacity queue and take proportionally longer
+type dependency struct {
+	capacity int
+	rtt      time.Duration
+}
+
+func (d dependency) latency(inFlight int) time.Duration {
+	if inFlight <= d.capacity {
+		return d.rtt
+	}
+	return d.rtt * time.Duration(inFlight) / time.Duration(d.capacity)
+}
+
+// simulate drives the limiter with callers that always use the whole limit, and returns the average limit
+// over the last quarter of the calls
+func simulate(limiter circuitbreaker.Limiter, d dependency, calls int, dropped func(rtt time.Duration) bool) int {
+	total, counted := 0, 0
+	for i := 0; i < calls; i++ {
+		inFlight := limiter.Limit()
+		rtt := d.latency(inFlight)
+		limiter.OnSample(circuitbreaker.Sample{RTT: rtt, InFlight: inFlight, Dropped: dropped(rtt)})
+		if i >= calls*3/4 {
+			total += limiter.Limit()
+			counted++
+		}
+	}
+	return total / counted
+}
+
+func TestConvergence(t *testing.T) {
+	baseRTT := 10 * time.Millisecond
+	testCases := map[string]struct {
+		limiter circuitbreaker.Limiter
+		// dropped tells whether the latency of a call makes it fail
+		dropped func(rtt time.Duration) bool
+		// tolerance is how far over the capacity the limit may settle, the algorithms let a little queue build
+		// up at the dependency
+		tolerance float64
+	}{
+		"AIMD": {
+			limiter:   limit.NewAIMD(limit.WithTimeout(baseRTT * 5 / 4)),
+			dropped:   func(time.Duration) bool { return false },
+			tolerance: 1.25,
+		},
+		"AIMDFailures": {
+			limiter:   limit.NewAIMD(),
+			dropped:   func(rtt time.Duration) bool { return rtt > baseRTT*5/4 },
+			tolerance: 1.25,
+		},
+		"Vegas": {
+			limiter:   limit.NewVegas(),
+			dropped:   func(time.Duration) bool { return false },
+			tolerance: 1.5,
+		},
+		"Gradient": {
+			limiter:   limit.NewGradient(),
+			dropped:   func(time.Duration) bool { return false },
+			tolerance: 2,
+		},
+	}
+
+	phases := []struct {
+		name string
+		dependency
+	}{
+		{name: "start", dependency: dependency{capacity: 50, rtt: baseRTT}},
+		{name: "capacity lost", dependency: dependency{capacity: 25, rtt: baseRTT}},
+		{name: "capacity back", dependency: dependency{capacity: 50, rtt: baseRTT}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, phase := range phases {
+				got := simulate(tc.limiter, phase.dependency, 5000, tc.dropped)
+				low, high := phase.capacity*4/5, int(float64(phase.capacity)*tc.tolerance)
+				if got < low || got > high {
+					t.Errorf("average limit after %s, expected between %d and %d, got %d", phase.name, low, high, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSlowerDependency(t *testing.T) {
+	limiters := map[string]circuitbreaker.Limiter{
+		"Vegas":    limit.NewVegas(),
+		"Gradient": limit.NewGradient(),
+	}
+	never := func(time.Duration) bool { return false }
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			simulate(limiter, dependency{capacity: 50, rtt: 10 * time.Millisecond}, 5000, never)
+
+			// the dependency gets slower for good, the latency without load is measured again rather than the
+			// limit being kept down
+			got := simulate(limiter, dependency{capacity: 50, rtt: 30 * time.Millisecond}, 20000, never)
+			if got < 40 {
+				t.Errorf("average limit, expected at least 40, got %d", got)
+			}
+		})
+	}
+}
+
+func TestFastFailures(t *testing.T) {
+	limiters := map[string]circuitbreaker.Limiter{
+		"Vegas":    limit.NewVegas(),
+		"Gradient": limit.NewGradient(),
+	}
+	d := dependency{capacity: 50, rtt: 10 * time.Millisecond}
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			// one call in 20 is rejected by the dependency right away, much faster than any call it serves
+			total, counted := 0, 0
+			for i := 0; i < 5000; i++ {
+				inFlight := limiter.Limit()
+				sample := circuitbreaker.Sample{RTT: d.latency(inFlight), InFlight: inFlight}
+				if i%20 == 0 {
+					sample.RTT, sample.Dropped = time.Millisecond, true
+				}
+				limiter.OnSample(sample)
+				if i >= 5000*3/4 {
+					total += limiter.Limit()
+					counted++
+				}
+			}
+
+			if got := total / counted; got < d.capacity/2 {
+				t.Errorf("average limit, expected at least %d, got %d", d.capacity/2, got)
+			}
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	limiter := limit.NewAIMD(limit.WithInitialLimit(5), limit.WithMinLimit(4), limit.WithMaxLimit(6))
+	if l := limiter.Limit(); l != 5 {
+		t.Errorf("limiter.Limit, expected the initial limit 5, got %d", l)
+	}
+
+	for i := 0; i < 10; i++ {
+		limiter.OnSample(circuitbreaker.Sample{RTT: time.Millisecond, InFlight: limiter.Limit()})
+	}
+	if l := limiter.Limit(); l != 6 {
+		t.Errorf("limiter.Limit after successes, expected the max limit 6, got %d", l)
+	}
+
+	for i := 0; i < 10; i++ {
+		limiter.OnSample(circuitbreaker.Sample{RTT: time.Millisecond, InFlight: limiter.Limit(), Dropped: true})
+	}
+	if l := limiter.Limit(); l != 4 {
+		t.Errorf("limiter.Limit after failures, expected the min limit 4, got %d", l)
+	}
+
+	// a few calls do not tell anything about a higher limit
+	limiter.OnSample(circuitbreaker.Sample{RTT: time.Millisecond, InFlight: 1})
+	if l := limiter.Limit(); l != 4 {
+		t.Errorf("limiter.Limit after an app limited call, expected 4, got %d", l)
+	}
+}
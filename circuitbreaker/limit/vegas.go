@@ -0,0 +1,65 @@
+package limit
+
+import (
+	"math"
+	"sync"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// Vegas estimates the calls queued at the dependency from the latency of each call over the latency without
+// load, taken as the lowest latency seen. The limit grows quickly while the queue is short and shrinks once it
+// grows, failures shrink it too.
+type Vegas struct {
+	mutex    sync.Mutex
+	config   config
+	limit    float64
+	baseline baseline
+}
+
+// NewVegas creates a Vegas limiter
+func NewVegas(opts ...Option) *Vegas {
+	config := newConfig(opts)
+	return &Vegas{config: config, limit: float64(config.initial)}
+}
+
+// Limit returns the number of calls currently allowed to run at once
+func (l *Vegas) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// OnSample adjusts the limit to a finished call
+func (l *Vegas) OnSample(sample circuitbreaker.Sample) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// the thresholds grow with the logarithm of the limit, so that large limits move by more than one call
+	step := math.Max(1, math.Log10(l.limit))
+	if sample.Dropped {
+		// a call that failed fast says nothing about the latency without load
+		l.limit = l.config.clamp(l.limit - step)
+		return
+	}
+	if !l.baseline.observe(sample, &l.limit, l.config) {
+		return
+	}
+
+	switch {
+	case appLimited(sample.InFlight, l.limit):
+		return
+	default:
+		queue := l.limit * (1 - float64(l.baseline.rtt)/float64(sample.RTT))
+		alpha, beta := 3*step, 6*step
+		switch {
+		case queue <= step:
+			l.limit += beta
+		case queue < alpha:
+			l.limit += step
+		case queue > beta:
+			l.limit -= step
+		}
+	}
+	l.limit = l.config.clamp(l.limit)
+}
@@ -0,0 +1,31 @@
+package circuitbreaker
+
+import "time"
+
+// Sample describes a finished call, as reported to a Limiter
+type Sample struct {
+	// RTT is how long the handler ran
+	RTT time.Duration
+	// InFlight is the number of calls running when the call was admitted, itself included
+	InFlight int
+	// Dropped is true when the call was classified as a failure
+	Dropped bool
+}
+
+// Limiter adapts the number of calls allowed to run at once from the latency and failures of the calls, see the
+// circuitbreaker/limit package for implementations. Calls over the limit are rejected with ErrLimitExceeded
+// while admitted, alongside the state of the circuit. Implementations must be safe for concurrent use and must
+// not call back into the breaker.
+type Limiter interface {
+	// Limit returns the number of calls currently allowed to run at once
+	Limit() int
+	// OnSample is called once an admitted call finished, ignored calls are not reported
+	OnSample(sample Sample)
+}
+
+// WithLimiter adapts the number of calls allowed to run at once with the given limiter, see Limiter
+func WithLimiter(limiter Limiter) SettingsOption {
+	return func(s *Settings) {
+		s.Limiter = limiter
+	}
+}
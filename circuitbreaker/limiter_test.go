@@ -0,0 +1,75 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// fixedLimiter allows a fixed number of calls and records the samples it gets
+type fixedLimiter struct {
+	mutex   sync.Mutex
+	limit   int
+	samples []circuitbreaker.Sample
+}
+
+func (l *fixedLimiter) Limit() int {
+	return l.limit
+}
+
+func (l *fixedLimiter) OnSample(sample circuitbreaker.Sample) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.samples = append(l.samples, sample)
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := &fixedLimiter{limit: 1}
+	var IsIgnored circuitbreaker.IsIgnoredHandler = func(r *http.Response, e error) bool {
+		return errors.Is(e, context.Canceled)
+	}
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithLimiter(limiter), circuitbreaker.WithIsIgnoredHandler(IsIgnored))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	release, done := blockingCalls(t, cb, 1)
+
+	_, err := cb.ExecuteContext(context.Background(), succeedingHandler)
+	expectedErr := circuitbreaker.ErrLimitExceeded{Name: "test", Limit: 1}
+	if err != expectedErr {
+		t.Errorf("cb.ExecuteContext over the limit, expected %s, got %v", expectedErr, err)
+	}
+	if counts := cb.Counts(); counts.LimitRejections != 1 || counts.Rejections != 0 {
+		t.Errorf("cb.Counts, expected 1 limit rejection and no rejection, got %d and %d", counts.LimitRejections, counts.Rejections)
+	}
+
+	events := receiveEvents(t, sub, 2)
+	if events[1].Type != circuitbreaker.LimitRejected || events[1].Err != expectedErr {
+		t.Errorf("event, expected limit-rejected with %s, got %s with %v", expectedErr, events[1].Type, events[1].Err)
+	}
+
+	close(release)
+	<-done
+
+	cb.ExecuteContext(context.Background(), failingHandler)
+	cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return nil, context.Canceled
+	})
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if len(limiter.samples) != 2 {
+		t.Fatalf("samples, expected 2 without the ignored call, got %d", len(limiter.samples))
+	}
+	if sample := limiter.samples[0]; sample.Dropped || sample.InFlight != 1 || sample.RTT <= 0 {
+		t.Errorf("sample of the blocking call, expected a success with 1 call in flight, got %+v", sample)
+	}
+	if sample := limiter.samples[1]; !sample.Dropped {
+		t.Errorf("sample of the failing call, expected dropped, got %+v", sample)
+	}
+}
@@ -0,0 +1,157 @@
+package circuitbreaker
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func aggregateAttr(aggregate gauges.Aggregate) slog.Attr {
+	return slog.Group("aggregate",
+		slog.Int("requests", aggregate.RequestCount),
+		slog.Int("failures", aggregate.FailureCount),
+		slog.Int("successes", aggregate.SuccessCount),
+		slog.Float64("failure_rate", aggregate.FailureRate()),
+		slog.Float64("success_rate", aggregate.SuccessRate()),
+	)
+}
+
+// logTransition logs openings as warnings since they mean requests will be rejected, other transitions are
+// part of the normal recovery cycle
+func (b *Breaker) logTransition(from, to State) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	if to == Open {
+		level = slog.LevelWarn
+	}
+
+	logger.Log(context.Background(), level, "circuit breaker state changed",
+		slog.String("name", b.Name()),
+		slog.String("from", from.String()),
+		slog.String("to", to.String()),
+		aggregateAttr(b.Aggregate()),
+	)
+}
+
+// logRejection uses the debug level since every call is rejected while the circuit is open
+func (b *Breaker) logRejection(eventType EventType, state State, err error) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	message := "circuit breaker rejected call"
+	switch eventType {
+	case BulkheadRejected:
+		message = "circuit breaker bulkhead rejected call"
+	case LimitRejected:
+		message = "circuit breaker concurrency limit rejected call"
+	case Throttled:
+		message = "circuit breaker throttled call"
+	case RateLimited:
+		message = "circuit breaker rate limited call"
+	case Shed:
+		message = "circuit breaker shed call"
+	}
+
+	logger.Debug(message,
+		slog.String("name", b.Name()),
+		slog.String("state", state.String()),
+		slog.String("error", err.Error()),
+	)
+}
+
+// logRetryDenied uses the debug level since every failed call is denied a retry while the budget is exhausted
+func (b *Breaker) logRetryDenied(attempt int, err error) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	attrs := []any{slog.String("name", b.Name()), slog.Int("attempt", attempt)}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.Debug("circuit breaker retry budget exhausted", attrs...)
+}
+
+func (b *Breaker) logOverride(message string, from, to State) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	logger.Info(message,
+		slog.String("name", b.Name()),
+		slog.String("from", from.String()),
+		slog.String("to", to.String()),
+	)
+}
+
+func (b *Breaker) logRestore(snapshot Snapshot) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	logger.Info("circuit breaker state restored",
+		slog.String("name", b.Name()),
+		slog.String("saved_state", snapshot.State.String()),
+		slog.String("state", b.stateMachine.State().String()),
+		slog.Time("saved_at", snapshot.SavedAt),
+	)
+}
+
+// logStoreError uses the warning level since the breaker keeps working without its store
+func (b *Breaker) logStoreError(message string, err error) {
+	logger := b.CurrentSettings().Logger
+	if logger == nil {
+		return
+	}
+
+	logger.Warn(message,
+		slog.String("name", b.Name()),
+		slog.String("error", err.Error()),
+	)
+}
+
+func (b *Breaker) logSettingsUpdated(from, to *Settings) {
+	logger := to.Logger
+	if logger == nil {
+		return
+	}
+
+	logger.Info("circuit breaker settings updated",
+		slog.String("name", to.Name),
+		thresholdsAttr("from", from.Thresholds),
+		thresholdsAttr("to", to.Thresholds),
+		slog.Bool("gauge_replaced", from.Gauge != to.Gauge),
+	)
+}
+
+func thresholdsAttr(key string, thresholds Thresholds) slog.Attr {
+	return slog.Group(key,
+		slog.Float64("failure_rate", thresholds.FailureRate),
+		slog.Float64("recovery_rate", thresholds.RecoveryRate),
+		slog.Duration("cooldown", thresholds.CooldownDuration),
+		slog.Int("max_requests_on_half_open", thresholds.MaxRequestOnHalfOpen),
+		slog.Int("min_requests", thresholds.MinRequests),
+	)
+}
+
+func (s *Settings) logInvalid(err error) {
+	logger := s.Logger
+	if logger == nil {
+		return
+	}
+
+	logger.Error("invalid circuit breaker settings",
+		slog.String("name", s.Name),
+		slog.String("error", err.Error()),
+	)
+}
@@ -0,0 +1,83 @@
+package circuitbreaker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+func decodeLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		record := map[string]interface{}{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("json.Decode, expected no err, got %s", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Run("LogsTransitionsAndRejections", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithLogger(logger))
+		cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+
+		cb.ForceState(circuitbreaker.Open)
+		cb.Execute(func(name string) (*http.Response, error) {
+			return nil, nil
+		})
+
+		records := decodeLogRecords(t, buf)
+		expected := []struct {
+			level   string
+			message string
+		}{
+			{"INFO", "circuit breaker state forced"},
+			{"WARN", "circuit breaker state changed"},
+			{"DEBUG", "circuit breaker rejected call"},
+		}
+
+		if len(records) != len(expected) {
+			t.Fatalf("log records, expected %d, got %d: %v", len(expected), len(records), records)
+		}
+
+		for i, e := range expected {
+			if records[i]["level"] != e.level || records[i]["msg"] != e.message {
+				t.Errorf("log record %d, expected %s %q, got %v %q", i, e.level, e.message, records[i]["level"], records[i]["msg"])
+			}
+			if records[i]["name"] != "test" {
+				t.Errorf("log record %d, name expected test, got %v", i, records[i]["name"])
+			}
+		}
+
+		if _, ok := records[1]["aggregate"].(map[string]interface{}); !ok {
+			t.Errorf("transition log record, expected aggregate group, got %v", records[1])
+		}
+	})
+
+	t.Run("LogsInvalidSettings", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+		_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithLogger(logger), circuitbreaker.WithFailureRate(0))
+		if err == nil {
+			t.Fatalf("circuitbreaker.NewSettings, expected err, got nil")
+		}
+
+		records := decodeLogRecords(t, buf)
+		if len(records) != 1 || records[0]["level"] != "ERROR" || records[0]["error"] != err.Error() {
+			t.Errorf("log records, expected one error record with %q, got %v", err, records)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+// Package prometheus exports the state and outcomes of circuit breakers as Prometheus metrics
+package prometheus
+
+import (
+	"sync"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace prefixes every metric exported by the Collector
+const Namespace = "circuitbreaker"
+
+var states = []circuitbreaker.State{circuitbreaker.Closed, circuitbreaker.Open, circuitbreaker.HalfOpen}
+
+// Collector is a prometheus.Collector that reads the current state, counts and gauge aggregate of a set of
+// breakers every time it is scraped. Breakers are identified by the "name" label, taken from Settings.Name.
+type Collector struct {
+	mutex    sync.RWMutex
+	breakers []*circuitbreaker.Breaker
+
+	state       *prom.Desc
+	calls       *prom.Desc
+	transitions *prom.Desc
+	retries     *prom.Desc
+	hedges      *prom.Desc
+	failureRate *prom.Desc
+	successRate *prom.Desc
+	requests    *prom.Desc
+}
+
+var _ prom.Collector = &Collector{}
+
+// NewCollector creates a collector for the given breakers, more can be added later with Add
+func NewCollector(breakers ...*circuitbreaker.Breaker) *Collector {
+	return &Collector{
+		breakers: breakers,
+		state: prom.NewDesc(prom.BuildFQName(Namespace, "", "state"),
+			"Whether the circuit breaker is in the given state (1) or not (0).",
+			[]string{"name", "state"}, nil),
+		calls: prom.NewDesc(prom.BuildFQName(Namespace, "", "calls_total"),
+			"Calls made through the circuit breaker by outcome: success, failure, timeout, ignored, rejected, bulkhead_rejected, limit_rejected, throttled, rate_limited or shed.",
+			[]string{"name", "outcome"}, nil),
+		transitions: prom.NewDesc(prom.BuildFQName(Namespace, "", "transitions_total"),
+			"State transitions of the circuit breaker.",
+			[]string{"name", "from", "to"}, nil),
+		retries: prom.NewDesc(prom.BuildFQName(Namespace, "", "retries_total"),
+			"Retries of failed calls by result: retried, or denied when the retry budget was exhausted.",
+			[]string{"name", "result"}, nil),
+		hedges: prom.NewDesc(prom.BuildFQName(Namespace, "", "hedges_total"),
+			"Attempts started on top of slow hedged calls.",
+			[]string{"name"}, nil),
+		failureRate: prom.NewDesc(prom.BuildFQName(Namespace, "", "failure_rate"),
+			"Percentage of failed requests currently held by the gauge.",
+			[]string{"name"}, nil),
+		successRate: prom.NewDesc(prom.BuildFQName(Namespace, "", "success_rate"),
+			"Percentage of successful requests currently held by the gauge.",
+			[]string{"name"}, nil),
+		requests: prom.NewDesc(prom.BuildFQName(Namespace, "", "gauge_requests"),
+			"Number of requests currently held by the gauge.",
+			[]string{"name"}, nil),
+	}
+}
+
+// Register creates a collector for the breakers and registers it with the registerer
+func Register(registerer prom.Registerer, breakers ...*circuitbreaker.Breaker) (*Collector, error) {
+	collector := NewCollector(breakers...)
+	if err := registerer.Register(collector); err != nil {
+		return nil, err
+	}
+	return collector, nil
+}
+
+// Add starts exporting metrics for the breaker
+func (c *Collector) Add(breaker *circuitbreaker.Breaker) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.breakers = append(c.breakers, breaker)
+}
+
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.state
+	ch <- c.calls
+	ch <- c.transitions
+	ch <- c.retries
+	ch <- c.hedges
+	ch <- c.failureRate
+	ch <- c.successRate
+	ch <- c.requests
+}
+
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, breaker := range c.breakers {
+		c.collectBreaker(ch, breaker)
+	}
+}
+
+func (c *Collector) collectBreaker(ch chan<- prom.Metric, breaker *circuitbreaker.Breaker) {
+	name := breaker.Name()
+	current := breaker.State()
+
+	for _, state := range states {
+		var value float64
+		if state == current {
+			value = 1
+		}
+		ch <- prom.MustNewConstMetric(c.state, prom.GaugeValue, value, name, state.String())
+	}
+
+	counts := breaker.Counts()
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Successes), name, "success")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Failures), name, "failure")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Timeouts), name, "timeout")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Ignored), name, "ignored")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Rejections), name, "rejected")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.BulkheadRejections), name, "bulkhead_rejected")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.LimitRejections), name, "limit_rejected")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Throttled), name, "throttled")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.RateLimited), name, "rate_limited")
+	ch <- prom.MustNewConstMetric(c.calls, prom.CounterValue, float64(counts.Shed), name, "shed")
+
+	ch <- prom.MustNewConstMetric(c.retries, prom.CounterValue, float64(counts.Retries), name, "retried")
+	ch <- prom.MustNewConstMetric(c.retries, prom.CounterValue, float64(counts.RetriesDenied), name, "denied")
+	ch <- prom.MustNewConstMetric(c.hedges, prom.CounterValue, float64(counts.Hedges), name)
+
+	for transition, count := range counts.Transitions {
+		ch <- prom.MustNewConstMetric(c.transitions, prom.CounterValue, float64(count),
+			name, transition.From.String(), transition.To.String())
+	}
+
+	aggregate := breaker.Aggregate()
+	ch <- prom.MustNewConstMetric(c.failureRate, prom.GaugeValue, aggregate.FailureRate(), name)
+	ch <- prom.MustNewConstMetric(c.successRate, prom.GaugeValue, aggregate.SuccessRate(), name)
+	ch <- prom.MustNewConstMetric(c.requests, prom.GaugeValue, float64(aggregate.RequestCount), name)
+}
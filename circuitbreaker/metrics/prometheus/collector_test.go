@@ -0,0 +1,117 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newBreaker(t *testing.T, name string) *circuitbreaker.Breaker {
+	t.Helper()
+
+	settings, err := circuitbreaker.NewSettings(name,
+		circuitbreaker.WithGauge(gauges.NewFixedWindowGauge(10)),
+		circuitbreaker.WithMinRequest(4),
+		circuitbreaker.WithFailureRate(50),
+	)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewSettings, expected no err, got %s", err)
+	}
+
+	breaker, err := circuitbreaker.NewBreakerWithSettings(settings)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+	}
+	return breaker
+}
+
+func execute(breaker *circuitbreaker.Breaker, err error) {
+	breaker.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		return &http.Response{}, err
+	})
+}
+
+func TestCollector(t *testing.T) {
+	payments := newBreaker(t, "payments")
+	execute(payments, nil)
+	execute(payments, nil)
+	execute(payments, errors.New("failed"))
+	execute(payments, context.DeadlineExceeded)
+	execute(payments, errors.New("failed"))
+	execute(payments, nil)
+
+	inventory := newBreaker(t, "inventory")
+	execute(inventory, nil)
+
+	registry := prom.NewPedanticRegistry()
+	collector, err := prometheus.Register(registry, payments)
+	if err != nil {
+		t.Fatalf("prometheus.Register, expected no err, got %s", err)
+	}
+	collector.Add(inventory)
+
+	expected := `
+# HELP circuitbreaker_calls_total Calls made through the circuit breaker by outcome: success, failure, timeout, ignored, rejected, bulkhead_rejected, limit_rejected, throttled, rate_limited or shed.
+# TYPE circuitbreaker_calls_total counter
+circuitbreaker_calls_total{name="inventory",outcome="bulkhead_rejected"} 0
+circuitbreaker_calls_total{name="inventory",outcome="failure"} 0
+circuitbreaker_calls_total{name="inventory",outcome="ignored"} 0
+circuitbreaker_calls_total{name="inventory",outcome="limit_rejected"} 0
+circuitbreaker_calls_total{name="inventory",outcome="rate_limited"} 0
+circuitbreaker_calls_total{name="inventory",outcome="rejected"} 0
+circuitbreaker_calls_total{name="inventory",outcome="shed"} 0
+circuitbreaker_calls_total{name="inventory",outcome="success"} 1
+circuitbreaker_calls_total{name="inventory",outcome="throttled"} 0
+circuitbreaker_calls_total{name="inventory",outcome="timeout"} 0
+circuitbreaker_calls_total{name="payments",outcome="bulkhead_rejected"} 0
+circuitbreaker_calls_total{name="payments",outcome="failure"} 2
+circuitbreaker_calls_total{name="payments",outcome="ignored"} 0
+circuitbreaker_calls_total{name="payments",outcome="limit_rejected"} 0
+circuitbreaker_calls_total{name="payments",outcome="rate_limited"} 0
+circuitbreaker_calls_total{name="payments",outcome="rejected"} 1
+circuitbreaker_calls_total{name="payments",outcome="shed"} 0
+circuitbreaker_calls_total{name="payments",outcome="success"} 2
+circuitbreaker_calls_total{name="payments",outcome="throttled"} 0
+circuitbreaker_calls_total{name="payments",outcome="timeout"} 1
+# HELP circuitbreaker_state Whether the circuit breaker is in the given state (1) or not (0).
+# TYPE circuitbreaker_state gauge
+circuitbreaker_state{name="inventory",state="closed"} 1
+circuitbreaker_state{name="inventory",state="half-open"} 0
+circuitbreaker_state{name="inventory",state="open"} 0
+circuitbreaker_state{name="payments",state="closed"} 0
+circuitbreaker_state{name="payments",state="half-open"} 0
+circuitbreaker_state{name="payments",state="open"} 1
+# HELP circuitbreaker_transitions_total State transitions of the circuit breaker.
+# TYPE circuitbreaker_transitions_total counter
+circuitbreaker_transitions_total{from="closed",name="payments",to="open"} 1
+# HELP circuitbreaker_retries_total Retries of failed calls by result: retried, or denied when the retry budget was exhausted.
+# TYPE circuitbreaker_retries_total counter
+circuitbreaker_retries_total{name="inventory",result="denied"} 0
+circuitbreaker_retries_total{name="inventory",result="retried"} 0
+circuitbreaker_retries_total{name="payments",result="denied"} 0
+circuitbreaker_retries_total{name="payments",result="retried"} 0
+# HELP circuitbreaker_hedges_total Attempts started on top of slow hedged calls.
+# TYPE circuitbreaker_hedges_total counter
+circuitbreaker_hedges_total{name="inventory"} 0
+circuitbreaker_hedges_total{name="payments"} 0
+# HELP circuitbreaker_failure_rate Percentage of failed requests currently held by the gauge.
+# TYPE circuitbreaker_failure_rate gauge
+circuitbreaker_failure_rate{name="inventory"} 0
+circuitbreaker_failure_rate{name="payments"} 60
+`
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"circuitbreaker_calls_total", "circuitbreaker_state", "circuitbreaker_transitions_total", "circuitbreaker_retries_total",
+		"circuitbreaker_hedges_total", "circuitbreaker_failure_rate")
+	if err != nil {
+		t.Errorf("testutil.GatherAndCompare, unexpected metrics: %s", err)
+	}
+}
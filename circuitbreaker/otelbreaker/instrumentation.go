@@ -0,0 +1,161 @@
+// Package otelbreaker instruments circuit breakers with OpenTelemetry. Breaker decisions are recorded as events
+// on the span found in the context passed to Breaker.ExecuteContext, and calls and transitions are counted
+// with OpenTelemetry metric instruments.
+package otelbreaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope used when creating the meter
+const ScopeName = "github.com/aelnahas/circuitbreaker/circuitbreaker/otelbreaker"
+
+// Attribute keys set on span events and metric data points
+const (
+	NameKey            = attribute.Key("circuitbreaker.name")
+	StateKey           = attribute.Key("circuitbreaker.state")
+	OutcomeKey         = attribute.Key("circuitbreaker.outcome")
+	RejectionReasonKey = attribute.Key("circuitbreaker.rejection_reason")
+	FromStateKey       = attribute.Key("circuitbreaker.from_state")
+	ToStateKey         = attribute.Key("circuitbreaker.to_state")
+)
+
+// Span event names
+const (
+	PermittedEvent = "circuitbreaker.permitted"
+	RejectedEvent  = "circuitbreaker.rejected"
+	FinishedEvent  = "circuitbreaker.finished"
+)
+
+// Outcome attribute values in addition to the success and failure outcomes of the gauge
+const (
+	OutcomeRejected = "rejected"
+	OutcomeIgnored  = "ignored"
+)
+
+// Option customises the instrumentation
+type Option func(*config)
+
+type config struct {
+	meterProvider metric.MeterProvider
+}
+
+// WithMeterProvider sets the provider used to create the metric instruments, the global one is used by default
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// Instrumentation implements circuitbreaker.Instrumentation with OpenTelemetry
+type Instrumentation struct {
+	calls       metric.Int64Counter
+	duration    metric.Float64Histogram
+	transitions metric.Int64Counter
+}
+
+var _ circuitbreaker.Instrumentation = &Instrumentation{}
+
+// NewInstrumentation creates the metric instruments, it only fails if the meter refuses to create them
+func NewInstrumentation(opts ...Option) (*Instrumentation, error) {
+	cfg := &config{meterProvider: otel.GetMeterProvider()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(ScopeName)
+	calls, err := meter.Int64Counter("circuitbreaker.calls",
+		metric.WithDescription("Calls made through the circuit breaker by outcome."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram("circuitbreaker.call.duration",
+		metric.WithDescription("Duration of the calls permitted by the circuit breaker."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	transitions, err := meter.Int64Counter("circuitbreaker.transitions",
+		metric.WithDescription("State transitions of the circuit breaker."),
+		metric.WithUnit("{transition}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Instrumentation{calls: calls, duration: duration, transitions: transitions}, nil
+}
+
+// WithInstrumentation is a settings option that instruments the breaker with OpenTelemetry. Since the
+// SettingsOption signature cannot report errors, instrument creation failures result in no instrumentation.
+func WithInstrumentation(opts ...Option) circuitbreaker.SettingsOption {
+	return func(s *circuitbreaker.Settings) {
+		instrumentation, err := NewInstrumentation(opts...)
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		circuitbreaker.WithInstrumentation(instrumentation)(s)
+	}
+}
+
+func (i *Instrumentation) CallRejected(ctx context.Context, name string, state circuitbreaker.State, err error) {
+	trace.SpanFromContext(ctx).AddEvent(RejectedEvent, trace.WithAttributes(
+		NameKey.String(name),
+		StateKey.String(state.String()),
+		RejectionReasonKey.String(err.Error()),
+	))
+
+	i.calls.Add(ctx, 1, metric.WithAttributes(NameKey.String(name), OutcomeKey.String(OutcomeRejected)))
+}
+
+func (i *Instrumentation) CallPermitted(ctx context.Context, name string, state circuitbreaker.State) {
+	trace.SpanFromContext(ctx).AddEvent(PermittedEvent, trace.WithAttributes(
+		NameKey.String(name),
+		StateKey.String(state.String()),
+	))
+}
+
+func (i *Instrumentation) CallFinished(ctx context.Context, name string, state circuitbreaker.State, outcome gauges.Outcome, err error, duration time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(FinishedEvent, trace.WithAttributes(
+		NameKey.String(name),
+		StateKey.String(state.String()),
+		OutcomeKey.String(outcome.String()),
+	))
+	span.SetAttributes(NameKey.String(name), OutcomeKey.String(outcome.String()))
+
+	attrs := metric.WithAttributes(NameKey.String(name), OutcomeKey.String(outcome.String()))
+	i.calls.Add(ctx, 1, attrs)
+	i.duration.Record(ctx, duration.Seconds(), attrs)
+}
+
+func (i *Instrumentation) CallIgnored(ctx context.Context, name string, state circuitbreaker.State, err error, duration time.Duration) {
+	trace.SpanFromContext(ctx).AddEvent(FinishedEvent, trace.WithAttributes(
+		NameKey.String(name),
+		StateKey.String(state.String()),
+		OutcomeKey.String(OutcomeIgnored),
+	))
+
+	attrs := metric.WithAttributes(NameKey.String(name), OutcomeKey.String(OutcomeIgnored))
+	i.calls.Add(ctx, 1, attrs)
+	i.duration.Record(ctx, duration.Seconds(), attrs)
+}
+
+func (i *Instrumentation) StateChanged(name string, from circuitbreaker.State, to circuitbreaker.State) {
+	i.transitions.Add(context.Background(), 1, metric.WithAttributes(
+		NameKey.String(name),
+		FromStateKey.String(from.String()),
+		ToStateKey.String(to.String()),
+	))
+}
@@ -0,0 +1,152 @@
+package otelbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/otelbreaker"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setup(t *testing.T) (*circuitbreaker.Breaker, *tracetest.SpanRecorder, *sdktrace.TracerProvider, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	settings, err := circuitbreaker.NewSettings("payments",
+		otelbreaker.WithInstrumentation(otelbreaker.WithMeterProvider(meterProvider)))
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewSettings, expected no err, got %s", err)
+	}
+
+	breaker, err := circuitbreaker.NewBreakerWithSettings(settings)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+	}
+	return breaker, recorder, tracerProvider, reader
+}
+
+func execute(ctx context.Context, breaker *circuitbreaker.Breaker, err error) {
+	breaker.ExecuteContext(ctx, func(ctx context.Context, name string) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+func eventNames(span sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, event := range span.Events() {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+func attributeValue(attrs []attribute.KeyValue, key attribute.Key) string {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestSpanEvents(t *testing.T) {
+	breaker, recorder, tracerProvider, _ := setup(t)
+	tracer := tracerProvider.Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "permitted")
+	execute(ctx, breaker, errors.New("failed"))
+	span.End()
+
+	breaker.ForceState(circuitbreaker.Open)
+	ctx, span = tracer.Start(context.Background(), "rejected")
+	execute(ctx, breaker, nil)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorder.Ended, expected 2 spans, got %d", len(spans))
+	}
+
+	t.Run("Permitted", func(t *testing.T) {
+		names := eventNames(spans[0])
+		if len(names) != 2 || names[0] != otelbreaker.PermittedEvent || names[1] != otelbreaker.FinishedEvent {
+			t.Errorf("span events, expected [%s %s], got %v", otelbreaker.PermittedEvent, otelbreaker.FinishedEvent, names)
+		}
+
+		finished := spans[0].Events()[1].Attributes
+		if outcome := attributeValue(finished, otelbreaker.OutcomeKey); outcome != "failure" {
+			t.Errorf("finished event outcome, expected failure, got %q", outcome)
+		}
+		if state := attributeValue(finished, otelbreaker.StateKey); state != "closed" {
+			t.Errorf("finished event state, expected closed, got %q", state)
+		}
+		if name := attributeValue(spans[0].Attributes(), otelbreaker.NameKey); name != "payments" {
+			t.Errorf("span name attribute, expected payments, got %q", name)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		names := eventNames(spans[1])
+		if len(names) != 1 || names[0] != otelbreaker.RejectedEvent {
+			t.Fatalf("span events, expected [%s], got %v", otelbreaker.RejectedEvent, names)
+		}
+
+		rejected := spans[1].Events()[0].Attributes
+		expectedReason := circuitbreaker.ErrRequestNotPermitted{Name: "payments", State: circuitbreaker.Open}.Error()
+		if reason := attributeValue(rejected, otelbreaker.RejectionReasonKey); reason != expectedReason {
+			t.Errorf("rejected event reason, expected %q, got %q", expectedReason, reason)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	breaker, _, _, reader := setup(t)
+	ctx := context.Background()
+
+	execute(ctx, breaker, nil)
+	execute(ctx, breaker, nil)
+	breaker.ForceState(circuitbreaker.Open)
+	execute(ctx, breaker, nil)
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &data); err != nil {
+		t.Fatalf("reader.Collect, expected no err, got %s", err)
+	}
+
+	sums := map[string]map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			sums[m.Name] = map[string]int64{}
+			for _, point := range sum.DataPoints {
+				label, _ := point.Attributes.Value(otelbreaker.OutcomeKey)
+				if m.Name == "circuitbreaker.transitions" {
+					label, _ = point.Attributes.Value(otelbreaker.ToStateKey)
+				}
+				sums[m.Name][label.AsString()] = point.Value
+			}
+		}
+	}
+
+	calls := sums["circuitbreaker.calls"]
+	if calls["success"] != 2 || calls[otelbreaker.OutcomeRejected] != 1 {
+		t.Errorf("circuitbreaker.calls, expected 2 success and 1 rejected, got %v", calls)
+	}
+
+	if transitions := sums["circuitbreaker.transitions"]; transitions["open"] != 1 {
+		t.Errorf("circuitbreaker.transitions, expected 1 to open, got %v", transitions)
+	}
+}
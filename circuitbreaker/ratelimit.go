@@ -0,0 +1,147 @@
+package circuitbreaker
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimitHalfOpenRatio is the share of the rate let through while the circuit is half-open
+const DefaultRateLimitHalfOpenRatio float64 = 0.1
+
+// DefaultRateLimitSlowStart is how long the rate takes to recover once the circuit closed
+const DefaultRateLimitSlowStart time.Duration = 30 * time.Second
+
+// RateLimit bounds the rate of calls through a breaker with a token bucket that follows the state of the
+// circuit: the rate drops to HalfOpenRate while the circuit is half-open, and once it closes the rate ramps up
+// linearly back to Rate over SlowStart, rather than jumping from a few probes to full traffic at once. The burst
+// scales with the rate. Calls over the rate are rejected with ErrRateLimited.
+type RateLimit struct {
+	// Rate is the number of calls per second allowed while the circuit is closed
+	Rate float64
+	// Burst is the number of calls allowed at once after a quiet period, Rate rounded up when 0
+	Burst int
+	// HalfOpenRate is the number of calls per second allowed while the circuit is half-open, the slow start
+	// begins from it, Rate times DefaultRateLimitHalfOpenRatio when 0
+	HalfOpenRate float64
+	// SlowStart is how long the rate takes to recover from HalfOpenRate to Rate, counted from the first call
+	// after the circuit closed, DefaultRateLimitSlowStart when 0 and no slow start when negative
+	SlowStart time.Duration
+}
+
+// WithRateLimit bounds the rate of calls through the breaker, see RateLimit
+func WithRateLimit(rateLimit RateLimit) SettingsOption {
+	return func(s *Settings) {
+		if rateLimit.Burst == 0 {
+			rateLimit.Burst = int(math.Ceil(rateLimit.Rate))
+		}
+		if rateLimit.HalfOpenRate == 0 {
+			rateLimit.HalfOpenRate = rateLimit.Rate * DefaultRateLimitHalfOpenRatio
+		}
+		switch {
+		case rateLimit.SlowStart == 0:
+			rateLimit.SlowStart = DefaultRateLimitSlowStart
+		case rateLimit.SlowStart < 0:
+			rateLimit.SlowStart = 0
+		}
+		s.RateLimit = &rateLimit
+	}
+}
+
+func (r RateLimit) validate() error {
+	if r.Rate <= 0 || math.IsNaN(r.Rate) || math.IsInf(r.Rate, 0) {
+		return ErrInvalidSettingParam{Param: "RateLimit.Rate", Val: r.Rate}
+	}
+	if r.Burst <= 0 {
+		return ErrInvalidSettingParam{Param: "RateLimit.Burst", Val: r.Burst}
+	}
+	if r.HalfOpenRate <= 0 || r.HalfOpenRate > r.Rate || math.IsNaN(r.HalfOpenRate) {
+		return ErrInvalidSettingParam{Param: "RateLimit.HalfOpenRate", Val: r.HalfOpenRate}
+	}
+	if r.SlowStart < 0 {
+		return ErrInvalidSettingParam{Param: "RateLimit.SlowStart", Val: r.SlowStart}
+	}
+	return nil
+}
+
+func sameRateLimit(a, b *RateLimit) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// rateLimiter is the token bucket enforcing a RateLimit, a new one is created when the settings change. The
+// breaker tells it about every transition, so that a circuit closed without any call admitted since it opened,
+// such as by Reset or ForceState, still starts slowly.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	config   RateLimit
+	tokens   float64
+	last     time.Time
+	state    State
+	closedAt time.Time
+}
+
+func newRateLimiter(config *RateLimit, state State) *rateLimiter {
+	if config == nil {
+		return nil
+	}
+	return &rateLimiter{config: *config, tokens: float64(config.Burst), last: time.Now(), state: state}
+}
+
+// transitioned follows the state of the breaker, closing the circuit starts the slow start
+func (rl *rateLimiter) transitioned(from, to State, now time.Time) {
+	if rl == nil {
+		return
+	}
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if to == Closed && from != Closed {
+		rl.closedAt = now
+	}
+	rl.state = to
+}
+
+// restart starts the slow start over on a circuit that stays closed
+func (rl *rateLimiter) restart(now time.Time) {
+	if rl == nil {
+		return
+	}
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.closedAt = now
+}
+
+// allow takes a token if there is one, it returns the rate in effect either way
+func (rl *rateLimiter) allow(now time.Time) (bool, float64) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rate := rl.rate(now)
+	burst := max(1, float64(rl.config.Burst)*rate/rl.config.Rate)
+	rl.tokens = min(burst, rl.tokens+rate*now.Sub(rl.last).Seconds())
+	rl.last = now
+
+	if rl.tokens < 1 {
+		return false, rate
+	}
+	rl.tokens--
+	return true, rate
+}
+
+// rate returns the calls per second allowed in the current state
+func (rl *rateLimiter) rate(now time.Time) float64 {
+	if rl.state == HalfOpen {
+		return rl.config.HalfOpenRate
+	}
+
+	elapsed := now.Sub(rl.closedAt)
+	if rl.closedAt.IsZero() || elapsed >= rl.config.SlowStart {
+		return rl.config.Rate
+	}
+	progress := float64(elapsed) / float64(rl.config.SlowStart)
+	return rl.config.HalfOpenRate + (rl.config.Rate-rl.config.HalfOpenRate)*progress
+}
@@ -0,0 +1,124 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// permitted returns how many of the given number of calls made right away were permitted
+func permitted(cb *circuitbreaker.Breaker, calls int) int {
+	count := 0
+	for i := 0; i < calls; i++ {
+		if _, err := cb.ExecuteContext(context.Background(), succeedingHandler); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRateLimit(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRateLimit(circuitbreaker.RateLimit{Rate: 1, Burst: 3}))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	if count := permitted(cb, 3); count != 3 {
+		t.Errorf("permitted, expected the burst of 3, got %d", count)
+	}
+
+	_, err := cb.ExecuteContext(context.Background(), succeedingHandler)
+	var rateLimited circuitbreaker.ErrRateLimited
+	if !errors.As(err, &rateLimited) || rateLimited.Rate != 1 {
+		t.Fatalf("cb.ExecuteContext over the rate, expected ErrRateLimited at 1/s, got %v", err)
+	}
+	if counts := cb.Counts(); counts.RateLimited != 1 || counts.Rejections != 0 {
+		t.Errorf("cb.Counts, expected 1 rate limited call and no rejection, got %d and %d", counts.RateLimited, counts.Rejections)
+	}
+
+	events := receiveEvents(t, sub, 7)
+	if events[6].Type != circuitbreaker.RateLimited {
+		t.Errorf("event, expected rate-limited, got %s", events[6].Type)
+	}
+}
+
+func TestRateLimitHalfOpen(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRateLimit(circuitbreaker.RateLimit{Rate: 1000, HalfOpenRate: 1, SlowStart: time.Hour}))
+
+	cb.ForceState(circuitbreaker.HalfOpen)
+	if count := permitted(cb, 5); count != 1 {
+		t.Errorf("permitted while half-open, expected a burst of 1, got %d", count)
+	}
+
+	// the rate starts over from the half-open rate once the circuit closes, rather than jumping to 1000/s
+	cb.ForceState(circuitbreaker.Closed)
+	time.Sleep(20 * time.Millisecond)
+	if count := permitted(cb, 5); count > 1 {
+		t.Errorf("permitted right after closing, expected at most 1, got %d", count)
+	}
+}
+
+func TestRateLimitSlowStartWithoutCalls(t *testing.T) {
+	testCases := map[string]func(cb *circuitbreaker.Breaker){
+		"Reset": func(cb *circuitbreaker.Breaker) {
+			cb.Reset()
+		},
+		"ForceClosed": func(cb *circuitbreaker.Breaker) {
+			cb.ForceState(circuitbreaker.Open)
+			cb.ForceState(circuitbreaker.Closed)
+		},
+		"ResetOpen": func(cb *circuitbreaker.Breaker) {
+			cb.ForceState(circuitbreaker.Open)
+			cb.Reset()
+		},
+	}
+
+	for name, closeCircuit := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cb := newTestBreaker(t, circuitbreaker.WithRateLimit(circuitbreaker.RateLimit{Rate: 1000, HalfOpenRate: 1, SlowStart: time.Hour}))
+
+			// no call is admitted between the closing and the check, the limiter learns about it from the transition
+			time.Sleep(20 * time.Millisecond)
+			closeCircuit(cb)
+			if count := permitted(cb, 5); count > 1 {
+				t.Errorf("permitted right after closing, expected at most 1, got %d", count)
+			}
+		})
+	}
+}
+
+func TestRateLimitWithoutSlowStart(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRateLimit(circuitbreaker.RateLimit{Rate: 1000, HalfOpenRate: 1, SlowStart: -1}))
+
+	cb.ForceState(circuitbreaker.HalfOpen)
+	permitted(cb, 1)
+	cb.ForceState(circuitbreaker.Closed)
+
+	// the bucket refills at the full rate, its burst is back to 1000 right away
+	time.Sleep(20 * time.Millisecond)
+	if count := permitted(cb, 5); count != 5 {
+		t.Errorf("permitted after closing, expected every call, got %d", count)
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	testCases := map[string]struct {
+		rateLimit circuitbreaker.RateLimit
+		expected  circuitbreaker.ErrInvalidSettingParam
+	}{
+		"Rate":         {rateLimit: circuitbreaker.RateLimit{}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RateLimit.Rate", Val: 0.0}},
+		"Burst":        {rateLimit: circuitbreaker.RateLimit{Rate: 1, Burst: -1}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RateLimit.Burst", Val: -1}},
+		"HalfOpenRate": {rateLimit: circuitbreaker.RateLimit{Rate: 1, HalfOpenRate: 2}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RateLimit.HalfOpenRate", Val: 2.0}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithRateLimit(tc.rateLimit))
+			if err != tc.expected {
+				t.Errorf("circuitbreaker.NewSettings, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
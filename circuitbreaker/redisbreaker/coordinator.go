@@ -0,0 +1,444 @@
+// Package redisbreaker coordinates the breakers of several replicas of a service through Redis, so that a
+// dependency detected as down by one replica is avoided by all of them.
+//
+// Replicas share state in two ways. When a breaker opens, the replica records a trip key that expires with the
+// cooldown and publishes the trip, and every other replica opens its own breaker of the same name. Replicas also
+// add the outcomes of their calls to buckets of one sync interval, and every replica opens its breaker when the
+// outcomes of the whole fleet over the window cross the thresholds of the breaker, even though its own share of
+// the calls did not.
+//
+// Redis is only ever an addition to the local state. When it cannot be reached the breakers keep working on
+// their own outcomes, Healthy reports false, and coordination resumes once Redis is back.
+package redisbreaker
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultPrefix is prepended to every key and channel
+	DefaultPrefix = "circuitbreaker"
+	// DefaultSyncInterval is how often outcomes are flushed to Redis and the fleet aggregate checked
+	DefaultSyncInterval = time.Second
+	// DefaultWindow is how far back the fleet aggregate goes
+	DefaultWindow = 10 * time.Second
+)
+
+// Option customises the coordinator
+type Option func(*Coordinator)
+
+// WithPrefix sets the prefix of keys and channels, replicas sharing breakers must use the same prefix
+func WithPrefix(prefix string) Option {
+	return func(c *Coordinator) {
+		c.prefix = prefix
+	}
+}
+
+// WithSyncInterval sets how often outcomes are flushed and the fleet aggregate checked, it is also the size of
+// the buckets outcomes are counted in
+func WithSyncInterval(interval time.Duration) Option {
+	return func(c *Coordinator) {
+		c.syncInterval = interval
+	}
+}
+
+// WithWindow sets how far back the fleet aggregate goes
+func WithWindow(window time.Duration) Option {
+	return func(c *Coordinator) {
+		c.window = window
+	}
+}
+
+// WithInstanceID sets the id recorded in the trip keys of this replica, a random one is used by default
+func WithInstanceID(id string) Option {
+	return func(c *Coordinator) {
+		c.instanceID = id
+	}
+}
+
+// WithLogger logs when Redis becomes unreachable and reachable again, and fleet wide trips
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Coordinator) {
+		c.logger = logger
+	}
+}
+
+// outcomes counted locally since the last flush
+type outcomes struct {
+	successes int64
+	failures  int64
+}
+
+// Coordinator shares the state of the breakers registered with it through Redis. It implements
+// circuitbreaker.Instrumentation to learn about outcomes and transitions, breakers must be created with
+// circuitbreaker.WithInstrumentation(coordinator) before being registered.
+type Coordinator struct {
+	client       redis.UniversalClient
+	prefix       string
+	syncInterval time.Duration
+	window       time.Duration
+	instanceID   string
+	logger       *slog.Logger
+
+	mutex    sync.Mutex
+	breakers map[string]*circuitbreaker.Breaker
+	pending  map[string]*outcomes
+	// closed is the bucket each breaker last closed in, the fleet outcomes up to it are left out
+	closed  map[string]int64
+	healthy bool
+
+	trips  chan string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ circuitbreaker.Instrumentation = &Coordinator{}
+
+// New starts coordinating through the client, Close stops it. The client is not closed by the coordinator.
+func New(client redis.UniversalClient, opts ...Option) *Coordinator {
+	c := &Coordinator{
+		client:       client,
+		prefix:       DefaultPrefix,
+		syncInterval: DefaultSyncInterval,
+		window:       DefaultWindow,
+		instanceID:   uuid.NewString(),
+		breakers:     map[string]*circuitbreaker.Breaker{},
+		pending:      map[string]*outcomes{},
+		closed:       map[string]int64{},
+		healthy:      true,
+		trips:        make(chan string, 64),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(3)
+	go c.syncLoop(ctx)
+	go c.publishLoop(ctx)
+	go c.subscribeLoop(ctx)
+	return c
+}
+
+// Register starts sharing the state of the breaker, names must be unique
+func (c *Coordinator) Register(breaker *circuitbreaker.Breaker) error {
+	name := breaker.Name()
+	if !c.instruments(breaker) {
+		return ErrNotInstrumented{Name: name}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.breakers[name]; ok {
+		return ErrDuplicateBreaker{Name: name}
+	}
+	c.breakers[name] = breaker
+	return nil
+}
+
+// Healthy reports whether the last exchange with Redis succeeded
+func (c *Coordinator) Healthy() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.healthy
+}
+
+// Close stops coordinating, the breakers keep working on their own
+func (c *Coordinator) Close() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *Coordinator) CallRejected(ctx context.Context, name string, state circuitbreaker.State, err error) {
+}
+
+func (c *Coordinator) CallPermitted(ctx context.Context, name string, state circuitbreaker.State) {}
+
+func (c *Coordinator) CallIgnored(ctx context.Context, name string, state circuitbreaker.State, err error, duration time.Duration) {
+}
+
+func (c *Coordinator) CallFinished(ctx context.Context, name string, state circuitbreaker.State, outcome gauges.Outcome, err error, duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.breakers[name]; !ok {
+		return
+	}
+
+	counts, ok := c.pending[name]
+	if !ok {
+		counts = &outcomes{}
+		c.pending[name] = counts
+	}
+	if outcome == gauges.Success {
+		counts.successes++
+	} else {
+		counts.failures++
+	}
+}
+
+// StateChanged hands openings over to the publishing goroutine so that the call that tripped the breaker does
+// not wait on Redis. Closings age out the fleet outcomes recorded so far, otherwise a cooldown shorter than the
+// window would close the breaker only for the outcomes that opened it to open it again.
+func (c *Coordinator) StateChanged(name string, from circuitbreaker.State, to circuitbreaker.State) {
+	if to == circuitbreaker.Closed {
+		c.mutex.Lock()
+		if _, ok := c.breakers[name]; ok {
+			c.closed[name] = c.bucket(time.Now())
+		}
+		c.mutex.Unlock()
+		return
+	}
+	if to != circuitbreaker.Open {
+		return
+	}
+
+	select {
+	case c.trips <- name:
+	default:
+		// the next sync still lets other replicas catch up through the fleet aggregate
+	}
+}
+
+func (c *Coordinator) instruments(breaker *circuitbreaker.Breaker) bool {
+	for _, instrumentation := range breaker.CurrentSettings().Instrumentations {
+		if instrumentation == circuitbreaker.Instrumentation(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Coordinator) breaker(name string) (*circuitbreaker.Breaker, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	breaker, ok := c.breakers[name]
+	return breaker, ok
+}
+
+// bucket returns the bucket outcomes are counted in at the given time
+func (c *Coordinator) bucket(now time.Time) int64 {
+	return now.UnixNano() / int64(c.syncInterval)
+}
+
+func (c *Coordinator) tripKey(name string) string {
+	return c.prefix + ":{" + name + "}:trip"
+}
+
+func (c *Coordinator) bucketKey(name string, bucket int64) string {
+	return c.prefix + ":{" + name + "}:outcomes:" + strconv.FormatInt(bucket, 10)
+}
+
+func (c *Coordinator) channel() string {
+	return c.prefix + ":trips"
+}
+
+// publishLoop records the trips of local breakers. The trip key is only set when no other replica set it
+// already, and only the replica that set it publishes, so that replicas opening because of a trip do not
+// publish it again.
+func (c *Coordinator) publishLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-c.trips:
+			breaker, ok := c.breaker(name)
+			if !ok {
+				continue
+			}
+
+			cooldown := breaker.CurrentSettings().Thresholds.CooldownDuration
+			if until, ok := breaker.OpenUntil(); ok {
+				cooldown = time.Until(until)
+			}
+			if cooldown <= 0 {
+				continue
+			}
+
+			set, err := c.client.SetNX(ctx, c.tripKey(name), c.instanceID, cooldown).Result()
+			if err == nil && set {
+				err = c.client.Publish(ctx, c.channel(), name).Err()
+			}
+			c.setHealthy(ctx, err)
+		}
+	}
+}
+
+// subscribeLoop opens local breakers as soon as another replica publishes a trip, the client reconnects on its
+// own when the connection drops
+func (c *Coordinator) subscribeLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	pubsub := c.client.Subscribe(ctx, c.channel())
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			if breaker, ok := c.breaker(message.Payload); ok {
+				c.trip(breaker, "trip published by another replica")
+			}
+		}
+	}
+}
+
+func (c *Coordinator) syncLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.setHealthy(ctx, c.sync(ctx))
+		}
+	}
+}
+
+// sync flushes the local outcomes, then opens the breakers that another replica tripped while a message could
+// have been missed, or whose fleet aggregate crosses their thresholds. Outcomes that fail to be flushed are
+// dropped, they are still part of the local gauge. The aggregate of a breaker starts after the bucket it last
+// closed in.
+func (c *Coordinator) sync(ctx context.Context) error {
+	c.mutex.Lock()
+	pending := c.pending
+	c.pending = map[string]*outcomes{}
+	breakers := make(map[string]*circuitbreaker.Breaker, len(c.breakers))
+	closed := make(map[string]int64, len(c.closed))
+	for name, breaker := range c.breakers {
+		breakers[name] = breaker
+	}
+	for name, bucket := range c.closed {
+		closed[name] = bucket
+	}
+	c.mutex.Unlock()
+
+	if len(breakers) == 0 {
+		return nil
+	}
+
+	current := c.bucket(time.Now())
+	buckets := int64((c.window + c.syncInterval - 1) / c.syncInterval)
+	ttl := c.window + 2*c.syncInterval
+
+	pipe := c.client.Pipeline()
+	for name, counts := range pending {
+		key := c.bucketKey(name, current)
+		pipe.HIncrBy(ctx, key, "successes", counts.successes)
+		pipe.HIncrBy(ctx, key, "failures", counts.failures)
+		pipe.PExpire(ctx, key, ttl)
+	}
+
+	trips := map[string]*redis.IntCmd{}
+	readings := map[string][]*redis.MapStringStringCmd{}
+	for name := range breakers {
+		trips[name] = pipe.Exists(ctx, c.tripKey(name))
+		first := current - buckets + 1
+		if bucket, ok := closed[name]; ok {
+			first = max(first, bucket+1)
+		}
+		for bucket := first; bucket <= current; bucket++ {
+			readings[name] = append(readings[name], pipe.HGetAll(ctx, c.bucketKey(name, bucket)))
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	// the breakers that closed while reading have their aggregate start over at the next sync
+	c.mutex.Lock()
+	for name, bucket := range c.closed {
+		if previous, ok := closed[name]; !ok || previous != bucket {
+			delete(breakers, name)
+		}
+	}
+	c.mutex.Unlock()
+
+	for name, breaker := range breakers {
+		if trips[name].Val() > 0 {
+			c.trip(breaker, "trip recorded by another replica")
+			continue
+		}
+
+		fleet := aggregate(readings[name])
+		if breaker.CurrentSettings().Thresholds.Exceeded(fleet) {
+			c.trip(breaker, "fleet failure rate crossed the threshold",
+				slog.Int("fleet_requests", fleet.RequestCount),
+				slog.Float64("fleet_failure_rate", fleet.FailureRate()),
+			)
+		}
+	}
+	return nil
+}
+
+func aggregate(readings []*redis.MapStringStringCmd) gauges.Aggregate {
+	var fleet gauges.Aggregate
+	for _, reading := range readings {
+		values := reading.Val()
+		successes, _ := strconv.Atoi(values["successes"])
+		failures, _ := strconv.Atoi(values["failures"])
+		fleet.SuccessCount += successes
+		fleet.FailureCount += failures
+	}
+	fleet.RequestCount = fleet.SuccessCount + fleet.FailureCount
+	return fleet
+}
+
+// trip only opens closed breakers, one that is half-open already waited for its cooldown and is better placed to
+// tell whether the dependency recovered
+func (c *Coordinator) trip(breaker *circuitbreaker.Breaker, reason string, attrs ...any) {
+	if breaker.State() != circuitbreaker.Closed || !breaker.Trip() || c.logger == nil {
+		return
+	}
+
+	c.logger.Warn("circuit breaker opened by the fleet",
+		append([]any{slog.String("name", breaker.Name()), slog.String("reason", reason)}, attrs...)...)
+}
+
+// setHealthy logs when Redis stops and starts responding, errors caused by Close are not reported
+func (c *Coordinator) setHealthy(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	changed := c.healthy != (err == nil)
+	c.healthy = err == nil
+	c.mutex.Unlock()
+
+	if !changed || c.logger == nil {
+		return
+	}
+
+	if err != nil {
+		c.logger.Warn("circuit breaker coordination unavailable, using local state only", slog.String("error", err.Error()))
+	} else {
+		c.logger.Info("circuit breaker coordination restored")
+	}
+}
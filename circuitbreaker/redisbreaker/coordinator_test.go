@@ -0,0 +1,168 @@
+package redisbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/redisbreaker"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const syncInterval = 20 * time.Millisecond
+
+// replica is one instance of a service sharing the payments breaker with the others
+type replica struct {
+	coordinator *redisbreaker.Coordinator
+	payments    *circuitbreaker.Breaker
+}
+
+func newReplica(t *testing.T, addr string) *replica {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+
+	coordinator := redisbreaker.New(client, redisbreaker.WithSyncInterval(syncInterval), redisbreaker.WithWindow(time.Second))
+	t.Cleanup(coordinator.Close)
+
+	settings, _ := circuitbreaker.NewSettings("payments",
+		circuitbreaker.WithInstrumentation(coordinator),
+		circuitbreaker.WithMinRequest(10),
+		circuitbreaker.WithFailureRate(50),
+		circuitbreaker.WithCooldownDuration(time.Minute),
+	)
+	payments, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	if err := coordinator.Register(payments); err != nil {
+		t.Fatalf("Coordinator.Register, expected no err, got %s", err)
+	}
+	return &replica{coordinator: coordinator, payments: payments}
+}
+
+func (r *replica) fail(times int) {
+	for i := 0; i < times; i++ {
+		r.payments.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+			return nil, errors.New("payments unavailable")
+		})
+	}
+}
+
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s, expected within 2s", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTripOpensFleet(t *testing.T) {
+	server := miniredis.RunT(t)
+	first, second, third := newReplica(t, server.Addr()), newReplica(t, server.Addr()), newReplica(t, server.Addr())
+
+	// let the subscriptions settle before publishing
+	time.Sleep(2 * syncInterval)
+	first.fail(10)
+	if first.payments.State() != circuitbreaker.Open {
+		t.Fatalf("first replica, expected %s, got %s", circuitbreaker.Open, first.payments.State())
+	}
+
+	for _, r := range []*replica{second, third} {
+		eventually(t, "other replicas open", func() bool { return r.payments.State() == circuitbreaker.Open })
+	}
+
+	if ttl := server.TTL("circuitbreaker:{payments}:trip"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("trip key TTL, expected within the cooldown, got %s", ttl)
+	}
+}
+
+func TestTripKeyOpensLateReplica(t *testing.T) {
+	server := miniredis.RunT(t)
+	first := newReplica(t, server.Addr())
+	first.payments.ForceState(circuitbreaker.Open)
+	eventually(t, "trip key recorded", func() bool { return server.Exists("circuitbreaker:{payments}:trip") })
+
+	late := newReplica(t, server.Addr())
+	eventually(t, "late replica opens", func() bool { return late.payments.State() == circuitbreaker.Open })
+}
+
+func TestFleetAggregate(t *testing.T) {
+	server := miniredis.RunT(t)
+	first, second := newReplica(t, server.Addr()), newReplica(t, server.Addr())
+
+	// neither replica sees enough requests to open on its own
+	first.fail(6)
+	second.fail(6)
+	if first.payments.State() != circuitbreaker.Closed || second.payments.State() != circuitbreaker.Closed {
+		t.Fatalf("replicas, expected closed below the minimum requests, got %s and %s", first.payments.State(), second.payments.State())
+	}
+
+	eventually(t, "fleet aggregate opens both replicas", func() bool {
+		return first.payments.State() == circuitbreaker.Open && second.payments.State() == circuitbreaker.Open
+	})
+}
+
+func TestFleetAggregateAfterClosing(t *testing.T) {
+	server := miniredis.RunT(t)
+	first, second := newReplica(t, server.Addr()), newReplica(t, server.Addr())
+
+	first.fail(6)
+	second.fail(6)
+	eventually(t, "fleet aggregate opens both replicas", func() bool {
+		return first.payments.State() == circuitbreaker.Open && second.payments.State() == circuitbreaker.Open
+	})
+
+	// as if the cooldown was over long before the window, the outcomes that opened the breakers must not open
+	// them again
+	time.Sleep(2 * syncInterval)
+	server.Del("circuitbreaker:{payments}:trip")
+	first.payments.ForceState(circuitbreaker.Closed)
+	second.payments.ForceState(circuitbreaker.Closed)
+	time.Sleep(5 * syncInterval)
+	if first.payments.State() != circuitbreaker.Closed || second.payments.State() != circuitbreaker.Closed {
+		t.Errorf("replicas after closing, expected closed, got %s and %s", first.payments.State(), second.payments.State())
+	}
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	r := newReplica(t, server.Addr())
+
+	server.Close()
+	eventually(t, "coordinator unhealthy", func() bool { return !r.coordinator.Healthy() })
+
+	r.fail(10)
+	if r.payments.State() != circuitbreaker.Open {
+		t.Errorf("local state without redis, expected %s, got %s", circuitbreaker.Open, r.payments.State())
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("miniredis.Restart, expected no err, got %s", err)
+	}
+	eventually(t, "coordinator healthy again", r.coordinator.Healthy)
+}
+
+func TestRegister(t *testing.T) {
+	server := miniredis.RunT(t)
+	coordinator := redisbreaker.New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	defer coordinator.Close()
+
+	plain, _ := circuitbreaker.NewBreaker("payments")
+	if err := coordinator.Register(plain); !errors.As(err, &redisbreaker.ErrNotInstrumented{}) {
+		t.Errorf("Register without instrumentation, expected ErrNotInstrumented, got %v", err)
+	}
+
+	settings, _ := circuitbreaker.NewSettings("payments", circuitbreaker.WithInstrumentation(coordinator))
+	instrumented, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	coordinator.Register(instrumented)
+	if err := coordinator.Register(instrumented); !errors.As(err, &redisbreaker.ErrDuplicateBreaker{}) {
+		t.Errorf("Register twice, expected ErrDuplicateBreaker, got %v", err)
+	}
+}
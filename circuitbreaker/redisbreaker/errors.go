@@ -0,0 +1,24 @@
+package redisbreaker
+
+import (
+	"fmt"
+)
+
+// ErrNotInstrumented gets returned when registering a breaker that was not created with the coordinator as one
+// of its instrumentations, the coordinator would not learn about its outcomes and transitions
+type ErrNotInstrumented struct {
+	Name string
+}
+
+func (e ErrNotInstrumented) Error() string {
+	return fmt.Sprintf("circuit breaker %s is not instrumented by the coordinator", e.Name)
+}
+
+// ErrDuplicateBreaker gets returned when registering a breaker whose name is already taken
+type ErrDuplicateBreaker struct {
+	Name string
+}
+
+func (e ErrDuplicateBreaker) Error() string {
+	return fmt.Sprintf("circuit breaker %s is already registered", e.Name)
+}
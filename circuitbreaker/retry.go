@@ -0,0 +1,146 @@
+package circuitbreaker
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryMaxAttempts is the number of attempts of a call, the first one included
+const DefaultRetryMaxAttempts int = 3
+
+// DefaultRetryInitialBackoff is the wait before the first retry
+const DefaultRetryInitialBackoff time.Duration = 100 * time.Millisecond
+
+// DefaultRetryMaxBackoff caps the wait between two attempts
+const DefaultRetryMaxBackoff time.Duration = 10 * time.Second
+
+// DefaultRetryMultiplier is the factor the backoff grows by after every attempt
+const DefaultRetryMultiplier float64 = 2.0
+
+// DefaultRetryJitter lets every wait be shortened by up to a fifth of the backoff
+const DefaultRetryJitter float64 = 0.2
+
+// Retry runs failed calls again after an exponential backoff. Every attempt goes through the breaker like a call
+// of its own, so retries stop as soon as the breaker or any of its limits rejects one: the rejection is returned,
+// and the previous failure is recorded as the outcome of the call. Retries also stop on an ignored attempt, once
+// the context is done and when the Budget is exhausted.
+//
+// By default only the outcome of the last attempt is recorded in the gauge, so a call counts once however many
+// times it was attempted. RecordAttempts records every attempt instead, which trips the circuit sooner when a
+// dependency starts failing.
+type Retry struct {
+	// MaxAttempts is the number of attempts of a call, the first one included, DefaultRetryMaxAttempts when 0
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, DefaultRetryInitialBackoff when 0
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts, DefaultRetryMaxBackoff when 0
+	MaxBackoff time.Duration
+	// Multiplier is the factor the backoff grows by after every attempt, DefaultRetryMultiplier when 0
+	Multiplier float64
+	// Jitter is the fraction of the backoff every wait can randomly be shortened by, between 0 and 1, it spreads
+	// the retries of concurrent callers
+	Jitter float64
+	// IsRetryable tells whether a failed attempt is worth retrying, every failed attempt is retried when nil
+	IsRetryable IsRetryableHandler
+	// RecordAttempts records every attempt in the gauge instead of only the outcome of the call
+	RecordAttempts bool
+	// Budget optionally caps the retries relative to the calls, it can be shared with other breakers, see
+	// RetryBudget
+	Budget *RetryBudget
+	// Random returns numbers in [0, 1) used for the jitter, it defaults to math/rand/v2.Float64 and can be set
+	// for reproducible tests
+	Random func() float64
+}
+
+// IsRetryableHandler gets called back with a failed attempt to determine if it should be retried
+type IsRetryableHandler func(*http.Response, error) bool
+
+// WithRetry retries failed calls, see Retry. Zero fields take their default, set Jitter to a negative value to
+// wait exactly the backoff.
+func WithRetry(retry Retry) SettingsOption {
+	return func(s *Settings) {
+		if retry.MaxAttempts == 0 {
+			retry.MaxAttempts = DefaultRetryMaxAttempts
+		}
+		if retry.InitialBackoff == 0 {
+			retry.InitialBackoff = DefaultRetryInitialBackoff
+		}
+		if retry.MaxBackoff == 0 {
+			retry.MaxBackoff = DefaultRetryMaxBackoff
+		}
+		if retry.Multiplier == 0 {
+			retry.Multiplier = DefaultRetryMultiplier
+		}
+		switch {
+		case retry.Jitter == 0:
+			retry.Jitter = DefaultRetryJitter
+		case retry.Jitter < 0:
+			retry.Jitter = 0
+		}
+		s.Retry = &retry
+	}
+}
+
+func (r Retry) validate() error {
+	if r.MaxAttempts < 1 {
+		return ErrInvalidSettingParam{Param: "Retry.MaxAttempts", Val: r.MaxAttempts}
+	}
+	if r.InitialBackoff < 0 {
+		return ErrInvalidSettingParam{Param: "Retry.InitialBackoff", Val: r.InitialBackoff}
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		return ErrInvalidSettingParam{Param: "Retry.MaxBackoff", Val: r.MaxBackoff}
+	}
+	if r.Multiplier < 1 || math.IsInf(r.Multiplier, 0) {
+		return ErrInvalidSettingParam{Param: "Retry.Multiplier", Val: r.Multiplier}
+	}
+	if r.Jitter < 0 || r.Jitter > 1 || math.IsNaN(r.Jitter) {
+		return ErrInvalidSettingParam{Param: "Retry.Jitter", Val: r.Jitter}
+	}
+	return nil
+}
+
+// Backoff returns the wait after the given failed attempt, starting at 1, before the jitter is applied
+func (r Retry) Backoff(attempt int) time.Duration {
+	backoff := float64(r.InitialBackoff) * math.Pow(r.Multiplier, float64(attempt-1))
+	if backoff >= float64(r.MaxBackoff) {
+		return r.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// retries reports whether the given failed attempt should be retried, a nil policy never retries
+func (r *Retry) retries(ctx context.Context, resp *http.Response, err error, attempt int) bool {
+	if r == nil || attempt >= r.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	return r.IsRetryable == nil || r.IsRetryable(resp, err)
+}
+
+// wait sleeps for the jittered backoff of the given attempt, it returns the error of the context if it is done
+// first
+func (r *Retry) wait(ctx context.Context, attempt int) error {
+	backoff := r.Backoff(attempt)
+	if r.Jitter > 0 {
+		random := r.Random
+		if random == nil {
+			random = rand.Float64
+		}
+		backoff -= time.Duration(float64(backoff) * r.Jitter * random())
+	}
+	if backoff <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
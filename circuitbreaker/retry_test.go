@@ -0,0 +1,164 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// flakyHandler fails the given number of calls before succeeding, it counts the calls it got
+func flakyHandler(failures int, calls *int) circuitbreaker.ExecuteContextHandler {
+	return func(ctx context.Context, name string) (*http.Response, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, errors.New("failed")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	retry := circuitbreaker.Retry{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	testCases := map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	}
+
+	for attempt, expected := range testCases {
+		if got := retry.Backoff(attempt); got != expected {
+			t.Errorf("retry.Backoff(%d), expected %s, got %s", attempt, expected, got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{InitialBackoff: time.Millisecond}))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	calls := 0
+	resp, err := cb.ExecuteContext(context.Background(), flakyHandler(2, &calls))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("cb.ExecuteContext, expected the third attempt to succeed, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls, expected 3, got %d", calls)
+	}
+
+	aggregate := cb.Aggregate()
+	if aggregate.RequestCount != 1 || aggregate.SuccessCount != 1 {
+		t.Errorf("cb.Aggregate, expected only the final success, got %d requests and %d successes", aggregate.RequestCount, aggregate.SuccessCount)
+	}
+	if counts := cb.Counts(); counts.Retries != 2 || counts.Failures != 2 || counts.Successes != 1 {
+		t.Errorf("cb.Counts, expected 2 retries, 2 failures and 1 success, got %+v", counts)
+	}
+
+	events := receiveEvents(t, sub, 6)
+	if last := events[5]; last.Type != circuitbreaker.CallSucceeded || last.Attempt != 3 {
+		t.Errorf("event, expected call-succeeded on attempt 3, got %s on attempt %d", last.Type, last.Attempt)
+	}
+
+	calls = 0
+	_, err = cb.ExecuteContext(context.Background(), flakyHandler(5, &calls))
+	if err == nil || calls != circuitbreaker.DefaultRetryMaxAttempts {
+		t.Errorf("cb.ExecuteContext, expected %d failed attempts, got %d with %v", circuitbreaker.DefaultRetryMaxAttempts, calls, err)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 2 || aggregate.FailureCount != 1 {
+		t.Errorf("cb.Aggregate, expected the call to fail once, got %d requests and %d failures", aggregate.RequestCount, aggregate.FailureCount)
+	}
+}
+
+func TestRetryRecordAttempts(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{RecordAttempts: true, InitialBackoff: time.Millisecond}))
+
+	calls := 0
+	cb.ExecuteContext(context.Background(), flakyHandler(2, &calls))
+
+	aggregate := cb.Aggregate()
+	if aggregate.RequestCount != 3 || aggregate.FailureCount != 2 {
+		t.Errorf("cb.Aggregate, expected every attempt, got %d requests and %d failures", aggregate.RequestCount, aggregate.FailureCount)
+	}
+}
+
+func TestRetryIsRetryable(t *testing.T) {
+	permanent := errors.New("permanent")
+	retry := circuitbreaker.Retry{InitialBackoff: time.Millisecond, IsRetryable: func(r *http.Response, e error) bool {
+		return !errors.Is(e, permanent)
+	}}
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(retry))
+
+	calls := 0
+	_, err := cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		calls++
+		return nil, permanent
+	})
+	if err != permanent || calls != 1 {
+		t.Errorf("cb.ExecuteContext, expected a single attempt, got %d with %v", calls, err)
+	}
+}
+
+func TestRetryStopsOnRejection(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{MaxAttempts: 5, InitialBackoff: time.Millisecond}), circuitbreaker.WithMinRequest(1))
+
+	calls := 0
+	_, err := cb.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		calls++
+		cb.Trip()
+		return nil, errors.New("failed")
+	})
+
+	var notPermitted circuitbreaker.ErrRequestNotPermitted
+	if !errors.As(err, &notPermitted) || calls != 1 {
+		t.Errorf("cb.ExecuteContext, expected the retry to be rejected after 1 attempt, got %d with %v", calls, err)
+	}
+	if counts := cb.Counts(); counts.Failures != 1 || counts.Rejections != 1 {
+		t.Errorf("cb.Counts, expected 1 failure and 1 rejection, got %d and %d", counts.Failures, counts.Rejections)
+	}
+}
+
+func TestRetryContextDone(t *testing.T) {
+	retry := circuitbreaker.Retry{MaxAttempts: 5}
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithRetry(retry))
+	settings.Retry.InitialBackoff = time.Hour
+	settings.Retry.MaxBackoff = time.Hour
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := cb.ExecuteContext(ctx, flakyHandler(5, &calls))
+	if err == nil || err.Error() != "failed" || calls != 1 {
+		t.Errorf("cb.ExecuteContext, expected the failed attempt once the wait is cancelled, got %d with %v", calls, err)
+	}
+	if aggregate := cb.Aggregate(); aggregate.FailureCount != 1 {
+		t.Errorf("cb.Aggregate, expected the failure to be recorded, got %d failures", aggregate.FailureCount)
+	}
+}
+
+func TestRetrySettings(t *testing.T) {
+	testCases := map[string]struct {
+		retry    circuitbreaker.Retry
+		expected circuitbreaker.ErrInvalidSettingParam
+	}{
+		"MaxAttempts": {retry: circuitbreaker.Retry{MaxAttempts: -1}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Retry.MaxAttempts", Val: -1}},
+		"MaxBackoff":  {retry: circuitbreaker.Retry{InitialBackoff: time.Minute, MaxBackoff: time.Second}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Retry.MaxBackoff", Val: time.Second}},
+		"Multiplier":  {retry: circuitbreaker.Retry{Multiplier: 0.5}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Retry.Multiplier", Val: 0.5}},
+		"Jitter":      {retry: circuitbreaker.Retry{Jitter: 2}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Retry.Jitter", Val: 2.0}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithRetry(tc.retry))
+			if err != tc.expected {
+				t.Errorf("circuitbreaker.NewSettings, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package circuitbreaker
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultRetryBudgetPercent lets retries add up to a fifth of the calls
+const DefaultRetryBudgetPercent float64 = 20.0
+
+// DefaultRetryBudgetMinPerSecond lets a few retries through even when there are few calls
+const DefaultRetryBudgetMinPerSecond float64 = 10.0
+
+// DefaultRetryBudgetTTL is how long calls and retries are remembered by a budget
+const DefaultRetryBudgetTTL time.Duration = 10 * time.Second
+
+// retryBudgetBuckets is the number of buckets the TTL of a budget is split into, calls and retries expire one
+// bucket at a time
+const retryBudgetBuckets = 10
+
+// RetryBudget caps the amplification of retries, so that an outage does not multiply the load on a dependency by
+// the number of attempts. It is a leaky token bucket: every call deposits Percent/100 of a token, every retry
+// withdraws a whole one, and both expire after the TTL. On top of the deposits the bucket holds a reserve of
+// MinPerSecond tokens for every second of the TTL, so retries stay possible when calls are rare.
+//
+// Retries over the budget are not attempted, the failure they were retrying is returned instead and a
+// RetryBudgetExhausted event is emitted. A budget can be shared by the Retry policies of several breakers, and is
+// safe for concurrent use. The zero value is a budget with DefaultRetryBudgetPercent, DefaultRetryBudgetMinPerSecond
+// and DefaultRetryBudgetTTL.
+type RetryBudget struct {
+	mutex   sync.Mutex
+	ratio   float64
+	reserve float64
+	width   time.Duration
+	buckets [retryBudgetBuckets]retryBudgetBucket
+}
+
+type retryBudgetBucket struct {
+	epoch   int64
+	calls   float64
+	retries float64
+}
+
+// NewRetryBudget creates a budget letting retries reach percent of the calls, plus minPerSecond retries every
+// second, over a sliding window of ttl
+func NewRetryBudget(percent float64, minPerSecond float64, ttl time.Duration) (*RetryBudget, error) {
+	if percent < 0 || math.IsNaN(percent) || math.IsInf(percent, 0) {
+		return nil, ErrInvalidSettingParam{Param: "RetryBudget.Percent", Val: percent}
+	}
+	if minPerSecond < 0 || math.IsNaN(minPerSecond) || math.IsInf(minPerSecond, 0) {
+		return nil, ErrInvalidSettingParam{Param: "RetryBudget.MinPerSecond", Val: minPerSecond}
+	}
+	if ttl < time.Second {
+		return nil, ErrInvalidSettingParam{Param: "RetryBudget.TTL", Val: ttl}
+	}
+
+	return &RetryBudget{
+		ratio:   percent / 100,
+		reserve: minPerSecond * ttl.Seconds(),
+		width:   ttl / retryBudgetBuckets,
+	}, nil
+}
+
+// Balance returns the number of retries currently allowed
+func (b *RetryBudget) Balance() float64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.balance(b.epoch(time.Now()))
+}
+
+// deposit records a call, a nil budget does nothing
+func (b *RetryBudget) deposit() {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.bucket(b.epoch(time.Now())).calls++
+}
+
+// withdraw records a retry if the budget allows it, a nil budget allows every retry
+func (b *RetryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	epoch := b.epoch(time.Now())
+	if b.balance(epoch) < 1 {
+		return false
+	}
+	b.bucket(epoch).retries++
+	return true
+}
+
+// epoch returns the bucket of the time, a zero value budget takes the defaults the first time it is used
+func (b *RetryBudget) epoch(now time.Time) int64 {
+	if b.width == 0 {
+		b.ratio = DefaultRetryBudgetPercent / 100
+		b.reserve = DefaultRetryBudgetMinPerSecond * DefaultRetryBudgetTTL.Seconds()
+		b.width = DefaultRetryBudgetTTL / retryBudgetBuckets
+	}
+	return now.UnixNano() / int64(b.width)
+}
+
+// bucket returns the bucket of the epoch, emptied if it was last used by an expired epoch
+func (b *RetryBudget) bucket(epoch int64) *retryBudgetBucket {
+	bucket := &b.buckets[epoch%retryBudgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = retryBudgetBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+func (b *RetryBudget) balance(epoch int64) float64 {
+	var calls, retries float64
+	for _, bucket := range b.buckets {
+		if epoch-bucket.epoch < retryBudgetBuckets {
+			calls += bucket.calls
+			retries += bucket.retries
+		}
+	}
+	return b.reserve + b.ratio*calls - retries
+}
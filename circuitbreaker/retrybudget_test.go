@@ -0,0 +1,109 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+func TestRetryBudget(t *testing.T) {
+	budget, err := circuitbreaker.NewRetryBudget(20, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewRetryBudget, expected no err, got %s", err)
+	}
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{MaxAttempts: 2, Budget: budget, InitialBackoff: time.Millisecond}), circuitbreaker.WithMinRequest(100))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	// every call deposits a fifth of a retry, so only the fifth and the tenth call are retried
+	calls := 0
+	for i := 0; i < 10; i++ {
+		cb.ExecuteContext(context.Background(), flakyHandler(100, &calls))
+	}
+
+	if calls != 12 {
+		t.Errorf("calls, expected 10 calls and 2 retries, got %d attempts", calls)
+	}
+	if counts := cb.Counts(); counts.Retries != 2 || counts.RetriesDenied != 8 {
+		t.Errorf("cb.Counts, expected 2 retries and 8 denied, got %d and %d", counts.Retries, counts.RetriesDenied)
+	}
+	if balance := budget.Balance(); balance != 0 {
+		t.Errorf("budget.Balance, expected 0, got %v", balance)
+	}
+
+	var exhausted *circuitbreaker.Event
+	for _, event := range receiveEvents(t, sub, 30) {
+		if event.Type == circuitbreaker.RetryBudgetExhausted {
+			exhausted = &event
+			break
+		}
+	}
+	if exhausted == nil || exhausted.Attempt != 1 || exhausted.Err == nil {
+		t.Errorf("event, expected retry-budget-exhausted after attempt 1 with its error, got %+v", exhausted)
+	}
+}
+
+func TestRetryBudgetMinPerSecond(t *testing.T) {
+	budget, _ := circuitbreaker.NewRetryBudget(0, 1, 3*time.Second)
+	if balance := budget.Balance(); balance != 3 {
+		t.Errorf("budget.Balance, expected a reserve of 3, got %v", balance)
+	}
+
+	// the budget is shared, retries of either breaker spend it
+	payments := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{MaxAttempts: 10, Budget: budget, InitialBackoff: time.Millisecond}))
+	orders := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{MaxAttempts: 10, Budget: budget, InitialBackoff: time.Millisecond}))
+
+	calls := 0
+	payments.ExecuteContext(context.Background(), flakyHandler(2, &calls))
+	_, err := orders.ExecuteContext(context.Background(), func(ctx context.Context, name string) (*http.Response, error) {
+		calls++
+		return nil, errors.New("failed")
+	})
+
+	if err == nil || calls != 5 {
+		t.Errorf("ExecuteContext, expected 2 calls and 3 retries, got %d attempts with %v", calls, err)
+	}
+	if counts := orders.Counts(); counts.Retries != 1 || counts.RetriesDenied != 1 {
+		t.Errorf("orders.Counts, expected 1 retry and 1 denied, got %d and %d", counts.Retries, counts.RetriesDenied)
+	}
+}
+
+func TestRetryBudgetZeroValue(t *testing.T) {
+	budget := &circuitbreaker.RetryBudget{}
+	expected := circuitbreaker.DefaultRetryBudgetMinPerSecond * circuitbreaker.DefaultRetryBudgetTTL.Seconds()
+	if balance := budget.Balance(); balance != expected {
+		t.Errorf("budget.Balance, expected the default reserve of %v, got %v", expected, balance)
+	}
+
+	cb := newTestBreaker(t, circuitbreaker.WithRetry(circuitbreaker.Retry{Budget: &circuitbreaker.RetryBudget{}, InitialBackoff: time.Millisecond}))
+	calls := 0
+	if _, err := cb.ExecuteContext(context.Background(), flakyHandler(1, &calls)); err != nil || calls != 2 {
+		t.Errorf("cb.ExecuteContext, expected a retry to succeed, got %d attempts with %v", calls, err)
+	}
+}
+
+func TestRetryBudgetSettings(t *testing.T) {
+	testCases := map[string]struct {
+		percent      float64
+		minPerSecond float64
+		ttl          time.Duration
+		expected     circuitbreaker.ErrInvalidSettingParam
+	}{
+		"Percent":      {percent: -1, ttl: time.Second, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RetryBudget.Percent", Val: -1.0}},
+		"MinPerSecond": {minPerSecond: -1, ttl: time.Second, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RetryBudget.MinPerSecond", Val: -1.0}},
+		"TTL":          {ttl: time.Millisecond, expected: circuitbreaker.ErrInvalidSettingParam{Param: "RetryBudget.TTL", Val: time.Millisecond}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := circuitbreaker.NewRetryBudget(tc.percent, tc.minPerSecond, tc.ttl)
+			if err != tc.expected {
+				t.Errorf("circuitbreaker.NewRetryBudget, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package circuitbreaker
 
 import (
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -10,6 +11,9 @@ import (
 //IsSUCcessfulHandler gets called back to determine if the response is a success
 type IsSuccessfulHandler func(*http.Response, error) bool
 
+//IsIgnoredHandler gets called back to determine if the outcome of a response should not be recorded at all
+type IsIgnoredHandler func(*http.Response, error) bool
+
 //OnStateChangeHandler gets called back when circuit breaker switches states
 type OnStateChangeHandler func(name string, from State, to State)
 
@@ -29,6 +33,11 @@ type Thresholds struct {
 	MinRequests int
 }
 
+//Exceeded reports whether the aggregate is bad enough to open a closed circuit
+func (t Thresholds) Exceeded(aggregate gauges.Aggregate) bool {
+	return aggregate.RequestCount >= t.MinRequests && aggregate.FailureRate() > t.FailureRate
+}
+
 //Settings is a collection of settings and options used with the circuit breaker and its internal members
 type Settings struct {
 	//Name is used to label the circuit breaker to help distinguish them
@@ -37,10 +46,34 @@ type Settings struct {
 	Thresholds Thresholds
 	//IsSuccessful callback to help determin whether or not a request is successful
 	IsSuccessful IsSuccessfulHandler
-	//OnStateChange called back when states have transitioned
+	//IsIgnored optional callback to leave some outcomes out of the gauge, such as requests cancelled by the caller
+	IsIgnored IsIgnoredHandler
+	//OnStateChange called back when states have transitioned, including forced transitions and resets
 	OnStateChange OnStateChangeHandler
 	//Gauge is used to collect metric to analyze the status of the requests
 	Gauge gauges.Gauge
+	//Instrumentations are notified of every call and transition, see Instrumentation
+	Instrumentations []Instrumentation
+	//EventListeners each receive every event of the breaker on their own goroutine, until Breaker.Close
+	EventListeners []EventListener
+	//Logger receives transitions, rejections and misconfigurations, nothing is logged when it is nil
+	Logger *slog.Logger
+	//StateStore optionally persists the state of the breaker across restarts, see StateStore
+	StateStore StateStore
+	//Bulkhead optionally bounds the calls running at once, see Bulkhead
+	Bulkhead *Bulkhead
+	//Limiter optionally adapts the number of calls allowed to run at once, see Limiter
+	Limiter Limiter
+	//Throttle optionally rejects calls with a probability growing with failures instead of opening the circuit
+	Throttle *Throttle
+	//Retry optionally retries failed calls with a backoff, see Retry
+	Retry *Retry
+	//Hedge optionally hedges the calls made with ExecuteHedged, see Hedge
+	Hedge *Hedge
+	//RateLimit optionally bounds the rate of calls, following the state of the circuit, see RateLimit
+	RateLimit *RateLimit
+	//Shedding optionally rejects the calls of a lower criticality first, see Shedding
+	Shedding *Shedding
 }
 
 //DefaultFailureRate default failure rate set to 10%
@@ -88,12 +121,22 @@ func NewSettings(name string, opts ...SettingsOption) (*Settings, error) {
 	}
 
 	if err := settings.Validate(); err != nil {
+		settings.logInvalid(err)
 		return nil, err
 	}
 
 	return settings, nil
 }
 
+// clone copies the settings along with their slices, so that options appending to the copy leave the original
+// untouched
+func (s *Settings) clone() *Settings {
+	clone := *s
+	clone.Instrumentations = append([]Instrumentation(nil), s.Instrumentations...)
+	clone.EventListeners = append([]EventListener(nil), s.EventListeners...)
+	return &clone
+}
+
 func (s *Settings) Validate() error {
 	if s.Thresholds.CooldownDuration <= 0 {
 		return ErrInvalidSettingParam{Param: "CooldownDuration", Val: s.Thresholds.CooldownDuration}
@@ -115,6 +158,42 @@ func (s *Settings) Validate() error {
 		return ErrInvalidSettingParam{Param: "IsSuccessful", Val: nil}
 	}
 
+	if s.Bulkhead != nil {
+		if err := s.Bulkhead.validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.Throttle != nil {
+		if err := s.Throttle.validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.Retry != nil {
+		if err := s.Retry.validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.Hedge != nil {
+		if err := s.Hedge.validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.RateLimit != nil {
+		if err := s.RateLimit.validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.Shedding != nil {
+		if err := s.Shedding.validate(s.Thresholds); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -142,6 +221,12 @@ func WithIsSuccessfulHandler(handler IsSuccessfulHandler) SettingsOption {
 	}
 }
 
+func WithIsIgnoredHandler(handler IsIgnoredHandler) SettingsOption {
+	return func(s *Settings) {
+		s.IsIgnored = handler
+	}
+}
+
 func WithOnStateChangeHandler(handler OnStateChangeHandler) SettingsOption {
 	return func(s *Settings) {
 		s.OnStateChange = handler
@@ -165,3 +250,32 @@ func WithGauge(gauge gauges.Gauge) SettingsOption {
 		s.Gauge = gauge
 	}
 }
+
+// WithInstrumentation adds an Instrumentation to the ones notified by the breaker
+func WithInstrumentation(instrumentation Instrumentation) SettingsOption {
+	return func(s *Settings) {
+		s.Instrumentations = append(s.Instrumentations, instrumentation)
+	}
+}
+
+// WithEventListener registers a listener that gets every event of the breaker, see Breaker.Subscribe. The listener
+// runs on a goroutine of its own until Breaker.Close is called, it is only started when the breaker is created.
+func WithEventListener(listener EventListener) SettingsOption {
+	return func(s *Settings) {
+		s.EventListeners = append(s.EventListeners, listener)
+	}
+}
+
+// WithLogger sets the logger used for diagnostics, by default the breaker does not log anything
+func WithLogger(logger *slog.Logger) SettingsOption {
+	return func(s *Settings) {
+		s.Logger = logger
+	}
+}
+
+// WithStateStore restores the breaker from the store when it is created and saves its state on every change
+func WithStateStore(store StateStore) SettingsOption {
+	return func(s *Settings) {
+		s.StateStore = store
+	}
+}
@@ -0,0 +1,112 @@
+package circuitbreaker
+
+import (
+	"context"
+	"math"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Criticality tells how important a call is, calls of a lower criticality are shed first, see Shedding. It is
+// only given through the context of the call, see WithCriticality.
+type Criticality int
+
+const (
+	// Sheddable calls can be dropped whenever the dependency struggles, such as analytics
+	Sheddable Criticality = iota - 1
+	// Normal is the criticality of the calls that were not given one
+	Normal
+	// Critical calls must keep flowing as long as possible, such as checkout
+	Critical
+)
+
+func (c Criticality) String() string {
+	switch c {
+	case Sheddable:
+		return "sheddable"
+	case Normal:
+		return "normal"
+	case Critical:
+		return "critical"
+	default:
+		return "unknown criticality"
+	}
+}
+
+func (c Criticality) valid() bool {
+	return c >= Sheddable && c <= Critical
+}
+
+// criticalityKey is the context key the criticality of a call is stored under
+type criticalityKey struct{}
+
+// WithCriticality returns a context tagging the calls made with it with the criticality. The context is the only
+// way to give a call its criticality, so that it reaches the breaker through the packages wrapping it, such as
+// fallback and coalesce, and carries over to the retries and hedges of the call.
+func WithCriticality(ctx context.Context, criticality Criticality) context.Context {
+	return context.WithValue(ctx, criticalityKey{}, criticality)
+}
+
+// CriticalityFromContext returns the criticality the context was tagged with, Normal if none
+func CriticalityFromContext(ctx context.Context) Criticality {
+	if criticality, ok := ctx.Value(criticalityKey{}).(Criticality); ok {
+		return criticality
+	}
+	return Normal
+}
+
+// Shedding rejects the calls of a lower criticality first, with ErrShed, so that important calls keep flowing
+// when the dependency struggles. Calls are tagged with WithCriticality, untagged calls are Normal. Shed calls
+// are not recorded in the gauge.
+type Shedding struct {
+	// HalfOpen is the lowest criticality of the calls admitted while the circuit is half-open, so that the probes
+	// are taken by important calls, Normal admits every call but the sheddable ones
+	HalfOpen Criticality
+	// WarningFailureRate is a failure rate, lower than the FailureRate threshold opening the circuit, over which
+	// the calls below Warning are rejected while the circuit is closed, 0 disables it. The MinRequests threshold
+	// applies.
+	WarningFailureRate float64
+	// Warning is the lowest criticality of the calls admitted once the failure rate is over WarningFailureRate,
+	// Normal admits every call but the sheddable ones
+	Warning Criticality
+}
+
+// WithShedding sheds the calls of a lower criticality first, see Shedding
+func WithShedding(shedding Shedding) SettingsOption {
+	return func(s *Settings) {
+		s.Shedding = &shedding
+	}
+}
+
+func (s Shedding) validate(thresholds Thresholds) error {
+	if !s.HalfOpen.valid() {
+		return ErrInvalidSettingParam{Param: "Shedding.HalfOpen", Val: s.HalfOpen}
+	}
+	if !s.Warning.valid() {
+		return ErrInvalidSettingParam{Param: "Shedding.Warning", Val: s.Warning}
+	}
+	if s.WarningFailureRate < 0 || s.WarningFailureRate > 100 || math.IsNaN(s.WarningFailureRate) {
+		return ErrInvalidSettingParam{Param: "Shedding.WarningFailureRate", Val: s.WarningFailureRate}
+	}
+	// a warning rate at or over the failure rate would never shed anything before the circuit opens
+	if s.WarningFailureRate != 0 && s.WarningFailureRate >= thresholds.FailureRate {
+		return ErrInvalidSettingParam{Param: "Shedding.WarningFailureRate", Val: s.WarningFailureRate}
+	}
+	return nil
+}
+
+// sheds reports whether a call of the criticality is shed in the state, it must be called with the lock of the
+// breaker held
+func (s Shedding) sheds(criticality Criticality, state State, aggregate gauges.Aggregate, minRequests int) bool {
+	switch state {
+	case HalfOpen:
+		return criticality < s.HalfOpen
+	case Closed:
+		if s.WarningFailureRate == 0 || aggregate.RequestCount < minRequests {
+			return false
+		}
+		return aggregate.FailureRate() > s.WarningFailureRate && criticality < s.Warning
+	default:
+		return false
+	}
+}
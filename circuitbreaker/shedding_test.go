@@ -0,0 +1,104 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// admitted reports which criticalities the breaker currently admits
+func admitted(cb *circuitbreaker.Breaker) map[circuitbreaker.Criticality]bool {
+	admitted := map[circuitbreaker.Criticality]bool{}
+	for _, criticality := range []circuitbreaker.Criticality{circuitbreaker.Sheddable, circuitbreaker.Normal, circuitbreaker.Critical} {
+		ctx := circuitbreaker.WithCriticality(context.Background(), criticality)
+		_, err := cb.ExecuteContext(ctx, succeedingHandler)
+		admitted[criticality] = err == nil
+	}
+	return admitted
+}
+
+func TestCriticalityFromContext(t *testing.T) {
+	if criticality := circuitbreaker.CriticalityFromContext(context.Background()); criticality != circuitbreaker.Normal {
+		t.Errorf("circuitbreaker.CriticalityFromContext without a criticality, expected normal, got %s", criticality)
+	}
+
+	ctx := circuitbreaker.WithCriticality(context.Background(), circuitbreaker.Critical)
+	if criticality := circuitbreaker.CriticalityFromContext(ctx); criticality != circuitbreaker.Critical {
+		t.Errorf("circuitbreaker.CriticalityFromContext, expected critical, got %s", criticality)
+	}
+}
+
+func TestSheddingHalfOpen(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithShedding(circuitbreaker.Shedding{HalfOpen: circuitbreaker.Critical}), circuitbreaker.WithMinRequest(4), circuitbreaker.WithFailureRate(60))
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	if got := admitted(cb); !got[circuitbreaker.Sheddable] || !got[circuitbreaker.Normal] {
+		t.Errorf("admitted while closed, expected every call, got %v", got)
+	}
+
+	cb.ForceState(circuitbreaker.HalfOpen)
+	ctx := circuitbreaker.WithCriticality(context.Background(), circuitbreaker.Normal)
+	_, err := cb.ExecuteContext(ctx, succeedingHandler)
+	expectedErr := circuitbreaker.ErrShed{Name: "test", Criticality: circuitbreaker.Normal, State: circuitbreaker.HalfOpen}
+	if err != expectedErr {
+		t.Errorf("cb.ExecuteContext while half-open, expected %s, got %v", expectedErr, err)
+	}
+	if counts := cb.Counts(); counts.Shed != 1 || counts.Rejections != 0 {
+		t.Errorf("cb.Counts, expected 1 shed call and no rejection, got %d and %d", counts.Shed, counts.Rejections)
+	}
+
+	ctx = circuitbreaker.WithCriticality(context.Background(), circuitbreaker.Critical)
+	if _, err := cb.ExecuteContext(ctx, succeedingHandler); err != nil {
+		t.Errorf("cb.ExecuteContext of a critical call while half-open, expected no err, got %s", err)
+	}
+
+	var shed bool
+	for _, event := range receiveEvents(t, sub, 11) {
+		shed = shed || event.Type == circuitbreaker.Shed && errors.Is(event.Err, expectedErr)
+	}
+	if !shed {
+		t.Error("events, expected a shed event")
+	}
+}
+
+func TestSheddingWarningFailureRate(t *testing.T) {
+	cb := newTestBreaker(t, circuitbreaker.WithShedding(circuitbreaker.Shedding{WarningFailureRate: 20, Warning: circuitbreaker.Critical}), circuitbreaker.WithMinRequest(4), circuitbreaker.WithFailureRate(60))
+
+	// 2 failures out of 5 calls is over the warning rate but under the failure rate
+	for i := 0; i < 3; i++ {
+		cb.ExecuteContext(context.Background(), succeedingHandler)
+	}
+	cb.ExecuteContext(context.Background(), failingHandler)
+	cb.ExecuteContext(context.Background(), failingHandler)
+
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("cb.State, expected closed, got %s", cb.State())
+	}
+	got := admitted(cb)
+	if got[circuitbreaker.Sheddable] || got[circuitbreaker.Normal] || !got[circuitbreaker.Critical] {
+		t.Errorf("admitted over the warning rate, expected only critical calls, got %v", got)
+	}
+}
+
+func TestSheddingSettings(t *testing.T) {
+	testCases := map[string]struct {
+		shedding circuitbreaker.Shedding
+		expected circuitbreaker.ErrInvalidSettingParam
+	}{
+		"HalfOpen":               {shedding: circuitbreaker.Shedding{HalfOpen: 5}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Shedding.HalfOpen", Val: circuitbreaker.Criticality(5)}},
+		"WarningFailureRate":     {shedding: circuitbreaker.Shedding{WarningFailureRate: 101}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Shedding.WarningFailureRate", Val: 101.0}},
+		"WarningOverFailureRate": {shedding: circuitbreaker.Shedding{WarningFailureRate: circuitbreaker.DefaultFailureRate}, expected: circuitbreaker.ErrInvalidSettingParam{Param: "Shedding.WarningFailureRate", Val: circuitbreaker.DefaultFailureRate}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithShedding(tc.shedding))
+			if err != tc.expected {
+				t.Errorf("circuitbreaker.NewSettings, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
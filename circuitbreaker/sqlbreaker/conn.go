@@ -0,0 +1,176 @@
+package sqlbreaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// wrappedConn guards the calls that talk to the database. When the parent connection does not implement one
+// of the optional context interfaces the legacy one is used, and driver.ErrSkip is returned when neither is
+// available so that database/sql falls back to preparing a statement, which is guarded in turn. The interfaces
+// database/sql uses to manage connections and arguments are forwarded as they are, with the behavior database/sql
+// has when they are missing.
+type wrappedConn struct {
+	parent  driver.Conn
+	breaker *circuitbreaker.Breaker
+}
+
+var (
+	_ driver.Conn               = &wrappedConn{}
+	_ driver.ConnBeginTx        = &wrappedConn{}
+	_ driver.ConnPrepareContext = &wrappedConn{}
+	_ driver.ExecerContext      = &wrappedConn{}
+	_ driver.QueryerContext     = &wrappedConn{}
+	_ driver.Pinger             = &wrappedConn{}
+	_ driver.SessionResetter    = &wrappedConn{}
+	_ driver.Validator          = &wrappedConn{}
+	_ driver.NamedValueChecker  = &wrappedConn{}
+)
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext does not go through the breaker, the statement it returns does when it is executed
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.parent.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.parent.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{parent: stmt, conn: c, breaker: c.breaker}, nil
+}
+
+func (c *wrappedConn) Close() error {
+	return c.parent.Close()
+}
+
+// Begin is deprecated in database/sql/driver but still part of the driver.Conn interface
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, isBeginTx := c.parent.(driver.ConnBeginTx)
+	if !isBeginTx {
+		if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+			return nil, ErrTxOptionNotSupported{Option: "non-default isolation level"}
+		}
+		if opts.ReadOnly {
+			return nil, ErrTxOptionNotSupported{Option: "read-only"}
+		}
+	}
+
+	var tx driver.Tx
+	err := execute(ctx, c.breaker, func(ctx context.Context) (err error) {
+		if isBeginTx {
+			tx, err = beginner.BeginTx(ctx, opts)
+		} else {
+			tx, err = c.parent.Begin()
+		}
+		return err
+	})
+	return tx, err
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, isExecerContext := c.parent.(driver.ExecerContext)
+	legacy, isExecer := c.parent.(driver.Execer)
+	if !isExecerContext && !isExecer {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+	err := execute(ctx, c.breaker, func(ctx context.Context) (err error) {
+		if isExecerContext {
+			result, err = execer.ExecContext(ctx, query, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		result, err = legacy.Exec(query, values)
+		return err
+	})
+	return result, err
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, isQueryerContext := c.parent.(driver.QueryerContext)
+	legacy, isQueryer := c.parent.(driver.Queryer)
+	if !isQueryerContext && !isQueryer {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+	err := execute(ctx, c.breaker, func(ctx context.Context) (err error) {
+		if isQueryerContext {
+			rows, err = queryer.QueryContext(ctx, query, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = legacy.Query(query, values)
+		return err
+	})
+	return rows, err
+}
+
+// Ping goes through the breaker even when the parent cannot ping, so an open circuit is still reported to
+// database/sql callers checking connectivity
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	return execute(ctx, c.breaker, func(ctx context.Context) error {
+		if pinger, ok := c.parent.(driver.Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+		return nil
+	})
+}
+
+// ResetSession does not go through the breaker, it only prepares a pooled connection to be used again
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.parent.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if validator, ok := c.parent.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue returns driver.ErrSkip when the parent has no checker, so that database/sql converts the
+// argument itself
+func (c *wrappedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, ErrNamedArgsNotSupported{Name: arg.Name}
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
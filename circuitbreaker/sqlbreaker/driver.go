@@ -0,0 +1,90 @@
+// Package sqlbreaker wraps a database/sql/driver.Driver so that every connection it hands out is guarded by a
+// circuit breaker, the same way outbound HTTP calls are guarded by circuitbreaker.Breaker.Execute
+package sqlbreaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// Register makes a breaker guarded version of parent available to sql.Open under the given driver name.
+// Like sql.Register it panics if the name is already taken.
+func Register(name string, parent driver.Driver, breaker *circuitbreaker.Breaker) {
+	sql.Register(name, Wrap(parent, breaker))
+}
+
+// Wrap returns a driver that opens connections through parent and routes Begin, Exec, Query and Ping on
+// those connections, and Exec and Query on the statements they prepare, through the breaker. It can be used with
+// sql.OpenDB when registering a name is not wanted.
+func Wrap(parent driver.Driver, breaker *circuitbreaker.Breaker) driver.Driver {
+	return &wrappedDriver{parent: parent, breaker: breaker}
+}
+
+// NewBreaker creates a breaker that uses IsSuccessful to classify database errors. The options are applied
+// after the classifier is set, so callers can still override it.
+func NewBreaker(name string, opts ...circuitbreaker.SettingsOption) (*circuitbreaker.Breaker, error) {
+	opts = append([]circuitbreaker.SettingsOption{circuitbreaker.WithIsSuccessfulHandler(IsSuccessful)}, opts...)
+	settings, err := circuitbreaker.NewSettings(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return circuitbreaker.NewBreakerWithSettings(settings)
+}
+
+// IsSuccessful is an circuitbreaker.IsSuccessfulHandler for database calls. Only errors that point at the
+// database or the connection to it being unhealthy count as failures: driver.ErrBadConn, network errors,
+// unexpected EOFs and timeouts. Everything else, such as sql.ErrNoRows or a constraint violation, is a problem
+// with the query rather than the dependency and is treated as a success.
+func IsSuccessful(_ *http.Response, err error) bool {
+	return !IsConnectionError(err)
+}
+
+// IsConnectionError reports whether err means the database could not be reached or the connection broke
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, driver.ErrSkip) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type wrappedDriver struct {
+	parent  driver.Driver
+	breaker *circuitbreaker.Breaker
+}
+
+var _ driver.Driver = &wrappedDriver{}
+
+func (d *wrappedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.parent.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{parent: conn, breaker: d.breaker}, nil
+}
+
+// execute runs fn through the breaker, the http response is never used by database calls
+func execute(ctx context.Context, breaker *circuitbreaker.Breaker, fn func(ctx context.Context) error) error {
+	_, err := breaker.ExecuteContext(ctx, func(ctx context.Context, name string) (*http.Response, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
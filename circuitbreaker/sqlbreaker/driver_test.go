@@ -0,0 +1,447 @@
+package sqlbreaker_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/sqlbreaker"
+)
+
+// fakeDriver is an in-memory driver whose connections all return the configured error
+type fakeDriver struct {
+	mutex sync.Mutex
+	err   error
+	calls int
+	// opens and resets count the connections opened and reset by database/sql, invalid makes it discard them
+	opens   int
+	resets  int
+	invalid bool
+	// legacy opens connections that only implement driver.Conn
+	legacy bool
+}
+
+func (d *fakeDriver) setErr(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.err = err
+}
+
+func (d *fakeDriver) call() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.calls++
+	return d.err
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.opens++
+	if d.legacy {
+		return &legacyConn{driver: d}, nil
+	}
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{legacyStmt{driver: c.driver}}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.driver.call(); err != nil {
+		return nil, err
+	}
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.driver.call(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.driver.call(); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	return c.driver.call()
+}
+
+func (c *fakeConn) ResetSession(ctx context.Context) error {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+	c.driver.resets++
+	return nil
+}
+
+func (c *fakeConn) IsValid() bool {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+	return !c.driver.invalid
+}
+
+// CheckNamedValue accepts cents, which database/sql cannot convert on its own
+func (c *fakeConn) CheckNamedValue(value *driver.NamedValue) error {
+	if amount, ok := value.Value.(cents); ok {
+		value.Value = int64(amount.value)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+type cents struct {
+	value int
+}
+
+// legacyConn only implements driver.Conn
+type legacyConn struct {
+	driver *fakeDriver
+}
+
+func (c *legacyConn) Prepare(query string) (driver.Stmt, error) {
+	return &legacyStmt{driver: c.driver}, nil
+}
+
+func (c *legacyConn) Close() error { return nil }
+
+func (c *legacyConn) Begin() (driver.Tx, error) {
+	if err := c.driver.call(); err != nil {
+		return nil, err
+	}
+	return fakeTx{}, nil
+}
+
+// legacyStmt only implements driver.Stmt
+type legacyStmt struct {
+	driver *fakeDriver
+}
+
+func (s *legacyStmt) Close() error  { return nil }
+func (s *legacyStmt) NumInput() int { return -1 }
+
+func (s *legacyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.driver.call(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *legacyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.driver.call(); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+// fakeStmt also implements the context interfaces of statements
+type fakeStmt struct {
+	legacyStmt
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Exec(nil)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.Query(nil)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string              { return []string{"id"} }
+func (r *fakeRows) Close() error                   { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+type netErr struct{}
+
+func (netErr) Error() string   { return "connection refused" }
+func (netErr) Timeout() bool   { return false }
+func (netErr) Temporary() bool { return false }
+
+var driverCount int
+
+func openDB(t *testing.T, opts ...circuitbreaker.SettingsOption) (*sql.DB, *fakeDriver, *circuitbreaker.Breaker) {
+	t.Helper()
+
+	opts = append([]circuitbreaker.SettingsOption{
+		circuitbreaker.WithGauge(gauges.NewFixedWindowGauge(4)),
+		circuitbreaker.WithMinRequest(2),
+		circuitbreaker.WithFailureRate(50),
+	}, opts...)
+
+	breaker, err := sqlbreaker.NewBreaker("db", opts...)
+	if err != nil {
+		t.Fatalf("sqlbreaker.NewBreaker, expected no err, got %s", err)
+	}
+
+	driverCount++
+	name := fmt.Sprintf("fake-breaker-%d", driverCount)
+	fake := &fakeDriver{}
+	sqlbreaker.Register(name, fake, breaker)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open(%s), expected no err, got %s", name, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake, breaker
+}
+
+func TestIsSuccessful(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, true},
+		{sql.ErrNoRows, true},
+		{fmt.Errorf("scan: %w", sql.ErrNoRows), true},
+		{errors.New("duplicate key"), true},
+		{driver.ErrBadConn, false},
+		{io.ErrUnexpectedEOF, false},
+		{context.DeadlineExceeded, false},
+		{fmt.Errorf("dial: %w", netErr{}), false},
+	}
+
+	for _, c := range cases {
+		if actual := sqlbreaker.IsSuccessful(nil, c.err); actual != c.expected {
+			t.Errorf("sqlbreaker.IsSuccessful(%v), expected %t, got %t", c.err, c.expected, actual)
+		}
+	}
+}
+
+func TestGuardedCalls(t *testing.T) {
+	db, fake, _ := openDB(t)
+	ctx := context.Background()
+
+	if err := db.PingContext(ctx); err != nil {
+		t.Errorf("db.Ping, expected no err, got %s", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "INSERT"); err != nil {
+		t.Errorf("db.Exec, expected no err, got %s", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT")
+	if err != nil {
+		t.Fatalf("db.Query, expected no err, got %s", err)
+	}
+	rows.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("db.Begin, expected no err, got %s", err)
+	}
+	tx.Rollback()
+
+	if fake.calls != 4 {
+		t.Errorf("fake driver calls, expected 4, got %d", fake.calls)
+	}
+}
+
+func TestOpensOnConnectionErrors(t *testing.T) {
+	db, fake, breaker := openDB(t)
+	ctx := context.Background()
+
+	fake.setErr(netErr{})
+	for i := 0; i < 2; i++ {
+		if _, err := db.ExecContext(ctx, "INSERT"); err == nil {
+			t.Errorf("db.Exec, expected err, got nil")
+		}
+	}
+
+	fake.calls = 0
+	_, err := db.ExecContext(ctx, "INSERT")
+	expectedErr := circuitbreaker.ErrRequestNotPermitted{Name: breaker.Settings.Name, State: circuitbreaker.Open}
+
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("db.Exec, expected %s, got %v", expectedErr, err)
+	}
+
+	if fake.calls != 0 {
+		t.Errorf("fake driver calls, expected 0, got %d", fake.calls)
+	}
+}
+
+func TestIgnoresQueryErrors(t *testing.T) {
+	db, fake, _ := openDB(t)
+	ctx := context.Background()
+
+	queryErr := errors.New("syntax error")
+	fake.setErr(queryErr)
+	for i := 0; i < 4; i++ {
+		if _, err := db.ExecContext(ctx, "INSERT"); !errors.Is(err, queryErr) {
+			t.Errorf("db.Exec, expected %s, got %v", queryErr, err)
+		}
+	}
+
+	fake.setErr(nil)
+	if err := db.PingContext(ctx); err != nil {
+		t.Errorf("db.Ping, expected no err, got %s", err)
+	}
+}
+
+func TestForwardedInterfaces(t *testing.T) {
+	db, fake, _ := openDB(t)
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, "INSERT", cents{value: 250}); err != nil {
+		t.Errorf("db.Exec with an argument checked by the driver, expected no err, got %s", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT"); err != nil {
+		t.Errorf("db.Exec, expected no err, got %s", err)
+	}
+	if fake.opens != 1 || fake.resets != 1 {
+		t.Errorf("connections, expected 1 opened and reset once, got %d opened and %d resets", fake.opens, fake.resets)
+	}
+
+	// a connection the driver reports invalid is not reused
+	fake.mutex.Lock()
+	fake.invalid = true
+	fake.mutex.Unlock()
+	db.ExecContext(ctx, "INSERT")
+	db.ExecContext(ctx, "INSERT")
+	if fake.opens != 2 {
+		t.Errorf("connections opened, expected a new one after the invalid one, got %d", fake.opens)
+	}
+}
+
+func TestLegacyBeginOptions(t *testing.T) {
+	db, fake, _ := openDB(t)
+	fake.legacy = true
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("db.BeginTx, expected no err, got %s", err)
+	}
+	tx.Rollback()
+
+	testCases := map[string]struct {
+		opts     *sql.TxOptions
+		expected sqlbreaker.ErrTxOptionNotSupported
+	}{
+		"Isolation": {
+			opts:     &sql.TxOptions{Isolation: sql.LevelSerializable},
+			expected: sqlbreaker.ErrTxOptionNotSupported{Option: "non-default isolation level"},
+		},
+		"ReadOnly": {
+			opts:     &sql.TxOptions{ReadOnly: true},
+			expected: sqlbreaker.ErrTxOptionNotSupported{Option: "read-only"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := db.BeginTx(ctx, tc.opts)
+			if err != tc.expected {
+				t.Errorf("db.BeginTx, expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+	if fake.calls != 1 {
+		t.Errorf("fake driver calls, expected only the default transaction, got %d", fake.calls)
+	}
+}
+
+// expectTrip fails calls with a connection error until the circuit opens, then expects the next call to be
+// rejected without reaching the driver
+func expectTrip(t *testing.T, fake *fakeDriver, breaker *circuitbreaker.Breaker, call func() error) {
+	t.Helper()
+
+	fake.setErr(netErr{})
+	for i := 0; i < 3; i++ {
+		if err := call(); err == nil {
+			t.Errorf("call, expected err, got nil")
+		}
+	}
+
+	fake.calls = 0
+	err := call()
+	expectedErr := circuitbreaker.ErrRequestNotPermitted{Name: breaker.Settings.Name, State: circuitbreaker.Open}
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("call, expected %s, got %v", expectedErr, err)
+	}
+	if fake.calls != 0 {
+		t.Errorf("fake driver calls, expected 0, got %d", fake.calls)
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	db, fake, breaker := openDB(t)
+	ctx := context.Background()
+
+	stmt, err := db.PrepareContext(ctx, "INSERT")
+	if err != nil {
+		t.Fatalf("db.Prepare, expected no err, got %s", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, cents{value: 250}); err != nil {
+		t.Errorf("stmt.Exec with an argument checked by the connection, expected no err, got %s", err)
+	}
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		t.Fatalf("stmt.Query, expected no err, got %s", err)
+	}
+	rows.Close()
+	if fake.calls != 2 {
+		t.Errorf("fake driver calls, expected 2, got %d", fake.calls)
+	}
+
+	expectTrip(t, fake, breaker, func() error {
+		_, err := stmt.ExecContext(ctx)
+		return err
+	})
+}
+
+func TestLegacyStatements(t *testing.T) {
+	db, fake, breaker := openDB(t)
+	fake.legacy = true
+	ctx := context.Background()
+
+	// the connection can only run queries by preparing them
+	if _, err := db.ExecContext(ctx, "INSERT"); err != nil {
+		t.Errorf("db.Exec, expected no err, got %s", err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT")
+	if err != nil {
+		t.Fatalf("db.Query, expected no err, got %s", err)
+	}
+	rows.Close()
+	if fake.calls != 2 {
+		t.Errorf("fake driver calls, expected 2, got %d", fake.calls)
+	}
+
+	expectTrip(t, fake, breaker, func() error {
+		_, err := db.ExecContext(ctx, "INSERT")
+		return err
+	})
+}
@@ -0,0 +1,23 @@
+package sqlbreaker
+
+import "fmt"
+
+// ErrNamedArgsNotSupported gets returned when named arguments are passed to a driver that only implements the
+// legacy Execer or Queryer interfaces
+type ErrNamedArgsNotSupported struct {
+	Name string
+}
+
+func (e ErrNamedArgsNotSupported) Error() string {
+	return fmt.Sprintf("sqlbreaker: driver does not support named argument %s", e.Name)
+}
+
+// ErrTxOptionNotSupported gets returned when a transaction with options is started on a driver that only
+// implements the legacy Begin, as database/sql does
+type ErrTxOptionNotSupported struct {
+	Option string
+}
+
+func (e ErrTxOptionNotSupported) Error() string {
+	return fmt.Sprintf("sqlbreaker: driver does not support %s transactions", e.Option)
+}
@@ -0,0 +1,95 @@
+package sqlbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+)
+
+// wrappedStmt guards the execution of a prepared statement, the same way wrappedConn guards the queries it runs
+// directly. It is also what database/sql ends up using when the parent connection cannot run queries without
+// preparing them first.
+type wrappedStmt struct {
+	parent  driver.Stmt
+	conn    *wrappedConn
+	breaker *circuitbreaker.Breaker
+}
+
+var (
+	_ driver.Stmt              = &wrappedStmt{}
+	_ driver.StmtExecContext   = &wrappedStmt{}
+	_ driver.StmtQueryContext  = &wrappedStmt{}
+	_ driver.NamedValueChecker = &wrappedStmt{}
+)
+
+func (s *wrappedStmt) Close() error {
+	return s.parent.Close()
+}
+
+func (s *wrappedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+// Exec is deprecated in database/sql/driver but still part of the driver.Stmt interface
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+// Query is deprecated in database/sql/driver but still part of the driver.Stmt interface
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := execute(ctx, s.breaker, func(ctx context.Context) (err error) {
+		if execer, ok := s.parent.(driver.StmtExecContext); ok {
+			result, err = execer.ExecContext(ctx, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		result, err = s.parent.Exec(values)
+		return err
+	})
+	return result, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := execute(ctx, s.breaker, func(ctx context.Context) (err error) {
+		if queryer, ok := s.parent.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+			return err
+		}
+
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = s.parent.Query(values)
+		return err
+	})
+	return rows, err
+}
+
+// CheckNamedValue falls back to the checker of the connection, since database/sql only asks the statement once it
+// has one
+func (s *wrappedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return s.conn.CheckNamedValue(value)
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
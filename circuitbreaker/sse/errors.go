@@ -0,0 +1,12 @@
+package sse
+
+import "fmt"
+
+// ErrInvalidHistorySize gets returned when the history is given a negative size
+type ErrInvalidHistorySize struct {
+	Size int
+}
+
+func (e ErrInvalidHistorySize) Error() string {
+	return fmt.Sprintf("history size must not be negative, got %d", e.Size)
+}
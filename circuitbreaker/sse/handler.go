@@ -0,0 +1,258 @@
+// Package sse streams the activity of a set of breakers to dashboards over Server-Sent Events.
+//
+// State related events (transitions, forced states, resets and pins) are sent with an increasing id and kept
+// in a bounded history, so a client reconnecting with the Last-Event-ID header receives the events it missed.
+// In between, a snapshot event with the status of every watched breaker is sent periodically. Clients can watch
+// a subset of the breakers by repeating the name query parameter.
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+// ContentType of the stream
+const ContentType = "text/event-stream"
+
+// SnapshotEvent is the SSE event name of periodic snapshots, their data is a list of admin.Breaker
+const SnapshotEvent = "snapshot"
+
+const (
+	// DefaultSnapshotInterval is how often snapshots are sent
+	DefaultSnapshotInterval = 5 * time.Second
+	// DefaultHistorySize is the number of events kept for clients reconnecting with Last-Event-ID
+	DefaultHistorySize = 256
+	// DefaultClientBufferSize is the number of events buffered per client before it is disconnected
+	DefaultClientBufferSize = 64
+)
+
+// Option customises the handler
+type Option func(*Handler)
+
+// WithSnapshotInterval sets how often snapshots are sent, a non positive interval disables them
+func WithSnapshotInterval(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.snapshotInterval = interval
+	}
+}
+
+// WithHistorySize sets how many events are kept to be replayed on reconnection, 0 disables the replay. NewHandler
+// returns ErrInvalidHistorySize when it is negative.
+func WithHistorySize(size int) Option {
+	return func(h *Handler) {
+		h.historySize = size
+	}
+}
+
+// WithRetry sets the reconnection delay advertised to clients, the browser default is used otherwise
+func WithRetry(retry time.Duration) Option {
+	return func(h *Handler) {
+		h.retry = retry
+	}
+}
+
+// entry is an event of the history along with its SSE id
+type entry struct {
+	id    uint64
+	event admin.Event
+}
+
+// client is a connected stream, it is disconnected when it falls behind rather than silently missing events,
+// since it can catch up by reconnecting with Last-Event-ID
+type client struct {
+	names    map[string]bool
+	entries  chan entry
+	overflow chan struct{}
+	once     sync.Once
+}
+
+func (c *client) watches(name string) bool {
+	return len(c.names) == 0 || c.names[name]
+}
+
+func (c *client) send(e entry) {
+	select {
+	case c.entries <- e:
+	default:
+		c.once.Do(func() { close(c.overflow) })
+	}
+}
+
+// Handler is an http.Handler serving the event stream. It subscribes to the breakers when created, Close
+// releases the subscriptions.
+type Handler struct {
+	breakers         []*circuitbreaker.Breaker
+	subscriptions    []*circuitbreaker.Subscription
+	snapshotInterval time.Duration
+	historySize      int
+	retry            time.Duration
+
+	mutex   sync.Mutex
+	lastID  uint64
+	history []entry
+	clients map[*client]struct{}
+}
+
+var _ http.Handler = &Handler{}
+
+// NewHandler starts recording the state events of the breakers
+func NewHandler(breakers []*circuitbreaker.Breaker, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		breakers:         breakers,
+		snapshotInterval: DefaultSnapshotInterval,
+		historySize:      DefaultHistorySize,
+		clients:          map[*client]struct{}{},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.historySize < 0 {
+		return nil, ErrInvalidHistorySize{Size: h.historySize}
+	}
+
+	for _, breaker := range breakers {
+		sub := breaker.Subscribe(circuitbreaker.DefaultEventBufferSize, circuitbreaker.StateChangeTypes()...)
+		h.subscriptions = append(h.subscriptions, sub)
+		go h.record(sub)
+	}
+	return h, nil
+}
+
+// Close stops recording events, connected clients keep receiving snapshots until they disconnect
+func (h *Handler) Close() {
+	for _, sub := range h.subscriptions {
+		sub.Close()
+	}
+}
+
+func (h *Handler) record(sub *circuitbreaker.Subscription) {
+	for event := range sub.Events() {
+		h.publish(admin.NewEvent(event))
+	}
+}
+
+func (h *Handler) publish(event admin.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastID++
+	e := entry{id: h.lastID, event: event}
+	h.history = append(h.history, e)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for c := range h.clients {
+		if c.watches(event.Breaker) {
+			c.send(e)
+		}
+	}
+}
+
+// connect registers the client and returns the events it missed since lastID, under the same lock so no event
+// falls in between
+func (h *Handler) connect(c *client, lastID uint64, replay bool) []entry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var missed []entry
+	if replay {
+		for _, e := range h.history {
+			if e.id > lastID && c.watches(e.event.Breaker) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	h.clients[c] = struct{}{}
+	return missed
+}
+
+func (h *Handler) disconnect(c *client) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.clients, c)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, admin.ErrStreamingUnsupported.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c := &client{
+		names:    map[string]bool{},
+		entries:  make(chan entry, DefaultClientBufferSize),
+		overflow: make(chan struct{}),
+	}
+	for _, name := range r.URL.Query()["name"] {
+		c.names[name] = true
+	}
+
+	lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	missed := h.connect(c, lastID, err == nil)
+	defer h.disconnect(c)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if h.retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", h.retry.Milliseconds())
+	}
+
+	for _, e := range missed {
+		writeEntry(w, e)
+	}
+	h.writeSnapshot(w, c)
+	flusher.Flush()
+
+	var ticks <-chan time.Time
+	if h.snapshotInterval > 0 {
+		ticker := time.NewTicker(h.snapshotInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case e := <-c.entries:
+			writeEntry(w, e)
+		case <-ticks:
+			h.writeSnapshot(w, c)
+		case <-c.overflow:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSnapshot sends the current status of the watched breakers, without an id so that it does not move the
+// client's Last-Event-ID
+func (h *Handler) writeSnapshot(w http.ResponseWriter, c *client) {
+	snapshot := []admin.Breaker{}
+	for _, breaker := range h.breakers {
+		if c.watches(breaker.Name()) {
+			snapshot = append(snapshot, admin.NewBreaker(breaker))
+		}
+	}
+
+	data, _ := json.Marshal(snapshot)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", SnapshotEvent, data)
+}
+
+func writeEntry(w http.ResponseWriter, e entry) {
+	data, _ := json.Marshal(e.event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.event.Type, data)
+}
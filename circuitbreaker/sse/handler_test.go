@@ -0,0 +1,188 @@
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/sse"
+)
+
+type frame struct {
+	id    string
+	event string
+	data  string
+}
+
+// stream reads the frames sent by the server on a channel until the connection is closed
+func stream(t *testing.T, ctx context.Context, url, lastEventID string) <-chan frame {
+	t.Helper()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s, expected no err, got %s", url, err)
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != sse.ContentType {
+		t.Errorf("Content-Type, expected %s, got %s", sse.ContentType, contentType)
+	}
+
+	frames := make(chan frame, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(frames)
+
+		scanner := bufio.NewScanner(resp.Body)
+		var f frame
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if f.event != "" {
+					frames <- f
+				}
+				f = frame{}
+			case strings.HasPrefix(line, "id: "):
+				f.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				f.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				f.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return frames
+}
+
+func next(t *testing.T, frames <-chan frame) frame {
+	t.Helper()
+
+	select {
+	case f, ok := <-frames:
+		if !ok {
+			t.Fatalf("stream closed, expected a frame")
+		}
+		return f
+	case <-time.After(time.Second):
+		t.Fatalf("stream, expected a frame within a second")
+	}
+	return frame{}
+}
+
+func newServer(t *testing.T, opts ...sse.Option) (*httptest.Server, *circuitbreaker.Breaker, *circuitbreaker.Breaker) {
+	t.Helper()
+
+	payments, _ := circuitbreaker.NewBreaker("payments")
+	inventory, _ := circuitbreaker.NewBreaker("inventory")
+	handler, err := sse.NewHandler([]*circuitbreaker.Breaker{payments, inventory}, opts...)
+	if err != nil {
+		t.Fatalf("sse.NewHandler, expected no err, got %s", err)
+	}
+	t.Cleanup(handler.Close)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, payments, inventory
+}
+
+// waitForHistory gives the handler time to record events published by the breakers
+func waitForHistory() {
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestStream(t *testing.T) {
+	server, payments, inventory := newServer(t, sse.WithSnapshotInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := stream(t, ctx, server.URL+"?name=payments", "")
+
+	snapshot := next(t, frames)
+	var breakers []admin.Breaker
+	json.Unmarshal([]byte(snapshot.data), &breakers)
+	if snapshot.event != sse.SnapshotEvent || snapshot.id != "" || len(breakers) != 1 || breakers[0].Name != "payments" {
+		t.Errorf("first frame, expected snapshot of payments without id, got %+v", snapshot)
+	}
+
+	inventory.ForceState(circuitbreaker.Open)
+	payments.ForceState(circuitbreaker.Open)
+
+	forced := next(t, frames)
+	transition := next(t, frames)
+
+	var event admin.Event
+	json.Unmarshal([]byte(transition.data), &event)
+	if forced.event != circuitbreaker.Forced.String() || transition.event != circuitbreaker.StateTransition.String() {
+		t.Errorf("frames, expected forced then transition, got %s then %s", forced.event, transition.event)
+	}
+	if event.Breaker != "payments" || event.From != "closed" || event.State != "open" {
+		t.Errorf("transition data, expected payments closed to open, got %+v", event)
+	}
+	if forced.id == "" || transition.id == "" || forced.id == transition.id {
+		t.Errorf("frame ids, expected distinct ids, got %q and %q", forced.id, transition.id)
+	}
+}
+
+func TestReconnectWithLastEventID(t *testing.T) {
+	server, payments, _ := newServer(t, sse.WithSnapshotInterval(time.Hour), sse.WithRetry(time.Second))
+
+	payments.ForceState(circuitbreaker.Open)
+	payments.Reset()
+	waitForHistory()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// events 1 and 2 are the forced state and its transition, the client saw them before disconnecting
+	frames := stream(t, ctx, server.URL, "2")
+
+	reset := next(t, frames)
+	transition := next(t, frames)
+	if reset.id != "3" || reset.event != circuitbreaker.Reset.String() {
+		t.Errorf("first replayed frame, expected reset with id 3, got %+v", reset)
+	}
+	if transition.id != "4" || transition.event != circuitbreaker.StateTransition.String() {
+		t.Errorf("second replayed frame, expected transition with id 4, got %+v", transition)
+	}
+
+	if snapshot := next(t, frames); snapshot.event != sse.SnapshotEvent {
+		t.Errorf("frame after replay, expected snapshot, got %+v", snapshot)
+	}
+}
+
+func TestPeriodicSnapshots(t *testing.T) {
+	server, _, _ := newServer(t, sse.WithSnapshotInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := stream(t, ctx, server.URL, "")
+	for i := 0; i < 3; i++ {
+		if f := next(t, frames); f.event != sse.SnapshotEvent {
+			t.Errorf("frame %d, expected snapshot, got %+v", i, f)
+		}
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	_, err := sse.NewHandler(nil, sse.WithHistorySize(-1))
+	if err != (sse.ErrInvalidHistorySize{Size: -1}) {
+		t.Errorf("sse.NewHandler with history size -1, expected ErrInvalidHistorySize, got %v", err)
+	}
+
+	// without history the events are still streamed, just not replayed
+	_, payments, _ := newServer(t, sse.WithHistorySize(0))
+	payments.ForceState(circuitbreaker.Open)
+	waitForHistory()
+}
@@ -14,6 +14,16 @@ const (
 	HalfOpen
 )
 
+// ParseState returns the state named by s, as returned by State.String
+func ParseState(s string) (State, error) {
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		if state.String() == s {
+			return state, nil
+		}
+	}
+	return Closed, ErrUnknownState{Val: s}
+}
+
 func (s State) String() string {
 	switch s {
 	case Closed:
@@ -27,21 +37,32 @@ func (s State) String() string {
 	}
 }
 
+// stateMachine is not safe for concurrent use, the breaker serializes access to it. When the cooldown
+// expires onCooldown is called from the timer goroutine, and it is up to the owner to take its lock and call
+// CooldownExpired.
 type stateMachine struct {
-	state         State
-	gauge         gauges.Gauge
-	requestCount  int
-	thresholds    Thresholds
-	timer         *time.Timer
-	onStateChange func(from, to State)
+	state        State
+	gauge        gauges.Gauge
+	requestCount int
+	thresholds   Thresholds
+	timer        *time.Timer
+	openUntil    time.Time
+	pinnedUntil  time.Time
+	onCooldown   func()
+	// throttled disables the transitions driven by outcomes, the breaker throttles calls instead
+	throttled bool
+	// probes is the number of calls admitted while half-open whose outcome is not reported yet, halfOpens
+	// numbers the half-open periods so that a probe of an earlier period does not release a slot of the current one
+	probes    int
+	halfOpens int
 }
 
-func NewStateMachine(gauge gauges.Gauge, thresholds Thresholds, onStateChange func(from, to State)) *stateMachine {
+func NewStateMachine(gauge gauges.Gauge, thresholds Thresholds, onCooldown func()) *stateMachine {
 	return &stateMachine{
-		gauge:         gauge,
-		state:         Closed,
-		thresholds:    thresholds,
-		onStateChange: onStateChange,
+		gauge:      gauge,
+		state:      Closed,
+		thresholds: thresholds,
+		onCooldown: onCooldown,
 	}
 }
 
@@ -59,7 +80,10 @@ func (sm *stateMachine) ReportOutcome(outcome gauges.Outcome) (State, error) {
 }
 
 func (sm *stateMachine) Reset() {
+	sm.stopTimer()
+	sm.Unpin()
 	sm.state = Closed
+	sm.requestCount = 0
 	sm.gauge.Reset()
 }
 
@@ -74,10 +98,81 @@ func (sm *stateMachine) TransitionState(target State) {
 	}
 }
 
+// Pin moves the state machine to the target state and keeps it there until the given time, outcomes are still
+// logged in the gauge but do not cause transitions
+func (sm *stateMachine) Pin(target State, until time.Time) {
+	sm.TransitionState(target)
+	sm.pinnedUntil = until
+}
+
+// Unpin lets the state machine transition again
+func (sm *stateMachine) Unpin() {
+	sm.pinnedUntil = time.Time{}
+}
+
+// IsPinned reports whether a pin is in effect at the given time
+func (sm *stateMachine) IsPinned(now time.Time) bool {
+	return now.Before(sm.pinnedUntil)
+}
+
+// CooldownExpired moves an open circuit to half-open, or closes it when throttling, it returns false when the
+// circuit is no longer open, is pinned, or was opened again after the timer that triggered the call was started
+func (sm *stateMachine) CooldownExpired(now time.Time) bool {
+	if sm.state != Open || now.Before(sm.openUntil) || sm.IsPinned(now) {
+		return false
+	}
+
+	if sm.throttled {
+		sm.transitionToClosed()
+	} else {
+		sm.transitionToHalfOpen()
+	}
+	return true
+}
+
+// Restore puts the state machine back in a state saved earlier, an open circuit keeps its original cooldown
+// and moves to half-open right away when it already elapsed
+func (sm *stateMachine) Restore(state State, openUntil time.Time, now time.Time) {
+	switch {
+	case state == Open && now.Before(openUntil):
+		sm.stopTimer()
+		sm.openUntil = openUntil
+		sm.timer = time.AfterFunc(openUntil.Sub(now), sm.onCooldown)
+		sm.state = Open
+		sm.requestCount = 0
+	case state == Open || state == HalfOpen:
+		sm.transitionToHalfOpen()
+	default:
+		sm.transitionToClosed()
+	}
+}
+
+// Reconfigure replaces the gauge and the thresholds and checks them against the current readings, the cooldown
+// of an open circuit keeps its original end
+func (sm *stateMachine) Reconfigure(gauge gauges.Gauge, thresholds Thresholds) {
+	sm.gauge = gauge
+	sm.thresholds = thresholds
+	sm.updateState()
+}
+
+// Throttle turns the transitions driven by outcomes off or back on, turning them off closes the circuit unless
+// it is pinned
+func (sm *stateMachine) Throttle(enabled bool) {
+	sm.throttled = enabled
+	if enabled && sm.state != Closed && !sm.IsPinned(time.Now()) {
+		sm.transitionToClosed()
+	}
+}
+
 func (sm *stateMachine) State() State {
 	return sm.state
 }
 
+// OpenUntil returns when the current cooldown ends, it is only meaningful while the circuit is open
+func (sm *stateMachine) OpenUntil() time.Time {
+	return sm.openUntil
+}
+
 func (sm *stateMachine) ShouldMakeRequests() bool {
 	switch sm.state {
 	case Closed:
@@ -91,14 +186,39 @@ func (sm *stateMachine) ShouldMakeRequests() bool {
 	}
 }
 
+// AdmitProbe takes a probe slot while the circuit is half-open, at most MaxRequestOnHalfOpen calls are probing
+// at once. It returns false when every slot is taken, and otherwise the slot to hand to ReleaseProbe, 0 when
+// the circuit is not half-open.
+func (sm *stateMachine) AdmitProbe() (int, bool) {
+	if sm.state != HalfOpen {
+		return 0, true
+	}
+	if sm.probes >= sm.thresholds.MaxRequestOnHalfOpen {
+		return 0, false
+	}
+	sm.probes++
+	return sm.halfOpens, true
+}
+
+// ReleaseProbe gives back a slot taken by AdmitProbe, a slot of an earlier half-open period is ignored
+func (sm *stateMachine) ReleaseProbe(probe int) {
+	if probe != 0 && probe == sm.halfOpens && sm.probes > 0 {
+		sm.probes--
+	}
+}
+
 func (sm *stateMachine) RequestCount() int {
 	return sm.requestCount
 }
 
 func (sm *stateMachine) updateState() {
+	if sm.throttled || sm.IsPinned(time.Now()) {
+		return
+	}
+
 	metrics := sm.gauge.OverallAggregate()
 
-	if metrics.RequestCount >= sm.thresholds.MinRequests && sm.state == Closed && metrics.FailureRate() > sm.thresholds.FailureRate {
+	if sm.state == Closed && sm.thresholds.Exceeded(metrics) {
 		sm.TransitionState(Open)
 	} else if sm.state == HalfOpen && sm.requestCount > sm.thresholds.MaxRequestOnHalfOpen {
 		if metrics.SuccessRate() >= sm.thresholds.RecoveryRate {
@@ -110,21 +230,31 @@ func (sm *stateMachine) updateState() {
 }
 
 func (sm *stateMachine) transitionToOpen() {
-	sm.timer = time.AfterFunc(sm.thresholds.CooldownDuration, sm.watchCooldown)
+	sm.stopTimer()
+	sm.openUntil = time.Now().Add(sm.thresholds.CooldownDuration)
+	sm.timer = time.AfterFunc(sm.thresholds.CooldownDuration, sm.onCooldown)
 	sm.state = Open
+	sm.requestCount = 0
 }
 
 func (sm *stateMachine) transitionToHalfOpen() {
+	sm.stopTimer()
 	sm.state = HalfOpen
+	sm.requestCount = 0
+	sm.probes = 0
+	sm.halfOpens++
 	sm.gauge.Reset()
-	sm.onStateChange(Open, HalfOpen)
 }
 
 func (sm *stateMachine) transitionToClosed() {
+	sm.stopTimer()
 	sm.state = Closed
 	sm.requestCount = 0
 }
 
-func (sm *stateMachine) watchCooldown() {
-	sm.transitionToHalfOpen()
+func (sm *stateMachine) stopTimer() {
+	if sm.timer != nil {
+		sm.timer.Stop()
+		sm.timer = nil
+	}
 }
@@ -0,0 +1,162 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// Snapshot is the state of a breaker as saved in a StateStore
+type Snapshot struct {
+	State State
+	// OpenUntil is when the cooldown ends, only set when the state is Open
+	OpenUntil time.Time
+	// Gauge holds the readings logged in the gauge, nil when it does not implement gauges.Snapshotter. The remote
+	// readings of a gauges.UnionGauge are left out. It is only restored when the gauge implements gauges.Restorer.
+	Gauge   *gauges.Snapshot
+	SavedAt time.Time
+}
+
+// StateStore persists the state of breakers so that it survives process restarts. A breaker with a store
+// restores its state when created and saves it on every state change, in the background, pins are not persisted.
+type StateStore interface {
+	// Load returns the snapshot saved for the named breaker, ok is false when there is none
+	Load(name string) (snapshot Snapshot, ok bool, err error)
+	// Save replaces the snapshot of the named breaker
+	Save(name string, snapshot Snapshot) error
+}
+
+// Snapshot captures the current state of the breaker
+func (b *Breaker) Snapshot() Snapshot {
+	snapshot, _ := b.snapshot()
+	return snapshot
+}
+
+// snapshot also returns the sequence of the snapshot, the state only changes with the write lock held so
+// snapshots taken under the read lock are numbered in the order of the states they capture
+func (b *Breaker) snapshot() (Snapshot, uint64) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	snapshot := Snapshot{State: b.stateMachine.State(), SavedAt: time.Now()}
+	if snapshot.State == Open {
+		snapshot.OpenUntil = b.stateMachine.OpenUntil()
+	}
+	if gauge, ok := gauges.LocalSnapshot(b.Settings.Gauge); ok {
+		snapshot.Gauge = &gauge
+	}
+	return snapshot, b.persister.sequence.Add(1)
+}
+
+// Save writes the current state to the StateStore of the settings, for instance on shutdown to keep the latest
+// gauge readings. It does nothing when there is no store. Unlike the saves made on state changes it returns
+// once the store saved the state, a state change still waiting to be saved is dropped since this state is newer.
+func (b *Breaker) Save() error {
+	settings := b.CurrentSettings()
+	if settings.StateStore == nil {
+		return nil
+	}
+
+	snapshot, sequence := b.snapshot()
+	return b.persister.save(settings.StateStore, settings.Name, snapshot, sequence)
+}
+
+// persist saves the state after a change without waiting for the store, failures are logged since the breaker
+// works without its store
+func (b *Breaker) persist() {
+	if b.CurrentSettings().StateStore == nil {
+		return
+	}
+
+	snapshot, sequence := b.snapshot()
+	p := &b.persister
+	p.mutex.Lock()
+	p.pending = &pendingSnapshot{snapshot: snapshot, sequence: sequence}
+	if p.writing {
+		p.mutex.Unlock()
+		return
+	}
+	p.writing = true
+	p.mutex.Unlock()
+
+	go b.write()
+}
+
+// write saves the pending snapshots until there is none left
+func (b *Breaker) write() {
+	p := &b.persister
+	for {
+		p.mutex.Lock()
+		pending := p.pending
+		p.pending = nil
+		if pending == nil {
+			p.writing = false
+			p.mutex.Unlock()
+			return
+		}
+		p.mutex.Unlock()
+
+		settings := b.CurrentSettings()
+		if err := p.save(settings.StateStore, settings.Name, pending.snapshot, pending.sequence); err != nil {
+			b.logStoreError("could not save circuit breaker state", err)
+		}
+	}
+}
+
+// persister saves the snapshots of a breaker on a single goroutine, started when there is something to save. A
+// snapshot taken while another one is being saved replaces the one waiting if any, so that a slow store never
+// holds up the calls changing the state and only gets the latest of them.
+type persister struct {
+	mutex   sync.Mutex
+	pending *pendingSnapshot
+	writing bool
+
+	sequence atomic.Uint64
+	// saveMutex orders the saves, saved is the sequence of the latest snapshot saved so that an older one never
+	// overwrites it
+	saveMutex sync.Mutex
+	saved     uint64
+}
+
+type pendingSnapshot struct {
+	snapshot Snapshot
+	sequence uint64
+}
+
+func (p *persister) save(store StateStore, name string, snapshot Snapshot, sequence uint64) error {
+	p.saveMutex.Lock()
+	defer p.saveMutex.Unlock()
+
+	if sequence <= p.saved {
+		return nil
+	}
+	if err := store.Save(name, snapshot); err != nil {
+		return err
+	}
+	p.saved = sequence
+	return nil
+}
+
+// restore must be called before the breaker is shared. A snapshot that cannot be loaded is logged and the
+// breaker starts closed, an open circuit whose cooldown elapsed while the process was down starts half-open.
+func (b *Breaker) restore() {
+	snapshot, ok, err := b.Settings.StateStore.Load(b.Settings.Name)
+	if err != nil {
+		b.logStoreError("could not load circuit breaker state", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if restorer, ok := b.Settings.Gauge.(gauges.Restorer); ok && snapshot.Gauge != nil {
+		if err := restorer.Restore(*snapshot.Gauge); err != nil {
+			b.logStoreError("could not restore circuit breaker gauge", err)
+		}
+	}
+
+	b.stateMachine.Restore(snapshot.State, snapshot.OpenUntil, time.Now())
+	b.logRestore(snapshot)
+}
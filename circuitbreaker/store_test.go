@@ -0,0 +1,206 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+type memoryStore struct {
+	mutex     sync.Mutex
+	snapshots map[string]circuitbreaker.Snapshot
+	loadErr   error
+	saves     int
+	// release holds up saves until it is closed when set
+	release chan struct{}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{snapshots: map[string]circuitbreaker.Snapshot{}}
+}
+
+func (s *memoryStore) Load(name string) (circuitbreaker.Snapshot, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.loadErr != nil {
+		return circuitbreaker.Snapshot{}, false, s.loadErr
+	}
+	snapshot, ok := s.snapshots[name]
+	return snapshot, ok, nil
+}
+
+func (s *memoryStore) Save(name string, snapshot circuitbreaker.Snapshot) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.saves++
+	s.snapshots[name] = snapshot
+	return nil
+}
+
+// waitSaves waits for the snapshots saved in the background
+func (s *memoryStore) waitSaves(t *testing.T, saves int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mutex.Lock()
+		got := s.saves
+		s.mutex.Unlock()
+		if got >= saves {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("store saves, expected %d, got %d", saves, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func failingHandler(ctx context.Context, name string) (*http.Response, error) {
+	return nil, errors.New("failed")
+}
+
+func succeedingHandler(ctx context.Context, name string) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func newStoredBreaker(t *testing.T, store circuitbreaker.StateStore) *circuitbreaker.Breaker {
+	t.Helper()
+
+	settings, err := circuitbreaker.NewSettings("payments",
+		circuitbreaker.WithStateStore(store),
+		circuitbreaker.WithGauge(gauges.NewFixedWindowGauge(10)),
+		circuitbreaker.WithCooldownDuration(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewSettings, expected no err, got %s", err)
+	}
+
+	cb, err := circuitbreaker.NewBreakerWithSettings(settings)
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewBreakerWithSettings, expected no err, got %s", err)
+	}
+	return cb
+}
+
+func TestStateStore(t *testing.T) {
+	t.Run("RestoresOpenCircuit", func(t *testing.T) {
+		store := newMemoryStore()
+		cb := newStoredBreaker(t, store)
+		cb.ForceState(circuitbreaker.Open)
+		openUntil, _ := cb.OpenUntil()
+		store.waitSaves(t, 1)
+
+		restored := newStoredBreaker(t, store)
+		if restored.State() != circuitbreaker.Open {
+			t.Errorf("restored state, expected %s, got %s", circuitbreaker.Open, restored.State())
+		}
+		if until, _ := restored.OpenUntil(); !until.Equal(openUntil) {
+			t.Errorf("restored OpenUntil, expected %s, got %s", openUntil, until)
+		}
+	})
+
+	t.Run("ElapsedCooldownRestoresHalfOpen", func(t *testing.T) {
+		store := newMemoryStore()
+		store.snapshots["payments"] = circuitbreaker.Snapshot{
+			State:     circuitbreaker.Open,
+			OpenUntil: time.Now().Add(-time.Second),
+		}
+
+		restored := newStoredBreaker(t, store)
+		if restored.State() != circuitbreaker.HalfOpen {
+			t.Errorf("restored state, expected %s, got %s", circuitbreaker.HalfOpen, restored.State())
+		}
+	})
+
+	t.Run("RestoresGauge", func(t *testing.T) {
+		store := newMemoryStore()
+		cb := newStoredBreaker(t, store)
+		cb.ExecuteContext(context.Background(), failingHandler)
+		cb.ExecuteContext(context.Background(), succeedingHandler)
+		if err := cb.Save(); err != nil {
+			t.Fatalf("Breaker.Save, expected no err, got %s", err)
+		}
+
+		restored := newStoredBreaker(t, store)
+		expected := gauges.Aggregate{RequestCount: 2, FailureCount: 1, SuccessCount: 1}
+		if restored.Aggregate() != expected {
+			t.Errorf("restored aggregate, expected %+v, got %+v", expected, restored.Aggregate())
+		}
+	})
+
+	t.Run("LoadErrorStartsClosed", func(t *testing.T) {
+		store := newMemoryStore()
+		store.loadErr = errors.New("corrupt")
+
+		restored := newStoredBreaker(t, store)
+		if restored.State() != circuitbreaker.Closed {
+			t.Errorf("state after load error, expected %s, got %s", circuitbreaker.Closed, restored.State())
+		}
+	})
+
+	t.Run("SavesOnReset", func(t *testing.T) {
+		store := newMemoryStore()
+		cb := newStoredBreaker(t, store)
+		cb.Reset()
+		store.waitSaves(t, 1)
+	})
+
+	t.Run("SlowStoreKeepsLatest", func(t *testing.T) {
+		store := newMemoryStore()
+		store.release = make(chan struct{})
+		cb := newStoredBreaker(t, store)
+
+		// the state changes do not wait for the store, the ones made while it is busy are saved once, as the latest
+		for _, state := range []circuitbreaker.State{circuitbreaker.Open, circuitbreaker.Closed, circuitbreaker.Open, circuitbreaker.HalfOpen} {
+			cb.ForceState(state)
+		}
+		close(store.release)
+
+		deadline := time.Now().Add(time.Second)
+		for snapshot, _, _ := store.Load("payments"); snapshot.State != circuitbreaker.HalfOpen; snapshot, _, _ = store.Load("payments") {
+			if time.Now().After(deadline) {
+				t.Fatalf("saved state, expected %s, got %s", circuitbreaker.HalfOpen, snapshot.State)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		if store.saves > 2 {
+			t.Errorf("saves, expected at most the one in progress and the latest, got %d", store.saves)
+		}
+	})
+}
+
+func TestSnapshotUnionGauge(t *testing.T) {
+	gauge := gauges.NewUnionGauge(gauges.NewFixedWindowGauge(10))
+	settings, _ := circuitbreaker.NewSettings("payments", circuitbreaker.WithGauge(gauge))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+
+	cb.ExecuteContext(context.Background(), failingHandler)
+	gauge.SetRemote("orders-2", gauges.Snapshot{Measurements: []gauges.Aggregate{{RequestCount: 3, SuccessCount: 3}}})
+
+	snapshot := cb.Snapshot()
+	if snapshot.Gauge == nil || snapshot.Gauge.Aggregate().RequestCount != 1 {
+		t.Fatalf("cb.Snapshot, expected only the local reading, got %+v", snapshot.Gauge)
+	}
+
+	if err := cb.UpdateSettings(circuitbreaker.WithGauge(gauges.NewFixedWindowGauge(20))); err != nil {
+		t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 1 || aggregate.FailureCount != 1 {
+		t.Errorf("new gauge, expected only the local failure, got %+v", aggregate)
+	}
+}
@@ -0,0 +1,67 @@
+package circuitbreaker
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+// DefaultThrottleK lets twice as many calls through as the dependency accepts, as recommended by the Google
+// SRE book
+const DefaultThrottleK float64 = 2.0
+
+// Throttle replaces the open and closed states with client side adaptive throttling, as described in the
+// Google SRE book: every call is rejected with a probability of max(0, (requests - K*accepts) / (requests + 1)),
+// requests and accepts being the calls and the successes held by the gauge. Calls rejected by the throttle
+// count as requests, so load is shed smoothly as the dependency fails and let through again as it recovers.
+//
+// The circuit stays closed while throttling, it can still be forced open or pinned, and a pinned circuit is not
+// throttled.
+type Throttle struct {
+	// K is how many times more calls than the dependency accepts are let through, lower values shed load more
+	// aggressively, at least 1, DefaultThrottleK when 0
+	K float64
+	// Random returns numbers in [0, 1) that calls are rejected by when lower than the probability, it defaults
+	// to math/rand/v2.Float64 and can be set for reproducible tests
+	Random func() float64
+}
+
+// WithThrottle makes the breaker throttle calls instead of opening the circuit, see Throttle
+func WithThrottle(throttle Throttle) SettingsOption {
+	return func(s *Settings) {
+		if throttle.K == 0 {
+			throttle.K = DefaultThrottleK
+		}
+		s.Throttle = &throttle
+	}
+}
+
+func (t Throttle) validate() error {
+	// below 1 calls would be rejected even while every one of them succeeds
+	if t.K < 1 || math.IsNaN(t.K) || math.IsInf(t.K, 0) {
+		return ErrInvalidSettingParam{Param: "Throttle.K", Val: t.K}
+	}
+	return nil
+}
+
+// Probability returns the probability of a call being rejected given the outcomes held by the gauge
+func (t Throttle) Probability(aggregate gauges.Aggregate) float64 {
+	requests := float64(aggregate.RequestCount)
+	accepts := float64(aggregate.SuccessCount)
+	return math.Max(0, (requests-t.K*accepts)/(requests+1))
+}
+
+// rejects draws whether a call is rejected, it must be called with the lock of the breaker held
+func (t Throttle) rejects(aggregate gauges.Aggregate) (bool, float64) {
+	probability := t.Probability(aggregate)
+	if probability == 0 {
+		return false, 0
+	}
+
+	random := t.Random
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < probability, probability
+}
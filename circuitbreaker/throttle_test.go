@@ -0,0 +1,97 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/gauges"
+)
+
+func TestThrottleProbability(t *testing.T) {
+	throttle := circuitbreaker.Throttle{K: 2}
+	testCases := map[string]struct {
+		aggregate gauges.Aggregate
+		expected  float64
+	}{
+		"NoRequests":  {aggregate: gauges.Aggregate{}, expected: 0},
+		"Healthy":     {aggregate: gauges.Aggregate{RequestCount: 10, SuccessCount: 10}, expected: 0},
+		"HalfFailing": {aggregate: gauges.Aggregate{RequestCount: 10, SuccessCount: 5, FailureCount: 5}, expected: 0},
+		"Failing":     {aggregate: gauges.Aggregate{RequestCount: 10, SuccessCount: 3, FailureCount: 7}, expected: 4.0 / 11},
+		"Down":        {aggregate: gauges.Aggregate{RequestCount: 10, FailureCount: 10}, expected: 10.0 / 11},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := throttle.Probability(tc.aggregate); math.Abs(got-tc.expected) > 1e-9 {
+				t.Errorf("throttle.Probability, expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	throttle := circuitbreaker.Throttle{Random: func() float64 { return 0.5 }}
+	settings, _ := circuitbreaker.NewSettings("test", circuitbreaker.WithThrottle(throttle), circuitbreaker.WithMinRequest(1))
+	cb, _ := circuitbreaker.NewBreakerWithSettings(settings)
+	sub := cb.Subscribe(circuitbreaker.DefaultEventBufferSize)
+	defer sub.Close()
+
+	if k := cb.CurrentSettings().Throttle.K; k != circuitbreaker.DefaultThrottleK {
+		t.Errorf("throttle.K, expected the default %v, got %v", circuitbreaker.DefaultThrottleK, k)
+	}
+
+	// the rejection probability is 0, then 1/2 which is not under 0.5, then 2/3
+	cb.ExecuteContext(context.Background(), failingHandler)
+	cb.ExecuteContext(context.Background(), failingHandler)
+	_, err := cb.ExecuteContext(context.Background(), failingHandler)
+
+	var throttled circuitbreaker.ErrThrottled
+	if !errors.As(err, &throttled) || math.Abs(throttled.Probability-2.0/3) > 1e-9 {
+		t.Fatalf("cb.ExecuteContext, expected ErrThrottled with a 2/3 probability, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("cb.State while throttling, expected closed, got %s", cb.State())
+	}
+	if counts := cb.Counts(); counts.Throttled != 1 || counts.Failures != 2 {
+		t.Errorf("cb.Counts, expected 1 throttled call and 2 failures, got %d and %d", counts.Throttled, counts.Failures)
+	}
+	if aggregate := cb.Aggregate(); aggregate.RequestCount != 3 {
+		t.Errorf("cb.Aggregate, expected the throttled call to count as a request, got %d requests", aggregate.RequestCount)
+	}
+
+	events := receiveEvents(t, sub, 5)
+	if events[4].Type != circuitbreaker.Throttled {
+		t.Errorf("event, expected throttled, got %s", events[4].Type)
+	}
+
+	cb.Pin(circuitbreaker.Closed, time.Now().Add(time.Hour), "drill")
+	if _, err := cb.ExecuteContext(context.Background(), succeedingHandler); err != nil {
+		t.Errorf("cb.ExecuteContext while pinned, expected no err, got %s", err)
+	}
+}
+
+func TestThrottleSettings(t *testing.T) {
+	for _, k := range []float64{-1, 0.99} {
+		_, err := circuitbreaker.NewSettings("test", circuitbreaker.WithThrottle(circuitbreaker.Throttle{K: k}))
+		expectedErr := circuitbreaker.ErrInvalidSettingParam{Param: "Throttle.K", Val: k}
+		if err != expectedErr {
+			t.Errorf("circuitbreaker.NewSettings with K %v, expected %s, got %v", k, expectedErr, err)
+		}
+	}
+	if _, err := circuitbreaker.NewSettings("test", circuitbreaker.WithThrottle(circuitbreaker.Throttle{K: 1})); err != nil {
+		t.Errorf("circuitbreaker.NewSettings with K 1, expected no err, got %s", err)
+	}
+
+	cb, _ := circuitbreaker.NewBreaker("test")
+	cb.Trip()
+	if err := cb.UpdateSettings(circuitbreaker.WithThrottle(circuitbreaker.Throttle{})); err != nil {
+		t.Fatalf("cb.UpdateSettings, expected no err, got %s", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("cb.State once throttling, expected closed, got %s", cb.State())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+// client talks to the endpoints served by admin.Handler
+type client struct {
+	addr  string
+	actor string
+	http  *http.Client
+}
+
+func (c *client) list(ctx context.Context) ([]admin.Breaker, error) {
+	var breakers []admin.Breaker
+	err := c.do(ctx, http.MethodGet, "/breakers", nil, &breakers)
+	return breakers, err
+}
+
+func (c *client) describe(ctx context.Context, name string) (admin.Breaker, error) {
+	var breaker admin.Breaker
+	err := c.do(ctx, http.MethodGet, "/breakers/"+url.PathEscape(name), nil, &breaker)
+	return breaker, err
+}
+
+func (c *client) force(ctx context.Context, name string, req admin.ForceRequest) (admin.Breaker, error) {
+	var breaker admin.Breaker
+	err := c.do(ctx, http.MethodPost, "/breakers/"+url.PathEscape(name)+"/force", req, &breaker)
+	return breaker, err
+}
+
+func (c *client) pin(ctx context.Context, name string, req admin.PinRequest) (admin.Breaker, error) {
+	var breaker admin.Breaker
+	err := c.do(ctx, http.MethodPut, "/breakers/"+url.PathEscape(name)+"/pin", req, &breaker)
+	return breaker, err
+}
+
+func (c *client) reset(ctx context.Context, name string, req admin.ResetRequest) (admin.Breaker, error) {
+	var breaker admin.Breaker
+	err := c.do(ctx, http.MethodPost, "/breakers/"+url.PathEscape(name)+"/reset", req, &breaker)
+	return breaker, err
+}
+
+// watch calls handle with every event streamed by the server until the context is done or the server closes
+// the stream
+func (c *client) watch(ctx context.Context, names []string, handle func(admin.Event) error) error {
+	query := url.Values{}
+	for _, name := range names {
+		query.Add("name", name)
+	}
+
+	path := "/watch"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event admin.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return err
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send returns the response only when its status is 200, the error body is turned into an error otherwise
+func (c *client) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.addr, "/")+path, &reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.actor != "" {
+		req.Header.Set(admin.ActorHeader, c.actor)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var apiErr admin.Error
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,145 @@
+// Command cbctl queries and controls the circuit breakers of a running process through the endpoints served by
+// admin.Handler.
+//
+// Usage:
+//
+//	cbctl [flags] list
+//	cbctl [flags] describe <name>
+//	cbctl [flags] force <name> <closed|open|half-open> [--for 10m] [--reason text]
+//	cbctl [flags] reset <name> [--reason text]
+//	cbctl [flags] watch [name...]
+//
+// Flags can be given anywhere on the command line, run cbctl -h for the list.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+// AddrEnv is read for the default admin address
+const AddrEnv = "CBCTL_ADDR"
+
+const defaultAddr = "http://localhost:9090"
+
+var errUsage = errors.New("usage: cbctl [flags] list | describe <name> | force <name> <state> [--for duration] | reset <name> | watch [name...]")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "cbctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cbctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	addr := os.Getenv(AddrEnv)
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	flags.StringVar(&addr, "addr", addr, "address of the admin endpoint, defaults to $"+AddrEnv)
+	output := flags.String("o", outputTable, "output format, table or json")
+	actor := flags.String("actor", os.Getenv("USER"), "who is making the change, recorded in the audit log")
+	pinFor := flags.Duration("for", 0, "with force, pin the state for this long instead of letting the breaker transition")
+	reason := flags.String("reason", "", "with force or reset, the reason recorded in the audit log")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of every command but watch")
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	if len(positional) == 0 {
+		return errUsage
+	}
+
+	c := &client{addr: addr, actor: *actor, http: http.DefaultClient}
+	p := &printer{out: stdout, format: *output}
+	command, positional := positional[0], positional[1:]
+
+	if command == "watch" {
+		return c.watch(ctx, positional, p.event)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	switch {
+	case command == "list" && len(positional) == 0:
+		breakers, err := c.list(ctx)
+		if err != nil {
+			return err
+		}
+		return p.breakers(breakers)
+	case command == "describe" && len(positional) == 1:
+		breaker, err := c.describe(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		return p.breaker(breaker)
+	case command == "force" && len(positional) == 2:
+		var breaker admin.Breaker
+		if *pinFor > 0 {
+			// pins must have a reason, fall back to the command itself so that the audit log still says
+			// where it came from
+			pinReason := *reason
+			if pinReason == "" {
+				pinReason = fmt.Sprintf("cbctl force %s %s --for %s", positional[0], positional[1], pinFor)
+			}
+			breaker, err = c.pin(ctx, positional[0], admin.PinRequest{State: positional[1], Duration: pinFor.String(), Reason: pinReason})
+		} else {
+			breaker, err = c.force(ctx, positional[0], admin.ForceRequest{State: positional[1], Reason: *reason})
+		}
+		if err != nil {
+			return err
+		}
+		return p.breaker(breaker)
+	case command == "reset" && len(positional) == 1:
+		breaker, err := c.reset(ctx, positional[0], admin.ResetRequest{Reason: *reason})
+		if err != nil {
+			return err
+		}
+		return p.breaker(breaker)
+	default:
+		return errUsage
+	}
+}
+
+// parseInterspersed parses flags that appear before, between or after positional arguments, which the flag
+// package alone does not allow
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
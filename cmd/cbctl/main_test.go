@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker"
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+func newServer(t *testing.T) (*httptest.Server, *circuitbreaker.Breaker) {
+	t.Helper()
+
+	payments, _ := circuitbreaker.NewBreaker("payments")
+	inventory, _ := circuitbreaker.NewBreaker("inventory")
+	handler, err := admin.NewHandler([]*circuitbreaker.Breaker{payments, inventory})
+	if err != nil {
+		t.Fatalf("admin.NewHandler, expected no err, got %s", err)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, payments
+}
+
+func runCommand(t *testing.T, server *httptest.Server, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"--addr", server.URL, "--actor", "tester"}, args...)
+	err := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestList(t *testing.T) {
+	server, _ := newServer(t)
+
+	t.Run("Table", func(t *testing.T) {
+		out, err := runCommand(t, server, "list")
+		if err != nil {
+			t.Fatalf("cbctl list, expected no err, got %s", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "NAME") ||
+			!strings.HasPrefix(lines[1], "inventory") || !strings.HasPrefix(lines[2], "payments") {
+			t.Errorf("cbctl list, expected header and two breakers, got\n%s", out)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		out, err := runCommand(t, server, "list", "-o", "json")
+		if err != nil {
+			t.Fatalf("cbctl list -o json, expected no err, got %s", err)
+		}
+
+		var breakers []admin.Breaker
+		if err := json.Unmarshal([]byte(out), &breakers); err != nil || len(breakers) != 2 {
+			t.Errorf("cbctl list -o json, expected two breakers, got %s (%v)", out, err)
+		}
+	})
+}
+
+func TestDescribe(t *testing.T) {
+	server, _ := newServer(t)
+
+	out, err := runCommand(t, server, "describe", "payments")
+	if err != nil {
+		t.Fatalf("cbctl describe, expected no err, got %s", err)
+	}
+
+	if !strings.Contains(out, "Name:") || !strings.Contains(out, "payments") || !strings.Contains(out, "Cooldown:") {
+		t.Errorf("cbctl describe, expected breaker details, got\n%s", out)
+	}
+
+	_, err = runCommand(t, server, "describe", "orders")
+	if err == nil || !strings.Contains(err.Error(), "unknown circuit breaker orders") {
+		t.Errorf("cbctl describe orders, expected unknown breaker error, got %v", err)
+	}
+}
+
+func TestForceAndReset(t *testing.T) {
+	server, payments := newServer(t)
+
+	if _, err := runCommand(t, server, "force", "payments", "open", "--for", "10m"); err != nil {
+		t.Fatalf("cbctl force --for, expected no err, got %s", err)
+	}
+
+	pin, ok := payments.Pinned()
+	if !ok || pin.State != circuitbreaker.Open || time.Until(pin.Until) < 9*time.Minute {
+		t.Errorf("payments.Pinned, expected open pinned for 10m, got %+v %t", pin, ok)
+	}
+
+	if _, err := runCommand(t, server, "reset", "payments", "--reason", "recovered"); err != nil {
+		t.Fatalf("cbctl reset, expected no err, got %s", err)
+	}
+
+	if state := payments.State(); state != circuitbreaker.Closed {
+		t.Errorf("payments.State, expected closed, got %s", state)
+	}
+
+	if _, err := runCommand(t, server, "force", "payments", "half-open"); err != nil {
+		t.Fatalf("cbctl force, expected no err, got %s", err)
+	}
+
+	if state := payments.State(); state != circuitbreaker.HalfOpen {
+		t.Errorf("payments.State, expected half-open, got %s", state)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	server, _ := newServer(t)
+
+	for _, args := range [][]string{{}, {"describe"}, {"force", "payments"}, {"list", "-o", "yaml"}} {
+		if _, err := runCommand(t, server, args...); err == nil {
+			t.Errorf("cbctl %v, expected err, got nil", args)
+		}
+	}
+}
+
+// syncBuffer lets the test read the watch output while run is still writing to it
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	server, payments := newServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := &syncBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- run(ctx, []string{"--addr", server.URL, "watch", "payments", "-o", "json"}, out, &bytes.Buffer{})
+	}()
+
+	waitFor := func(n int) []admin.Event {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) >= n && lines[0] != "" {
+				events := make([]admin.Event, len(lines))
+				for i, line := range lines {
+					json.Unmarshal([]byte(line), &events[i])
+				}
+				return events
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("cbctl watch, expected %d events, got\n%s", n, out.String())
+		return nil
+	}
+
+	waitFor(1)
+	payments.ForceState(circuitbreaker.Open)
+	events := waitFor(3)
+
+	if events[0].Type != admin.EventSnapshot || events[0].State != "closed" {
+		t.Errorf("first event, expected closed snapshot, got %+v", events[0])
+	}
+	if events[1].Type != circuitbreaker.Forced.String() || events[2].Type != circuitbreaker.StateTransition.String() || events[2].State != "open" {
+		t.Errorf("events, expected forced then transition to open, got %+v", events[1:])
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("cbctl watch, expected no err once cancelled, got %s", err)
+	}
+}
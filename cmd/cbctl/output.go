@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aelnahas/circuitbreaker/circuitbreaker/admin"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes command results either as aligned tables or as JSON
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func (p *printer) breakers(breakers []admin.Breaker) error {
+	if p.format == outputJSON {
+		return p.json(breakers)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tREQUESTS\tFAILURE RATE\tSUCCESS RATE\tPINNED UNTIL")
+	for _, b := range breakers {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%.1f%%\t%s\n", b.Name, b.State, b.Aggregate.RequestCount,
+			b.Aggregate.FailureRate, b.Aggregate.SuccessRate, pinnedUntil(b))
+	}
+	return w.Flush()
+}
+
+func (p *printer) breaker(b admin.Breaker) error {
+	if p.format == outputJSON {
+		return p.json(b)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", b.Name)
+	fmt.Fprintf(w, "State:\t%s\n", b.State)
+	if b.OpenUntil != nil {
+		fmt.Fprintf(w, "Open until:\t%s\n", b.OpenUntil.Format(time.RFC3339))
+	}
+	if b.Pin != nil {
+		fmt.Fprintf(w, "Pinned:\t%s until %s (%s)\n", b.Pin.State, b.Pin.Until.Format(time.RFC3339), b.Pin.Reason)
+	}
+	fmt.Fprintln(w, "Thresholds:")
+	fmt.Fprintf(w, "  Failure rate:\t%.1f%%\n", b.Thresholds.FailureRate)
+	fmt.Fprintf(w, "  Recovery rate:\t%.1f%%\n", b.Thresholds.RecoveryRate)
+	fmt.Fprintf(w, "  Cooldown:\t%s\n", b.Thresholds.CooldownDuration)
+	fmt.Fprintf(w, "  Max requests on half-open:\t%d\n", b.Thresholds.MaxRequestOnHalfOpen)
+	fmt.Fprintf(w, "  Min requests:\t%d\n", b.Thresholds.MinRequests)
+	fmt.Fprintln(w, "Aggregate:")
+	fmt.Fprintf(w, "  Requests:\t%d\n", b.Aggregate.RequestCount)
+	fmt.Fprintf(w, "  Failures:\t%d (%.1f%%)\n", b.Aggregate.FailureCount, b.Aggregate.FailureRate)
+	fmt.Fprintf(w, "  Successes:\t%d (%.1f%%)\n", b.Aggregate.SuccessCount, b.Aggregate.SuccessRate)
+	return w.Flush()
+}
+
+// event writes a single line per event so that watch output can be piped
+func (p *printer) event(e admin.Event) error {
+	if p.format == outputJSON {
+		return p.json(e)
+	}
+
+	line := fmt.Sprintf("%s  %-12s  %-16s  %s", e.Time.Format(time.RFC3339), e.Breaker, e.Type, e.State)
+	if e.From != "" && e.From != e.State {
+		line = fmt.Sprintf("%s  %-12s  %-16s  %s -> %s", e.Time.Format(time.RFC3339), e.Breaker, e.Type, e.From, e.State)
+	}
+	if e.Reason != "" {
+		line += "  (" + e.Reason + ")"
+	}
+	_, err := fmt.Fprintln(p.out, line)
+	return err
+}
+
+func (p *printer) json(v interface{}) error {
+	return json.NewEncoder(p.out).Encode(v)
+}
+
+func pinnedUntil(b admin.Breaker) string {
+	if b.Pin == nil {
+		return "-"
+	}
+	return b.Pin.Until.Format(time.RFC3339)
+}